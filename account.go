@@ -32,11 +32,13 @@ package main
 
 import (
     "github.com/murlokswarm/app"
-    "log"
     "net/url"
+    "strings"
     //"pass/dialog"
     "pass/lock"
+    "pass/logger"
     "pass/rest"
+    "pass/urlmatch"
 )
 
 type Account struct {
@@ -45,6 +47,29 @@ type Account struct {
     ImageName string
     Query     string
     Data      rest.DecodedEntry
+
+    // The password is edited as text and kept in the entry's locked
+    // buffer otherwise.
+    Password string
+
+    // URLs and tags are edited as text and split when saved.
+    URLs       string
+    Tags       string
+    FieldTypes []string
+    MatchModes []matchMode
+}
+
+// A match mode of the URLs, as offered to the user.
+type matchMode struct {
+    Mode  string
+    Label string
+}
+
+var matchModes = []matchMode{
+    {urlmatch.ModeDomain, "Match domain"},
+    {urlmatch.ModeHost, "Match host"},
+    {urlmatch.ModeStartsWith, "Match start of URL"},
+    {urlmatch.ModeRegex, "Match regular expression"},
 }
 
 func (h *Account) DefaultView() string {
@@ -95,15 +120,84 @@ func (h *Account) DefaultView() string {
                    class="editable username"/><br/>
             <input name="password"
                    type="text"
-                   value="{{html .Data.Password}}"
+                   value="{{html .Password}}"
                    placeholder="Password"
-                   onchange="Data.Password"
+                   onchange="Password"
                    autocomplete="off"
                    autocorrect="off"
                    autocapitalize="off"
                    spellcheck="false"
                    selectable="on"
                    class="editable password"/>
+            <div class="details">
+                <textarea name="urls"
+                          placeholder="URLs, one per line"
+                          onchange="URLs"
+                          autocomplete="off"
+                          autocorrect="off"
+                          autocapitalize="off"
+                          spellcheck="false"
+                          selectable="on"
+                          class="editable url">{{html .URLs}}</textarea>
+                <select onchange="Data.Match"
+                        class="editable matchmode">
+                    {{range .MatchModes}}
+                    <option value="{{.Mode}}" {{if eq .Mode (or $.Data.Match "domain")}}selected{{end}}>{{.Label}}</option>
+                    {{end}}
+                </select>
+                <input name="tags"
+                       type="text"
+                       value="{{html .Tags}}"
+                       placeholder="Tags, separated by commas"
+                       onchange="Tags"
+                       autocomplete="off"
+                       autocorrect="off"
+                       autocapitalize="off"
+                       spellcheck="false"
+                       selectable="on"
+                       class="editable tags"/>
+                {{range $i, $f := .Data.Fields}}
+                <div class="field">
+                    <input type="text"
+                           value="{{html $f.Name}}"
+                           placeholder="Name (clear to remove)"
+                           onchange="Data.Fields.{{$i}}.Name"
+                           autocomplete="off"
+                           spellcheck="false"
+                           selectable="on"
+                           class="editable fieldname"/>
+                    <select onchange="Data.Fields.{{$i}}.Type"
+                            class="editable fieldtype">
+                        {{range $.FieldTypes}}
+                        <option value="{{.}}" {{if eq . $f.Type}}selected{{end}}>{{.}}</option>
+                        {{end}}
+                    </select>
+                    <input type="{{if eq $f.Type "hidden"}}password{{else}}text{{end}}"
+                           value="{{html $f.Value}}"
+                           placeholder="{{if eq $f.Type "date"}}YYYY-MM-DD{{else}}Value{{end}}"
+                           onchange="Data.Fields.{{$i}}.Value"
+                           autocomplete="off"
+                           autocorrect="off"
+                           autocapitalize="off"
+                           spellcheck="false"
+                           selectable="on"
+                           class="editable fieldvalue"/>
+                </div>
+                {{end}}
+                <a class="clickable addfield" onclick="AddField">+ Add field</a>
+                <textarea name="notes"
+                          placeholder="Notes"
+                          onchange="Data.Notes"
+                          autocomplete="off"
+                          spellcheck="false"
+                          selectable="on"
+                          class="editable notes">{{html .Data.Notes}}</textarea>
+                <p class="timestamps">
+                    {{if not .Data.Created.IsZero}}Created {{.Data.Created.Format "2006-01-02 15:04"}}<br/>{{end}}
+                    {{if not .Data.Modified.IsZero}}Modified {{.Data.Modified.Format "2006-01-02 15:04"}}<br/>{{end}}
+                    {{if not .Data.LastUsed.IsZero}}Last used {{.Data.LastUsed.Format "2006-01-02 15:04"}}{{end}}
+                </p>
+            </div>
           </div>
           <div class="bottom-toolbar">
               <div>
@@ -118,6 +212,8 @@ func (h *Account) DefaultView() string {
 `
 }
 func (h *Account) Render() string {
+    h.FieldTypes = rest.FieldTypes
+    h.MatchModes = matchModes
     return h.DefaultView()
 }
 
@@ -136,17 +232,19 @@ func (h *Account) OnHref(URL *url.URL) {
         })
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
     h.Data = *restResponse
+    h.Password = h.Data.PasswordText()
+    h.URLs = strings.Join(h.Data.URLs, "\n")
+    h.Tags = strings.Join(h.Data.Tags, ", ")
 
     // Acquire the image name. If it exists in preloaded map,
     // use it as is, but if it is not, we subsitute.
-    h.ImageName = GetImageName(h.Title)
-
-    //log.Println(h.Render())
+    _, base := rest.SplitPath(h.Title)
+    h.ImageName = GetImageName(base)
 
     // Tells the app to update the rendering of the component.
     app.Render(h)
@@ -158,40 +256,71 @@ func (h *Account) OK() {
         return
     }
 
-    d := h.Data.Name
-    if d != nil && h.Title != (*d).Text {
-        // need to remove old and submit new
-    } else {
-        if d == nil {
-            h.Data.Name = &rest.Name{
-                Text: h.Title,
-            }
-        }
-        // Modify the decoded entry so that it matches
-        //the contents of the UI.
-        err := restClient.VaultWriteSecret(&h.Data)
+    // Take over the password and the lists edited as text and drop
+    // custom fields whose names were cleared.
+    h.Data.SetPassword(h.Password)
+    h.Data.URLs = rest.SplitList(h.URLs)
+    h.Data.Tags = rest.SplitList(h.Tags)
+
+    fields := []rest.Field{}
 
-        if err != nil {
-            log.Println(err)
-            return
+    for _, f := range h.Data.Fields {
+        if f.Name != "" {
+            fields = append(fields, f)
         }
     }
+
+    h.Data.Fields = fields
+
+    if err := saveEntry(&h.Data, h.Title); err != nil {
+        logger.Error(err)
+        return
+    }
+
     // Now, we just need to go back.
     h.Cancel()
 }
 
+// Writes an entry edited in a view under the given title.
+func saveEntry(data *rest.DecodedEntry, title string) error {
+    d := data.Name
+    if d != nil && title != (*d).Text {
+        // The name changed, possibly into another folder, so the entry
+        // is stored under its new name and the old one removed.
+        return restClient.VaultRenameSecret(data, title)
+    }
+
+    if d == nil {
+        data.Name = &rest.Name{
+            Text: title,
+        }
+    }
+
+    // Modify the decoded entry so that it matches
+    //the contents of the UI.
+    return restClient.VaultWriteSecret(data)
+}
+
 func (h *Account) Cancel() {
     NavigateBack("")
 }
 
 func (h *Account) RandomizePassword() {
     // Generate a new password.
-    h.Data.Password = lock.EntropyAlphabet(32)
+    h.Password = lock.EntropyAlphabet(32)
 
     // Tells the app to update the rendering of the component.
     app.Render(h)
 }
 
+func (h *Account) AddField() {
+    h.Data.Fields = append(h.Data.Fields, rest.Field{
+        Type: rest.FieldText,
+    })
+
+    app.Render(h)
+}
+
 func (h *Account) Delete() {
     d := h.Data.Name
     if d != nil {
@@ -201,7 +330,6 @@ func (h *Account) Delete() {
 }
 
 func (h *Account) DoSearchQuery(arg app.ChangeArg) {
-    log.Println(arg.Value, h.Query)
     NavigateBack(arg.Value)
 }
 
@@ -0,0 +1,589 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package agent keeps Pass unlocked between runs of the command line
+// tools and the desktop app, in the style of gpg-agent. The agent holds
+// the derived key and the decrypted token in memory and hands them to
+// clients on the same machine, so that the password is asked for and
+// Argon2 run only once per session.
+//
+// The agent listens on a unix socket which only the user may access.
+// Every request must carry a secret which the agent writes next to the
+// socket, readable only by the user. The agent locks itself, wiping
+// the key, after being idle for a while and on request.
+package agent
+
+import (
+    "bytes"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net"
+    "os"
+    "path/filepath"
+    "pass/lock"
+    "pass/socket"
+    "pass/util"
+    "sync"
+    "time"
+)
+
+const (
+    // Environment variable pointing to the socket.
+    SocketEnv = "PASS_AGENT_SOCK"
+
+    // Time after which an idle agent locks itself.
+    DefaultTimeout = 15 * time.Minute
+
+    authLength = 32
+
+    // The longest message read, which leaves plenty of room for a key
+    // and a token.
+    maxMessage = 4096
+)
+
+var (
+    ErrNoAgent = errors.New("no agent running")
+    ErrRunning = errors.New("agent already running")
+    ErrLocked  = errors.New("agent is locked")
+    ErrKey     = errors.New("key does not unlock the token")
+    ErrDenied  = errors.New("not authorized")
+    ErrRequest = errors.New("unknown request")
+    ErrMessage = errors.New("message too long")
+)
+
+// Operations of the protocol. Each connection carries one request and
+// one response, both a line of JSON. The key and the token are never
+// marshalled by encoding/json, which keeps its buffers for reuse, but
+// written by writeMessage.
+const (
+    opStatus = "status"
+    opKey    = "key"
+    opUnlock = "unlock"
+    opLock   = "lock"
+)
+
+type request struct {
+    Auth string `json:"auth"`
+    Op   string `json:"op"`
+    Key  []byte `json:"key,omitempty"`
+}
+
+type response struct {
+    Error   string    `json:"error,omitempty"`
+    Locked  bool      `json:"locked"`
+    Expires time.Time `json:"expires,omitempty"`
+    Salt    []byte    `json:"salt,omitempty"`
+    Key     []byte    `json:"key,omitempty"`
+    Token   []byte    `json:"token,omitempty"`
+}
+
+// Errors are sent as text and mapped back on the client, so that they
+// can be compared.
+var knownErrors = []error{ErrLocked, ErrKey, ErrDenied, ErrRequest}
+
+// SocketPath returns the path of the socket: the given path if set,
+// $PASS_AGENT_SOCK if set, and otherwise pass/agent.sock in the user
+// configuration directory.
+func SocketPath(path string) string {
+    if path != "" {
+        return path
+    }
+
+    if path = os.Getenv(SocketEnv); path != "" {
+        return path
+    }
+
+    dir, err := os.UserConfigDir()
+
+    if err != nil {
+        dir = os.TempDir()
+    }
+
+    return filepath.Join(dir, "pass", "agent.sock")
+}
+
+// The file holding the secret for a socket.
+func authPath(socket string) string {
+    return socket + ".auth"
+}
+
+// Agent holds the key for one vault. It starts locked and is unlocked
+// by a client which has derived the key from the password.
+type Agent struct {
+    // Idle time after which the agent locks itself. If zero, it stays
+    // unlocked until asked to lock.
+    Timeout time.Duration
+
+    // Called whenever the agent locks, e.g., for logging.
+    OnLock func()
+
+    salt           []byte
+    encryptedToken string
+
+    mu       sync.Mutex
+    key      *lock.SecureBuffer
+    token    *lock.SecureBuffer
+    expires  time.Time
+    timer    *time.Timer
+    auth     []byte
+    listener net.Listener
+    path     string
+}
+
+// New sets up a locked agent for the vault of the configuration.
+func New(c util.Configuration, timeout time.Duration) (*Agent, error) {
+    salt, err := hex.DecodeString(c.Encrypted.Salt)
+
+    if err != nil {
+        return nil, err
+    }
+
+    return &Agent{
+        Timeout:        timeout,
+        salt:           salt,
+        encryptedToken: c.Encrypted.Token,
+    }, nil
+}
+
+// Unlock verifies the key against the encrypted token and keeps both.
+func (a *Agent) Unlock(key []byte) error {
+    l := lock.Lock{Key: lock.SecureCopy(key), Salt: a.salt}
+    token, err := l.UnlockToken(a.encryptedToken)
+
+    if err != nil {
+        l.Wipe()
+        return ErrKey
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    a.wipe()
+    a.key = l.Key
+    a.token = token
+    a.touch()
+
+    return nil
+}
+
+// Lock wipes the key and forgets the token.
+func (a *Agent) Lock() {
+    a.mu.Lock()
+    locked := a.key == nil
+    a.wipe()
+    a.mu.Unlock()
+
+    if !locked && a.OnLock != nil {
+        a.OnLock()
+    }
+}
+
+// Locked reports whether the agent holds no key.
+func (a *Agent) Locked() bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    return a.key == nil
+}
+
+// wipe must be called with the mutex held.
+func (a *Agent) wipe() {
+    a.key.Wipe()
+    a.key = nil
+    a.token.Wipe()
+    a.token = nil
+    a.expires = time.Time{}
+
+    if a.timer != nil {
+        a.timer.Stop()
+        a.timer = nil
+    }
+}
+
+// touch restarts the idle timer. It must be called with the mutex held.
+func (a *Agent) touch() {
+    if a.Timeout <= 0 {
+        return
+    }
+
+    a.expires = time.Now().Add(a.Timeout)
+
+    if a.timer != nil {
+        a.timer.Reset(a.Timeout)
+        return
+    }
+
+    a.timer = time.AfterFunc(a.Timeout, func() {
+        a.mu.Lock()
+        idle := a.key != nil && !time.Now().Before(a.expires)
+        a.mu.Unlock()
+
+        if idle {
+            a.Lock()
+        }
+    })
+}
+
+// Listen serves the agent on a unix socket at path and writes the
+// secret clients have to present to path.auth.
+func (a *Agent) Listen(path string) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return err
+    }
+
+    // Remove a stale socket from an earlier run, but never take over
+    // from a running agent.
+    if conn, err := net.Dial("unix", path); err == nil {
+        conn.Close()
+        return ErrRunning
+    }
+
+    if err := socket.RemoveStale(path); err != nil {
+        return err
+    }
+
+    auth := lock.Entropy(authLength)
+
+    if err := writeAuth(authPath(path), auth); err != nil {
+        return err
+    }
+
+    listener, err := socket.ListenPrivate(path)
+
+    if err != nil {
+        os.Remove(authPath(path))
+        return err
+    }
+
+    a.mu.Lock()
+    a.listener = listener
+    a.path = path
+    a.auth = auth
+    a.mu.Unlock()
+
+    go a.serve(listener)
+    return nil
+}
+
+// writeAuth replaces the secret file, so that it is created with the
+// right permissions even if an old one is left over.
+func writeAuth(path string, auth []byte) error {
+    os.Remove(path)
+
+    f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+    if err != nil {
+        return err
+    }
+
+    if _, err = f.WriteString(hex.EncodeToString(auth)); err != nil {
+        f.Close()
+        return err
+    }
+
+    return f.Close()
+}
+
+func (a *Agent) serve(listener net.Listener) {
+    for {
+        conn, err := listener.Accept()
+
+        if err != nil {
+            return
+        }
+
+        go func() {
+            defer conn.Close()
+            conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+            var req request
+
+            if readMessage(conn, &req) != nil {
+                lock.Zero(req.Key)
+                return
+            }
+
+            res := a.handle(req)
+            key, token := res.Key, res.Token
+            res.Key, res.Token = nil, nil
+            writeMessage(conn, res, secret{"key", key}, secret{"token", token})
+
+            // The copies of the key and the token sent or received.
+            lock.Zero(req.Key)
+            lock.Zero(key)
+            lock.Zero(token)
+        }()
+    }
+}
+
+func (a *Agent) handle(req request) response {
+    a.mu.Lock()
+    auth := a.auth
+    a.mu.Unlock()
+
+    given, _ := hex.DecodeString(req.Auth)
+
+    if len(auth) == 0 || subtle.ConstantTimeCompare(given, auth) != 1 {
+        return response{Error: ErrDenied.Error()}
+    }
+
+    switch req.Op {
+    case opStatus:
+    case opKey:
+        a.mu.Lock()
+        defer a.mu.Unlock()
+
+        if a.key == nil {
+            return response{Error: ErrLocked.Error(), Locked: true}
+        }
+
+        a.touch()
+
+        return response{
+            Expires: a.expires,
+            Salt:    a.salt,
+            Key:     append([]byte(nil), a.key.Bytes()...),
+            Token:   append([]byte(nil), a.token.Bytes()...),
+        }
+    case opUnlock:
+        if err := a.Unlock(req.Key); err != nil {
+            return response{Error: err.Error(), Locked: a.Locked()}
+        }
+    case opLock:
+        a.Lock()
+    default:
+        return response{Error: ErrRequest.Error(), Locked: a.Locked()}
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    return response{Locked: a.key == nil, Expires: a.expires}
+}
+
+// Close locks the agent, stops serving it and removes the socket and
+// the secret.
+func (a *Agent) Close() error {
+    a.Lock()
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.listener == nil {
+        return nil
+    }
+
+    err := a.listener.Close()
+    os.Remove(authPath(a.path))
+    os.Remove(a.path)
+    a.listener = nil
+    a.auth = nil
+
+    return err
+}
+
+// Status describes the state of a running agent.
+type Status struct {
+    Locked bool
+
+    // When the agent locks itself unless used; zero if it does not.
+    Expires time.Time
+}
+
+// Client talks to a running agent.
+type Client struct {
+    path string
+    auth string
+}
+
+// Dial connects to the agent at the socket path. It returns ErrNoAgent
+// if none is running.
+func Dial(path string) (*Client, error) {
+    auth, err := ioutil.ReadFile(authPath(path))
+
+    if err != nil {
+        return nil, ErrNoAgent
+    }
+
+    c := &Client{path: path, auth: string(bytes.TrimSpace(auth))}
+
+    if _, err = c.Status(); err != nil {
+        return nil, err
+    }
+
+    return c, nil
+}
+
+func (c *Client) call(req request) (response, error) {
+    var res response
+
+    conn, err := net.Dial("unix", c.path)
+
+    if err != nil {
+        return res, ErrNoAgent
+    }
+
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+    req.Auth = c.auth
+    key := req.Key
+    req.Key = nil
+
+    if err = writeMessage(conn, req, secret{"key", key}); err != nil {
+        return res, err
+    }
+
+    if err = readMessage(conn, &res); err != nil {
+        lock.Zero(res.Key)
+        lock.Zero(res.Token)
+        return response{}, err
+    }
+
+    if res.Error != "" {
+        return res, toError(res.Error)
+    }
+
+    return res, nil
+}
+
+// A field of a message holding a key or a token.
+type secret struct {
+    name string
+    data []byte
+}
+
+// writeMessage sends v, which must marshal to a non-empty JSON object,
+// as a line with the secrets added in base64. The line is put together
+// in a locked buffer, which is wiped once written.
+func writeMessage(w io.Writer, v interface{}, secrets ...secret) error {
+    data, err := json.Marshal(v)
+
+    if err != nil {
+        return err
+    }
+
+    size := len(data) + 1
+
+    for _, s := range secrets {
+        if len(s.data) > 0 {
+            size += len(s.name) + len(`,"":""`) + base64.StdEncoding.EncodedLen(len(s.data))
+        }
+    }
+
+    b := lock.NewSecureBuffer(size)
+    defer b.Wipe()
+
+    line := b.Bytes()
+    n := copy(line, data[:len(data)-1])
+
+    for _, s := range secrets {
+        if len(s.data) == 0 {
+            continue
+        }
+
+        n += copy(line[n:], `,"`+s.name+`":"`)
+        base64.StdEncoding.Encode(line[n:], s.data)
+        n += base64.StdEncoding.EncodedLen(len(s.data))
+        n += copy(line[n:], `"`)
+    }
+
+    copy(line[n:], "}\n")
+
+    _, err = w.Write(line)
+    return err
+}
+
+// readMessage reads a line into a locked buffer, which is wiped once
+// the line is unmarshalled into v. Secrets in v are left to the caller.
+func readMessage(r io.Reader, v interface{}) error {
+    b := lock.NewSecureBuffer(maxMessage)
+    defer b.Wipe()
+
+    line := b.Bytes()
+    n := 0
+
+    for n < len(line) {
+        m, err := r.Read(line[n:])
+        n += m
+
+        if i := bytes.IndexByte(line[n-m:n], '\n'); i >= 0 {
+            return json.Unmarshal(line[:n-m+i], v)
+        }
+
+        if err != nil {
+            return err
+        }
+    }
+
+    return ErrMessage
+}
+
+func toError(s string) error {
+    for _, err := range knownErrors {
+        if err.Error() == s {
+            return err
+        }
+    }
+
+    return errors.New(s)
+}
+
+// Status asks whether the agent is unlocked.
+func (c *Client) Status() (Status, error) {
+    res, err := c.call(request{Op: opStatus})
+    return Status{Locked: res.Locked, Expires: res.Expires}, err
+}
+
+// Key returns the key and the decrypted token, and the salt to tell
+// which vault they belong to. It returns ErrLocked if the agent is
+// locked. Every call counts as use and postpones locking.
+func (c *Client) Key() (key []byte, salt []byte, token []byte, err error) {
+    res, err := c.call(request{Op: opKey})
+    return res.Key, res.Salt, res.Token, err
+}
+
+// Unlock hands a key derived from the password to the agent. It returns
+// ErrKey if the key is not the one of the agent's vault.
+func (c *Client) Unlock(key []byte) error {
+    _, err := c.call(request{Op: opUnlock, Key: key})
+    return err
+}
+
+// Lock asks the agent to lock.
+func (c *Client) Lock() error {
+    _, err := c.call(request{Op: opLock})
+    return err
+}
@@ -0,0 +1,243 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package agent
+
+import (
+    "bytes"
+    "os"
+    "pass/lock"
+    "pass/util"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// Sets up a configuration whose token is locked with a random key.
+func testConfig(t *testing.T) (util.Configuration, []byte) {
+    key := lock.Entropy(32)
+    l := lock.Lock{Key: lock.SecureCopy(key)}
+
+    token, salt, err := l.LockToken("s.token")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var c util.Configuration
+    c.Encrypted.Token = token
+    c.Encrypted.Salt = salt
+
+    return c, key
+}
+
+func startAgent(t *testing.T, timeout time.Duration) (*Agent, string, []byte) {
+    c, key := testConfig(t)
+    a, err := New(c, timeout)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    path := filepath.Join(t.TempDir(), "agent.sock")
+
+    if err = a.Listen(path); err != nil {
+        t.Fatal(err)
+    }
+
+    t.Cleanup(func() { a.Close() })
+
+    return a, path, key
+}
+
+func TestUnlockAndLock(t *testing.T) {
+    a, path, key := startAgent(t, 0)
+    c, err := Dial(path)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if s, err := c.Status(); err != nil || !s.Locked {
+        t.Errorf("Agent did not start locked: %v %v", s, err)
+    }
+
+    if _, _, _, err = c.Key(); err != ErrLocked {
+        t.Errorf("Locked agent handed out a key: %v", err)
+    }
+
+    if err = c.Unlock(lock.Entropy(32)); err != ErrKey {
+        t.Errorf("Agent accepted a wrong key: %v", err)
+    }
+
+    if err = c.Unlock(key); err != nil {
+        t.Fatal(err)
+    }
+
+    k, salt, token, err := c.Key()
+
+    if err != nil || !bytes.Equal(k, key) || !bytes.Equal(salt, a.salt) || string(token) != "s.token" {
+        t.Errorf("Unexpected key %x, salt %x and token %q: %v", k, salt, token, err)
+    }
+
+    kept := a.key.Bytes()
+
+    if err = c.Lock(); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, _, _, err = c.Key(); err != ErrLocked {
+        t.Errorf("Agent did not lock: %v", err)
+    }
+
+    if !bytes.Equal(kept, make([]byte, len(kept))) {
+        t.Errorf("Key not overwritten")
+    }
+
+    if a.key != nil || a.token != nil {
+        t.Errorf("Key or token kept after locking")
+    }
+}
+
+func TestWrongSecret(t *testing.T) {
+    a, path, key := startAgent(t, 0)
+    a.Unlock(key)
+
+    c := &Client{path: path, auth: "00"}
+
+    if _, _, _, err := c.Key(); err != ErrDenied {
+        t.Errorf("Key handed out without the secret: %v", err)
+    }
+
+    if err := c.Lock(); err != ErrDenied {
+        t.Errorf("Lock without the secret: %v", err)
+    }
+}
+
+func TestIdleTimeout(t *testing.T) {
+    a, path, key := startAgent(t, 100*time.Millisecond)
+    locked := make(chan bool, 1)
+    a.OnLock = func() { locked <- true }
+
+    c, err := Dial(path)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    c.Unlock(key)
+
+    // Use postpones locking.
+    time.Sleep(60 * time.Millisecond)
+
+    if _, _, _, err = c.Key(); err != nil {
+        t.Fatal(err)
+    }
+
+    time.Sleep(60 * time.Millisecond)
+
+    if a.Locked() {
+        t.Errorf("Agent locked although used")
+    }
+
+    select {
+    case <-locked:
+    case <-time.After(time.Second):
+        t.Fatal("Agent did not lock when idle")
+    }
+
+    if _, _, _, err = c.Key(); err != ErrLocked {
+        t.Errorf("Agent handed out a key after locking: %v", err)
+    }
+}
+
+func TestListenAndClose(t *testing.T) {
+    a, path, _ := startAgent(t, 0)
+
+    info, err := os.Stat(authPath(path))
+
+    if err != nil || info.Mode().Perm() != 0600 {
+        t.Errorf("Secret not only readable by the user: %v %v", info, err)
+    }
+
+    if info, err = os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+        t.Errorf("Others may connect: %v %v", info, err)
+    }
+
+    other, _ := New(util.Configuration{}, 0)
+
+    if err = other.Listen(path); err != ErrRunning {
+        t.Errorf("Took over the socket of a running agent: %v", err)
+    }
+
+    a.Close()
+
+    if _, err = os.Stat(authPath(path)); !os.IsNotExist(err) {
+        t.Errorf("Secret not removed")
+    }
+
+    if _, err = Dial(path); err != ErrNoAgent {
+        t.Errorf("Agent still reachable: %v", err)
+    }
+}
+
+func TestMessage(t *testing.T) {
+    var w bytes.Buffer
+
+    key := lock.Entropy(32)
+    res := response{Expires: time.Now().UTC(), Salt: []byte("salt")}
+
+    if err := writeMessage(&w, res, secret{"key", key}, secret{"token", nil}); err != nil {
+        t.Fatal(err)
+    }
+
+    line := w.String()
+
+    if !strings.HasSuffix(line, "}\n") || strings.Count(line, "\n") != 1 || strings.Contains(line, "token") {
+        t.Errorf("Unexpected message: %q", line)
+    }
+
+    var read response
+
+    if err := readMessage(&w, &read); err != nil {
+        t.Fatal(err)
+    }
+
+    if !bytes.Equal(read.Key, key) || !bytes.Equal(read.Salt, res.Salt) || !read.Expires.Equal(res.Expires) {
+        t.Errorf("Message not read back: %v", read)
+    }
+
+    long := bytes.Repeat([]byte("a"), maxMessage+1)
+
+    if err := readMessage(bytes.NewReader(long), &read); err != ErrMessage {
+        t.Errorf("Read a message which is too long: %v", err)
+    }
+}
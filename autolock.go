@@ -0,0 +1,73 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "pass/logger"
+    "pass/observe"
+    "pass/session"
+    "pass/util"
+)
+
+// Locks the app when it has not been used for a while, or has been
+// unlocked for too long.
+var autoLock = session.New(nil, session.DefaultIdle, session.DefaultLifetime, nil)
+
+// activity counts every request to Vault made by the app, e.g., when
+// searching or opening an entry, as use.
+type activity struct{}
+
+func (activity) Observe(e observe.Event) {
+    autoLock.Touch()
+}
+
+// setupAutoLock applies the configured limits.
+func setupAutoLock(c util.Configuration) {
+    var err error
+
+    if autoLock.Idle, err = session.ParseDuration(c.AutoLock.Idle, session.DefaultIdle); err != nil {
+        logger.Warn("Invalid idle timeout:", err)
+        autoLock.Idle = session.DefaultIdle
+    }
+
+    if autoLock.Lifetime, err = session.ParseDuration(c.AutoLock.Lifetime, session.DefaultLifetime); err != nil {
+        logger.Warn("Invalid session lifetime:", err)
+        autoLock.Lifetime = session.DefaultLifetime
+    }
+
+    autoLock.OnLock = func() {
+        logger.Info("Session expired.")
+
+        // The timer fires on a goroutine of its own.
+        app.CallOnUIGoroutine(Lock)
+    }
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "pass/rest"
+    "sync"
+)
+
+// Jobs which read or rewrite entries in the background, such as
+// upgrading the vault or loading the SSH keys, run with clones of the
+// client, as the views keep using theirs. Lock cancels them and waits
+// until their clones are wiped.
+var (
+    jobs       sync.WaitGroup
+    jobsMu     sync.Mutex
+    jobClients = make(map[*rest.Client]bool)
+)
+
+// runInBackground runs job with a clone of the client. The clone does
+// not count as activity, so that a long job does not keep Pass from
+// locking itself. Once the job has returned and the clone is wiped,
+// then, if set, is called on the UI goroutine.
+func runInBackground(job func(client *rest.Client), then func()) {
+    client := restClient.Clone()
+    client.Observer = observer
+
+    jobsMu.Lock()
+    jobClients[client] = true
+    jobsMu.Unlock()
+
+    jobs.Add(1)
+
+    go func() {
+        job(client)
+
+        jobsMu.Lock()
+        delete(jobClients, client)
+        jobsMu.Unlock()
+
+        client.Wipe()
+        jobs.Done()
+
+        if then != nil {
+            app.CallOnUIGoroutine(then)
+        }
+    }()
+}
+
+// stopBackground cancels the jobs running in the background and waits
+// until they have returned and wiped their clients.
+func stopBackground() {
+    jobsMu.Lock()
+
+    for client := range jobClients {
+        client.Cancel()
+    }
+
+    jobsMu.Unlock()
+    jobs.Wait()
+}
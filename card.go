@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "net/url"
+    "pass/logger"
+    "pass/rest"
+    "time"
+)
+
+type Card struct {
+    Title   string
+    Brand   string
+    Expired bool
+    Error   string
+    Card    rest.Card
+    Data    rest.DecodedEntry
+}
+
+func (h *Card) Render() string {
+    h.Brand = h.Card.Brand()
+    h.Expired = h.Card.Expired(time.Now())
+
+    return `
+<div class="WindowLayout">
+    <div class="SearchLayout">
+        <input type="text"
+               value="{{html .Title}}"
+               placeholder="Account"
+               onchange="DoSearchQuery"
+               autocomplete="off"
+               autocorrect="off"
+               autocapitalize="off"
+               spellcheck="false"
+               selectable="on"
+               class="editable searchfield"/>
+        <div class="animated">
+            <div style="text-align: center;
+                        margin-left: auto;
+                        margin-right: auto;
+                        margin-top: -webkit-calc(20vh - 20px);">
+                <img src="iconpack/` + GetImageName("card") + `.png"
+                      style="max-width: 128px; "/>
+                <p><input type="text"
+                       value="{{.Title}}"
+                       placeholder="Card"
+                       onchange="Title"
+                       autocomplete="off"
+                       spellcheck="false"
+                       selectable="on"
+                       class="editable name"/></p>
+                <p class="timestamps">{{.Brand}}{{if .Expired}} (expired){{end}}</p>
+            </div>
+            <input type="text"
+                   value="{{html .Card.Holder}}"
+                   placeholder="Cardholder"
+                   onchange="Card.Holder"
+                   autocomplete="off"
+                   autocorrect="off"
+                   autocapitalize="off"
+                   spellcheck="false"
+                   selectable="on"
+                   class="editable username"/><br/>
+            <input type="text"
+                   value="{{html .Card.Number}}"
+                   placeholder="Card number"
+                   onchange="Card.Number"
+                   autocomplete="off"
+                   autocorrect="off"
+                   spellcheck="false"
+                   selectable="on"
+                   class="editable password"/>
+            <div class="details">
+                <div class="field">
+                    <input type="text"
+                           value="{{html .Card.Expiry}}"
+                           placeholder="Expiry (MM/YY)"
+                           onchange="Card.Expiry"
+                           autocomplete="off"
+                           spellcheck="false"
+                           selectable="on"
+                           class="editable fieldname"/>
+                    <input type="password"
+                           value="{{html .Card.CVV}}"
+                           placeholder="Security code"
+                           onchange="Card.CVV"
+                           autocomplete="off"
+                           spellcheck="false"
+                           selectable="on"
+                           class="editable fieldvalue"/>
+                </div>
+                <textarea placeholder="Notes"
+                          onchange="Data.Notes"
+                          autocomplete="off"
+                          spellcheck="false"
+                          selectable="on"
+                          class="editable notes">{{html .Data.Notes}}</textarea>
+                {{if .Error}}<p class="timestamps">{{.Error}}</p>{{end}}
+            </div>
+          </div>
+          <div class="bottom-toolbar">
+              <div>
+                  <button class="button ok" onclick="OK"/>
+                  <button class="button cancel" onclick="Cancel"/>
+                  <button class="button delete" onclick="Delete"/>
+              </div>
+          </div>
+     </div>
+</div>`
+}
+
+func (h *Card) OnHref(URL *url.URL) {
+    u := URL.Query()
+    h.Title = u.Get("Name")
+
+    restResponse, err := restClient.VaultReadSecret(
+        &rest.Name{
+            Text:      h.Title,
+            Encrypted: u.Get("Encrypted"),
+        })
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
+
+    h.Data = *restResponse
+
+    if c, ok := h.Data.Payload.(*rest.Card); ok {
+        h.Card = *c
+    }
+
+    app.Render(h)
+}
+
+func (h *Card) OK() {
+    // We do not want empty names.
+    if h.Title == "" {
+        return
+    }
+
+    h.Data.Type = rest.TypeCard
+    h.Data.Payload = &h.Card
+
+    if err := saveEntry(&h.Data, h.Title); err != nil {
+        logger.Error(err)
+        h.Error = err.Error()
+        app.Render(h)
+        return
+    }
+
+    h.Cancel()
+}
+
+func (h *Card) Cancel() {
+    NavigateBack("")
+}
+
+func (h *Card) Delete() {
+    if h.Data.Name != nil {
+        restClient.VaultDeleteSecret(&h.Data)
+    }
+    h.Cancel()
+}
+
+func (h *Card) DoSearchQuery(arg app.ChangeArg) {
+    NavigateBack(arg.Value)
+}
+
+func init() {
+    app.RegisterComponent(&Card{})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command docker-credential-pass is a docker credential helper which
+// keeps registry credentials in Pass. Enable it in
+// ~/.docker/config.json with
+//
+//     { "credsStore": "pass" }
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "pass/dockercred"
+    "pass/logger"
+    "pass/vault"
+)
+
+// Version of the helper, as reported by the version action.
+const Version = "1.0.0"
+
+func main() {
+    configPath := flag.String("config", "", "configuration file (default $PASS_CONFIG)")
+    folder := flag.String("folder", dockercred.DefaultFolder, "folder for credentials stored by docker")
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: docker-credential-pass [-config file] [-folder folder] store|get|erase|list|version")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelWarn)
+
+    if flag.NArg() != 1 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    action := flag.Arg(0)
+
+    switch action {
+    case dockercred.ActionVersion:
+        fmt.Println("docker-credential-pass", Version)
+        return
+    case dockercred.ActionStore, dockercred.ActionGet,
+        dockercred.ActionErase, dockercred.ActionList:
+    default:
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    // Errors are reported on standard output, where docker reads them.
+    client, err := vault.Open(*configPath)
+
+    if err != nil {
+        fmt.Fprintln(os.Stdout, err)
+        os.Exit(1)
+    }
+
+    helper := dockercred.Helper{Vault: client, Folder: *folder}
+
+    if err = helper.Serve(action, os.Stdin, os.Stdout); err != nil {
+        fmt.Fprintln(os.Stdout, err)
+        os.Exit(1)
+    }
+}
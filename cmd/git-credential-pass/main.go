@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command git-credential-pass is a git credential helper which keeps
+// credentials in Pass. Enable it with
+//
+//     git config --global credential.helper pass
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "pass/gitcred"
+    "pass/logger"
+    "pass/util"
+    "pass/vault"
+)
+
+func main() {
+    configPath := flag.String("config", "", "configuration file (default $PASS_CONFIG)")
+    folder := flag.String("folder", gitcred.DefaultFolder, "folder for credentials stored by git")
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: git-credential-pass [-config file] [-folder folder] get|store|erase")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelWarn)
+
+    if flag.NArg() != 1 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    // Unknown operations are to be ignored, so there is no need to
+    // ask for the password.
+    switch flag.Arg(0) {
+    case gitcred.OperationGet, gitcred.OperationStore, gitcred.OperationErase:
+    default:
+        return
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(*configPath))
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "git-credential-pass:", err)
+        os.Exit(1)
+    }
+
+    client, err := vault.Open(*configPath)
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "git-credential-pass:", err)
+        os.Exit(1)
+    }
+
+    helper := gitcred.Helper{Vault: client, Folder: *folder, Matcher: c.Matcher()}
+
+    if err = helper.Run(flag.Arg(0), os.Stdin, os.Stdout); err != nil {
+        fmt.Fprintln(os.Stderr, "git-credential-pass:", err)
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,190 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command pass-native-host is the native messaging host through which
+// the browser extension fills in logins from Pass. The browser starts
+// it; Pass must be unlocked through the agent, as there is no terminal
+// to ask for the password.
+//
+// Register it with the browser and pair the extension with
+//
+//     pass-native-host -manifest chrome -extension <id> > \
+//         ~/Library/Application\ Support/Google/Chrome/NativeMessagingHosts/pass_desktop.json
+//     pass-native-host -pair
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "pass/agent"
+    "pass/logger"
+    "pass/nativehost"
+    "pass/util"
+    "pass/vault"
+    "path/filepath"
+)
+
+// The pairings are kept next to the configuration.
+const pairingsFile = "browser.json"
+
+// The extension which started the host: Chrome passes its origin,
+// Firefox the path of the manifest and the extension ID.
+func caller(args []string) string {
+    switch len(args) {
+    case 0:
+        return ""
+    case 1:
+        return args[0]
+    default:
+        return args[1]
+    }
+}
+
+func allowed(c util.Configuration, extension string) bool {
+    if len(c.Browser.Extensions) == 0 {
+        return true
+    }
+
+    for _, e := range c.Browser.Extensions {
+        if e == extension {
+            return true
+        }
+    }
+
+    return false
+}
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, "pass-native-host:", err)
+    os.Exit(1)
+}
+
+func main() {
+    configPath := flag.String("config", "", "configuration file (default $PASS_CONFIG)")
+    pair := flag.Bool("pair", false, "print a code to pair an extension")
+    list := flag.Bool("list", false, "list the paired extensions")
+    unpair := flag.String("unpair", "", "unpair the extension with this client ID")
+    manifest := flag.String("manifest", "", "print the manifest for chrome or firefox")
+    extension := flag.String("extension", "", "extension ID for the manifest")
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: pass-native-host [-config file] [-pair | -list | -unpair client | -manifest browser -extension id]")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    // Standard output is for messages to the browser.
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelWarn)
+
+    path := vault.ConfigPath(*configPath)
+    pairings := nativehost.NewPairings(filepath.Join(filepath.Dir(path), pairingsFile))
+
+    switch {
+    case *pair:
+        code, err := pairings.NewCode()
+
+        if err != nil {
+            fail(err)
+        }
+
+        fmt.Printf("Enter %s in the extension within %v.\n", code, nativehost.PairingTimeout)
+        return
+    case *list:
+        clients, err := pairings.List()
+
+        if err != nil {
+            fail(err)
+        }
+
+        for _, p := range clients {
+            fmt.Printf("%s\t%s\t%s\n", p.Client, p.Name, p.Created.Format("2006-01-02 15:04"))
+        }
+
+        return
+    case *unpair != "":
+        if err := pairings.Remove(*unpair); err != nil {
+            fail(err)
+        }
+
+        return
+    case *manifest != "":
+        executable, err := os.Executable()
+
+        if err != nil {
+            fail(err)
+        }
+
+        m, err := nativehost.Manifest(*manifest, executable, *extension)
+
+        if err != nil || *extension == "" {
+            flag.Usage()
+            os.Exit(2)
+        }
+
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        encoder.Encode(m)
+        return
+    }
+
+    c, err := util.LoadConfiguration(path)
+
+    if err != nil {
+        fail(err)
+    }
+
+    if origin := caller(flag.Args()); !allowed(c, origin) {
+        fail(fmt.Errorf("extension %s is not allowed", origin))
+    }
+
+    host := nativehost.Host{
+        Pairings: pairings,
+        Matcher:  c.Matcher(),
+        Open: func() (nativehost.Vault, error) {
+            client, err := vault.Resume(c)
+
+            if err == agent.ErrNoAgent || err == agent.ErrLocked {
+                return nil, nativehost.ErrLocked
+            }
+
+            if err != nil {
+                return nil, err
+            }
+
+            return client, nil
+        },
+    }
+
+    if err = host.Serve(os.Stdin, os.Stdout); err != nil {
+        fail(err)
+    }
+}
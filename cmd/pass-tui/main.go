@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command pass-tui is a terminal front end to Pass, for SSH sessions
+// and other places without the desktop app.
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "golang.org/x/term"
+    "os"
+    "pass/logger"
+    "pass/tui"
+    "pass/util"
+    "pass/vault"
+)
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, "pass-tui:", err)
+    os.Exit(1)
+}
+
+func main() {
+    configPath := flag.String("config", "", "configuration file (default $PASS_CONFIG)")
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: pass-tui [-config file]")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    // Anything logged would end up in the middle of the screen.
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelError)
+
+    if flag.NArg() != 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(*configPath))
+
+    if err != nil {
+        fail(err)
+    }
+
+    in := int(os.Stdin.Fd())
+    out := int(os.Stdout.Fd())
+
+    if !term.IsTerminal(in) || !term.IsTerminal(out) {
+        fail(errors.New("not a terminal"))
+    }
+
+    ui := tui.New(func(password string) (tui.Vault, error) {
+        client, err := vault.Connect(c, password)
+
+        if err != nil {
+            return nil, err
+        }
+
+        // Let the other tools use the session, if an agent runs.
+        vault.Share(c, client)
+        return client, nil
+    }, c.Matcher())
+
+    // The session is shared with the agent, so locking locks both.
+    ui.OnLock = func() {
+        vault.LockAgent(c)
+    }
+
+    if client, err := vault.Resume(c); err == nil {
+        ui.Unlock(client)
+    }
+
+    state, err := term.MakeRaw(in)
+
+    if err != nil {
+        fail(err)
+    }
+
+    err = ui.Run(os.Stdin, os.Stdout, func() (int, int) {
+        width, height, err := term.GetSize(out)
+
+        if err != nil {
+            return 80, 24
+        }
+
+        return width, height
+    })
+
+    term.Restore(in, state)
+
+    if err != nil {
+        fail(err)
+    }
+}
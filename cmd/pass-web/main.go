@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command pass-web serves Pass as a web page on localhost, for
+// platforms where the desktop app does not run. Open the link it
+// prints; it works once, in one browser.
+package main
+
+import (
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "pass/logger"
+    "pass/util"
+    "pass/vault"
+    "pass/webui"
+)
+
+func fail(err error) {
+    fmt.Fprintln(os.Stderr, "pass-web:", err)
+    os.Exit(1)
+}
+
+func main() {
+    configPath := flag.String("config", "", "configuration file (default $PASS_CONFIG)")
+    listen := flag.String("listen", "127.0.0.1:0", "loopback address to listen on")
+    flag.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: pass-web [-config file] [-listen address]")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelWarn)
+
+    if flag.NArg() != 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(*configPath))
+
+    if err != nil {
+        fail(err)
+    }
+
+    server := webui.New(func(password string) (webui.Vault, error) {
+        client, err := vault.Connect(c, password)
+
+        if err != nil {
+            return nil, err
+        }
+
+        // Let the command line tools use the session, if an agent runs.
+        if err = vault.Share(c, client); err != nil {
+            logger.Warn("Could not unlock the agent:", err)
+        }
+
+        return client, nil
+    }, c.Matcher())
+
+    // The session is shared with the agent, so locking locks both.
+    server.OnLock = func() {
+        if err := vault.LockAgent(c); err != nil {
+            logger.Warn("Could not lock the agent:", err)
+        }
+    }
+
+    if client, err := vault.Resume(c); err == nil {
+        server.Unlock(client)
+    }
+
+    listener, link, err := server.Listen(*listen)
+
+    if err != nil {
+        fail(err)
+    }
+
+    fmt.Println("Open", link)
+
+    if err = http.Serve(listener, server); err != nil {
+        fail(err)
+    }
+}
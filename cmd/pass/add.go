@@ -0,0 +1,219 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "golang.org/x/term"
+    "io/ioutil"
+    "os"
+    "pass/lock"
+    "pass/rest"
+    "pass/vault"
+    "strings"
+)
+
+// Length of generated passwords, as in the desktop app.
+const DefaultPasswordLength = 32
+
+func init() {
+    commands["add"] = command{
+        Usage: "add [-type type] [-username name] [-url url]... [-match mode] [-tag tag]... [-generate length] name",
+        Run:   runAdd,
+    }
+
+    commands["generate"] = command{
+        Usage: "generate [-length length] [name]",
+        Run:   runGenerate,
+    }
+}
+
+// Reads a secret: twice from the terminal, or else from standard
+// input, where all of it is the text of a note, and the first line
+// anything else.
+func readSecret(name string, multiline bool) (string, error) {
+    if !term.IsTerminal(int(os.Stdin.Fd())) {
+        if multiline {
+            data, err := ioutil.ReadAll(os.Stdin)
+            return string(data), err
+        }
+
+        line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+        if err != nil && line == "" {
+            return "", err
+        }
+
+        return strings.TrimRight(line, "\r\n"), nil
+    }
+
+    if multiline {
+        return "", errors.New("pipe the text of the note to pass, or use edit")
+    }
+
+    secret, err := vault.ReadPassword("Secret of " + name + ": ")
+
+    if err != nil {
+        return "", err
+    }
+
+    again, err := vault.ReadPassword("Again: ")
+
+    if err != nil {
+        return "", err
+    }
+
+    if secret != again {
+        return "", errors.New("the secrets do not match")
+    }
+
+    return secret, nil
+}
+
+// Adds an entry. The type specific parts of other entries than
+// accounts, OTP entries and notes are added with edit.
+func runAdd(args []string) int {
+    var urls, tags listFlag
+
+    flags := flag.NewFlagSet("add", flag.ContinueOnError)
+    id := flags.String("type", rest.TypeAccount, "type of the entry")
+    username := flags.String("username", "", "username")
+    length := flags.Int("generate", 0, "generate a password of this length")
+    match := flags.String("match", "", "how the URLs are matched: domain, host, startswith or regex")
+    flags.Var(&urls, "url", "URL of the entry")
+    flags.Var(&tags, "tag", "tag of the entry")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 1 {
+        return usageError("add")
+    }
+
+    name := flags.Arg(0)
+
+    if _, ok := rest.LookupType(*id); !ok {
+        return fail(rest.ErrUnknownType)
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    // A new entry must not replace an existing one.
+    if _, err = findEntry(client, name, *id); err == nil {
+        return fail(rest.ErrExists)
+    } else if err != rest.ErrNotFound {
+        return fail(err)
+    }
+
+    d := &rest.DecodedEntry{
+        Name:     &rest.Name{Text: name},
+        Type:     *id,
+        Username: *username,
+        URLs:     urls,
+        Match:    *match,
+        Tags:     tags,
+    }
+
+    var secret string
+
+    if *length > 0 {
+        secret = lock.EntropyAlphabet(*length)
+    } else if secret, err = readSecret(name, *id == rest.TypeNote); err != nil {
+        return fail(err)
+    }
+
+    if *id == rest.TypeNote {
+        d.Payload = &rest.Note{Text: secret}
+    } else {
+        d.SetPassword(secret)
+    }
+
+    if err = client.VaultWriteSecret(d); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+// Generates a password. Given a name, the password of the account is
+// set to it, and the account is added if it does not exist.
+func runGenerate(args []string) int {
+    flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+    length := flags.Int("length", DefaultPasswordLength, "length of the password")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() > 1 || *length <= 0 {
+        return usageError("generate")
+    }
+
+    password := lock.EntropyAlphabet(*length)
+
+    if flags.NArg() == 1 {
+        name := flags.Arg(0)
+        client, err := vault.Open(configPath)
+
+        if err != nil {
+            return fail(err)
+        }
+
+        d, err := findEntry(client, name, rest.TypeAccount)
+
+        if err == rest.ErrNotFound {
+            d = &rest.DecodedEntry{
+                Name: &rest.Name{Text: name},
+                Type: rest.TypeAccount,
+            }
+        } else if err != nil {
+            return fail(err)
+        }
+
+        d.SetPassword(password)
+
+        if err = client.VaultWriteSecret(d); err != nil {
+            return fail(err)
+        }
+    }
+
+    return output(map[string]string{"password": password}, func() {
+        fmt.Println(password)
+    })
+}
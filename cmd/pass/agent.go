@@ -0,0 +1,208 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "pass/agent"
+    "pass/logger"
+    "pass/util"
+    "pass/vault"
+    "syscall"
+    "time"
+)
+
+func init() {
+    commands["agent"] = command{
+        Usage: "agent [-timeout duration]",
+        Run:   runAgent,
+    }
+
+    commands["unlock"] = command{
+        Usage: "unlock",
+        Run:   runUnlock,
+    }
+
+    commands["lock"] = command{
+        Usage: "lock",
+        Run:   runLock,
+    }
+
+    commands["status"] = command{
+        Usage: "status",
+        Run:   runStatus,
+    }
+}
+
+// The timeout of the agent: the flag if given, as configured, or the
+// default.
+func agentTimeout(c util.Configuration, flagValue time.Duration) (time.Duration, error) {
+    if flagValue >= 0 {
+        return flagValue, nil
+    }
+
+    if c.Agent.Timeout == "" {
+        return agent.DefaultTimeout, nil
+    }
+
+    return time.ParseDuration(c.Agent.Timeout)
+}
+
+// Runs the agent in the foreground until interrupted. It starts locked
+// and is unlocked by the first command asking for the password.
+func runAgent(args []string) int {
+    flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+    timeout := flags.Duration("timeout", -1, "lock after being idle for this long, never if 0")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 0 {
+        return usageError("agent")
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(configPath))
+
+    if err != nil {
+        return fail(err)
+    }
+
+    t, err := agentTimeout(c, *timeout)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    a, err := agent.New(c, t)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    a.OnLock = func() {
+        logger.Info("Agent locked.")
+    }
+
+    path := agent.SocketPath(c.Agent.Socket)
+
+    if err = a.Listen(path); err != nil {
+        return fail(err)
+    }
+
+    logger.SetLevel(logger.LevelInfo)
+    logger.Info("Agent listening on", path)
+
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+    <-signals
+
+    if err = a.Close(); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+// Asks for the password and unlocks the agent with it.
+func runUnlock(args []string) int {
+    if len(args) != 0 {
+        return usageError("unlock")
+    }
+
+    if _, err := vault.Open(configPath); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+func runLock(args []string) int {
+    if len(args) != 0 {
+        return usageError("lock")
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(configPath))
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if err = vault.LockAgent(c); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+func runStatus(args []string) int {
+    if len(args) != 0 {
+        return usageError("status")
+    }
+
+    c, err := util.LoadConfiguration(vault.ConfigPath(configPath))
+
+    if err != nil {
+        return fail(err)
+    }
+
+    a, err := agent.Dial(agent.SocketPath(c.Agent.Socket))
+
+    if err != nil {
+        return fail(err)
+    }
+
+    s, err := a.Status()
+
+    if err != nil {
+        return fail(err)
+    }
+
+    result := map[string]interface{}{"locked": s.Locked}
+
+    if !s.Expires.IsZero() {
+        result["expires"] = s.Expires
+    }
+
+    return output(result, func() {
+        switch {
+        case s.Locked:
+            fmt.Println("locked")
+        case s.Expires.IsZero():
+            fmt.Println("unlocked")
+        default:
+            fmt.Println("unlocked until", s.Expires.Format("15:04:05"))
+        }
+    })
+}
@@ -0,0 +1,178 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "flag"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "pass/vault"
+    "path/filepath"
+    "strings"
+)
+
+// Used if $EDITOR is not set.
+const DefaultEditor = "vi"
+
+func init() {
+    commands["edit"] = command{
+        Usage: "edit [-type type] name",
+        Run:   runEdit,
+    }
+}
+
+// Where the entry is edited. Memory backed file systems are preferred,
+// so that the entry is not written to disk.
+func editDir() string {
+    for _, dir := range []string{"/dev/shm", os.Getenv("XDG_RUNTIME_DIR")} {
+        if info, err := os.Stat(dir); dir != "" && err == nil && info.IsDir() {
+            return dir
+        }
+    }
+
+    return os.TempDir()
+}
+
+// Opens the editor on data and returns the edited data. The file is
+// overwritten and removed afterwards.
+func editData(data []byte) ([]byte, error) {
+    dir, err := ioutil.TempDir(editDir(), "pass-")
+
+    if err != nil {
+        return nil, err
+    }
+
+    defer os.RemoveAll(dir)
+
+    file := filepath.Join(dir, "entry.json")
+
+    if err = ioutil.WriteFile(file, data, 0600); err != nil {
+        return nil, err
+    }
+
+    editor := strings.Fields(os.Getenv("EDITOR"))
+
+    if len(editor) == 0 {
+        editor = []string{DefaultEditor}
+    }
+
+    cmd := exec.Command(editor[0], append(editor[1:], file)...)
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+
+    err = cmd.Run()
+    edited, readErr := ioutil.ReadFile(file)
+
+    // Overwrite the file before it is removed.
+    ioutil.WriteFile(file, make([]byte, len(edited)), 0600)
+
+    if err != nil {
+        return nil, errors.New("editor failed: " + err.Error())
+    }
+
+    return edited, readErr
+}
+
+// Edits an entry as JSON in $EDITOR.
+func runEdit(args []string) int {
+    flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+    id := flags.String("type", "", "type of the entry, if the name is not unique")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 1 {
+        return usageError("edit")
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), *id)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    e, err := toJSON(d)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    // The name and type are changed with mv, and the timestamps are
+    // kept by Pass.
+    e.Name, e.Type = "", ""
+    e.Created, e.Modified, e.LastUsed = nil, nil, nil
+
+    data, err := json.MarshalIndent(e, "", "    ")
+
+    if err != nil {
+        return fail(err)
+    }
+
+    edited, err := editData(append(data, '\n'))
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if bytes.Equal(bytes.TrimSpace(edited), data) {
+        return ExitOK
+    }
+
+    e = &entryJSON{}
+    decoder := json.NewDecoder(bytes.NewReader(edited))
+    decoder.DisallowUnknownFields()
+
+    if err = decoder.Decode(e); err != nil {
+        return fail(errors.New("entry not saved: " + err.Error()))
+    }
+
+    if err = fromJSON(e, d); err != nil {
+        return fail(err)
+    }
+
+    if err = client.VaultWriteSecret(d); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "pass/rest"
+    "strings"
+    "time"
+)
+
+// An entry as printed with -json and edited with edit.
+type entryJSON struct {
+    Name     string       `json:"name,omitempty"`
+    Type     string       `json:"type,omitempty"`
+    Username string       `json:"username"`
+    Password string       `json:"password"`
+    URLs     []string     `json:"urls"`
+    Match    string       `json:"match,omitempty"`
+    Notes    string       `json:"notes"`
+    Fields   []rest.Field `json:"fields"`
+    Tags     []string     `json:"tags"`
+    Created  *time.Time   `json:"created,omitempty"`
+    Modified *time.Time   `json:"modified,omitempty"`
+    LastUsed *time.Time   `json:"lastused,omitempty"`
+
+    // The type specific payload, e.g., a card.
+    Data json.RawMessage `json:"data,omitempty"`
+}
+
+func timePointer(t time.Time) *time.Time {
+    if t.IsZero() {
+        return nil
+    }
+    return &t
+}
+
+func toJSON(d *rest.DecodedEntry) (*entryJSON, error) {
+    e := &entryJSON{
+        Name:     d.Name.Text,
+        Type:     rest.GetType(d.Type).ID,
+        Username: d.Username,
+        Password: d.PasswordText(),
+        URLs:     d.URLs,
+        Match:    d.Match,
+        Notes:    d.Notes,
+        Fields:   d.Fields,
+        Tags:     d.Tags,
+        Created:  timePointer(d.Created),
+        Modified: timePointer(d.Modified),
+        LastUsed: timePointer(d.LastUsed),
+    }
+
+    if t, ok := rest.LookupType(d.Type); ok && t.Codec != nil && d.Payload != nil {
+        data, err := t.Codec.Encode(d.Payload)
+
+        if err != nil {
+            return nil, err
+        }
+
+        e.Data = data
+    }
+
+    return e, nil
+}
+
+// Takes over the editable parts of e, i.e., everything but the name,
+// type and timestamps.
+func fromJSON(e *entryJSON, d *rest.DecodedEntry) error {
+    d.Username = e.Username
+    d.SetPassword(e.Password)
+    d.URLs = e.URLs
+    d.Match = e.Match
+    d.Notes = e.Notes
+    d.Fields = e.Fields
+    d.Tags = e.Tags
+
+    if t, ok := rest.LookupType(d.Type); ok && t.Codec != nil && len(e.Data) > 0 {
+        payload, err := t.Codec.Decode(e.Data)
+
+        if err != nil {
+            return err
+        }
+
+        d.Payload = payload
+    }
+
+    return nil
+}
+
+// Finds an entry by its full name. The type may be given, or as a
+// suffix of the name, e.g., github,1; otherwise the name must be
+// unique.
+func findEntry(client *rest.Client, name string, id string) (*rest.DecodedEntry, error) {
+    if id == "" {
+        name, id = rest.SplitTypeSuffix(name)
+    }
+
+    if id != "" {
+        return client.VaultFindSecret(name, id)
+    }
+
+    names, err := client.VaultListSecrets()
+
+    if err != nil {
+        return nil, err
+    }
+
+    var found []rest.Name
+
+    for _, n := range *names {
+        if n.Text == name {
+            found = append(found, n)
+        }
+    }
+
+    switch len(found) {
+    case 0:
+        return nil, rest.ErrNotFound
+    case 1:
+        return client.VaultReadSecret(&found[0])
+    }
+
+    types := []string{}
+
+    for _, n := range found {
+        types = append(types, rest.GetType(n.Type).ID)
+    }
+
+    return nil, errors.New("there are several entries named " + name +
+        ", choose one with -type " + strings.Join(types, "|"))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "encoding/json"
+    "pass/rest"
+    "testing"
+)
+
+func TestEntryJSONRoundTrip(t *testing.T) {
+    d := &rest.DecodedEntry{
+        Name:     &rest.Name{Text: "visa"},
+        Type:     rest.TypeCard,
+        Username: "carl",
+        Tags:     []string{"bank"},
+        Payload:  &rest.Card{Number: "4111111111111111", CVV: "123"},
+    }
+
+    e, err := toJSON(d)
+
+    if err != nil || e.Type != rest.TypeCard || e.Created != nil {
+        t.Fatalf("Unexpected entry %v: %v", e, err)
+    }
+
+    data, _ := json.Marshal(e)
+    edited := &entryJSON{}
+    json.Unmarshal(data, edited)
+    edited.Data = json.RawMessage(`{"number":"5555555555554444","cvv":"321"}`)
+    edited.Username = "carl2"
+
+    if err = fromJSON(edited, d); err != nil {
+        t.Fatalf("Could not take over edits: %s", err)
+    }
+
+    card := d.Payload.(*rest.Card)
+
+    if d.Username != "carl2" || card.Number != "5555555555554444" || card.Brand() != rest.BrandMastercard {
+        t.Errorf("Edits were not taken over")
+    }
+
+    if d.Name.Text != "visa" || d.Type != rest.TypeCard {
+        t.Errorf("Name or type changed")
+    }
+}
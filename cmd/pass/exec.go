@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "pass/inject"
+    "pass/vault"
+)
+
+func init() {
+    commands["exec"] = command{
+        Usage: "exec [-env VARIABLE=entry[:field]]... [-tag tag]... command [arguments]",
+        Run:   runExec,
+    }
+}
+
+// Runs a command with secrets in its environment. The exit code is
+// that of the command.
+func runExec(args []string) int {
+    var env, tags listFlag
+
+    flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+    flags.Var(&env, "env", "set VARIABLE to a field of an entry, by default the password")
+    flags.Var(&tags, "tag", "set variables for all entries with the tag")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() == 0 || len(env)+len(tags) == 0 {
+        return usageError("exec")
+    }
+
+    mappings := []inject.Mapping{}
+
+    for _, e := range env {
+        m, err := inject.ParseMapping(e)
+
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "pass:", e+":", err)
+            return ExitUsage
+        }
+
+        mappings = append(mappings, m)
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    vars, err := inject.Resolve(client, mappings, tags)
+
+    // The child may run for long, and only needs the secrets it gets
+    // in its environment.
+    client.Wipe()
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if len(vars) == 0 {
+        return fail(errors.New("no secrets found"))
+    }
+
+    code, err := inject.Run(flags.Arg(0), flags.Args()[1:],
+        inject.Environ(os.Environ(), vars))
+
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "pass:", err)
+    }
+
+    return code
+}
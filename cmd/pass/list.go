@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "pass/rest"
+    "pass/util"
+    "pass/vault"
+    "sort"
+    "strings"
+    "text/tabwriter"
+)
+
+func init() {
+    commands["list"] = command{
+        Usage: "list [-type type] [-tag tag] [-url url] [query]",
+        Run:   runList,
+    }
+}
+
+type listItem struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+}
+
+// Lists the entries whose names contain the query, or, for a URL, the
+// entries for it, best match first.
+func runList(args []string) int {
+    flags := flag.NewFlagSet("list", flag.ContinueOnError)
+    id := flags.String("type", "", "only list entries of this type")
+    tag := flags.String("tag", "", "only list entries with this tag")
+    page := flags.String("url", "", "only list entries for this URL")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() > 1 {
+        return usageError("list")
+    }
+
+    query := strings.ToLower(flags.Arg(0))
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    var names []rest.Name
+
+    if *tag != "" || *page != "" {
+        // Tags and URLs are encrypted, so the entries must be read.
+        var entries []*rest.DecodedEntry
+
+        if *tag != "" {
+            entries, err = client.VaultFindTagged(*tag)
+        } else {
+            entries, err = client.VaultReadAll()
+        }
+
+        if err != nil {
+            return fail(err)
+        }
+
+        if *page != "" {
+            if entries, err = rankByURL(entries, *page); err != nil {
+                return fail(err)
+            }
+        }
+
+        for _, d := range entries {
+            names = append(names, *d.Name)
+        }
+    } else {
+        list, err := client.VaultListSecrets()
+
+        if err != nil {
+            return fail(err)
+        }
+
+        names = *list
+    }
+
+    items := []listItem{}
+
+    for _, n := range names {
+        t := rest.GetType(n.Type).ID
+
+        if *id != "" && t != *id {
+            continue
+        }
+
+        if !strings.Contains(strings.ToLower(n.Text), query) {
+            continue
+        }
+
+        items = append(items, listItem{Name: n.Text, Type: t})
+    }
+
+    // Entries for a URL are already ranked.
+    if *page == "" {
+        sort.Slice(items, func(i, j int) bool {
+            return items[i].Name < items[j].Name
+        })
+    }
+
+    return output(items, func() {
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+        for _, item := range items {
+            fmt.Fprintf(w, "%s\t%s\n", item.Name, item.Type)
+        }
+
+        w.Flush()
+    })
+}
+
+// Ranks the entries by how well they match the URL, with the configured
+// equivalent domains. Equally good matches are sorted by name.
+func rankByURL(entries []*rest.DecodedEntry, page string) ([]*rest.DecodedEntry, error) {
+    c, err := util.LoadConfiguration(vault.ConfigPath(configPath))
+
+    if err != nil {
+        return nil, err
+    }
+
+    sort.SliceStable(entries, func(i, j int) bool {
+        return entries[i].Name.Text < entries[j].Name.Text
+    })
+
+    return rest.RankByURL(entries, page, c.Matcher())
+}
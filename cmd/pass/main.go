@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command pass uses the vault from the command line.
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "pass/logger"
+    "pass/rest"
+    "pass/vault"
+    "sort"
+    "strings"
+)
+
+// Exit codes.
+const (
+    ExitOK       = 0
+    ExitError    = 1
+    ExitUsage    = 2
+    ExitNotFound = 3
+    ExitPassword = 4
+)
+
+// A command is a subcommand. Run returns the exit code.
+type command struct {
+    Usage string
+    Run   func(args []string) int
+}
+
+var commands = map[string]command{}
+
+var (
+    configPath string
+
+    // Print results as JSON, for scripts.
+    jsonOutput bool
+)
+
+func usage() {
+    fmt.Fprintln(os.Stderr, "usage: pass [-config file] [-json] <command> [arguments]")
+    fmt.Fprintln(os.Stderr)
+    fmt.Fprintln(os.Stderr, "commands:")
+
+    for _, name := range sortedCommands() {
+        fmt.Fprintf(os.Stderr, "  %s\n", commands[name].Usage)
+    }
+}
+
+func sortedCommands() []string {
+    names := make([]string, 0, len(commands))
+
+    for name := range commands {
+        names = append(names, name)
+    }
+
+    sort.Strings(names)
+    return names
+}
+
+// A flag which may be given several times.
+type listFlag []string
+
+func (l *listFlag) String() string {
+    return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+    *l = append(*l, value)
+    return nil
+}
+
+// Reports an error and returns the exit code for it.
+func fail(err error) int {
+    fmt.Fprintln(os.Stderr, "pass:", err)
+
+    switch err {
+    case rest.ErrNotFound:
+        return ExitNotFound
+    case vault.ErrPassword:
+        return ExitPassword
+    }
+
+    return ExitError
+}
+
+// Reports wrong usage of a command.
+func usageError(name string) int {
+    fmt.Fprintln(os.Stderr, "usage: pass", commands[name].Usage)
+    return ExitUsage
+}
+
+// Prints a result, as JSON or as text.
+func output(v interface{}, text func()) int {
+    if !jsonOutput {
+        text()
+        return ExitOK
+    }
+
+    encoder := json.NewEncoder(os.Stdout)
+    encoder.SetIndent("", "  ")
+
+    if err := encoder.Encode(v); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+func main() {
+    flag.StringVar(&configPath, "config", "", "configuration file (default $PASS_CONFIG)")
+    flag.BoolVar(&jsonOutput, "json", false, "print results as JSON")
+    flag.Usage = usage
+    flag.Parse()
+
+    // Logs go to standard error, as standard output is for results.
+    logger.SetOutput(os.Stderr)
+    logger.SetLevel(logger.LevelWarn)
+
+    if flag.NArg() == 0 {
+        usage()
+        os.Exit(ExitUsage)
+    }
+
+    cmd, ok := commands[flag.Arg(0)]
+
+    if !ok {
+        fmt.Fprintln(os.Stderr, "pass: unknown command", flag.Arg(0))
+        usage()
+        os.Exit(ExitUsage)
+    }
+
+    os.Exit(cmd.Run(flag.Args()[1:]))
+}
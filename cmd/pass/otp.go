@@ -0,0 +1,85 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "pass/otp"
+    "pass/rest"
+    "pass/vault"
+    "time"
+)
+
+// OTP codes change every period.
+const OTPPeriod = 30
+
+func init() {
+    commands["otp"] = command{
+        Usage: "otp name",
+        Run:   runOTP,
+    }
+}
+
+// Prints the current code of an OTP entry.
+func runOTP(args []string) int {
+    flags := flag.NewFlagSet("otp", flag.ContinueOnError)
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 1 {
+        return usageError("otp")
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), rest.TypeOTP)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    code := otp.ComputeOTPCode(d.Password.Bytes())
+    remaining := OTPPeriod - int(time.Now().Unix()%OTPPeriod)
+
+    return output(map[string]interface{}{
+        "code":      code,
+        "remaining": remaining,
+    }, func() {
+        fmt.Println(code)
+    })
+}
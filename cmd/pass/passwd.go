@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "pass/vault"
+)
+
+var errPasswordMismatch = errors.New("passwords do not match")
+
+func init() {
+    commands["passwd"] = command{
+        Usage: "passwd [-reencrypt] [-abort]",
+        Run:   runPasswd,
+    }
+}
+
+// Changes the master password. With -reencrypt, or if the vault has no
+// data key yet, every entry is re-encrypted with a new data key. An
+// interrupted change is resumed by running it again, or undone with
+// -abort.
+func runPasswd(args []string) int {
+    flags := flag.NewFlagSet("passwd", flag.ContinueOnError)
+    abort := flags.Bool("abort", false, "undo an interrupted change")
+    reencrypt := flags.Bool("reencrypt", false, "re-encrypt all entries with a new data key")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 0 {
+        return usageError("passwd")
+    }
+
+    oldPassword, err := vault.ReadPassword("Current password: ")
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if *abort {
+        if err = vault.AbortPasswordChange(configPath, oldPassword); err != nil {
+            return fail(err)
+        }
+
+        return ExitOK
+    }
+
+    newPassword, err := vault.ReadPassword("New password: ")
+
+    if err != nil {
+        return fail(err)
+    }
+
+    repeated, err := vault.ReadPassword("Repeat new password: ")
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if newPassword != repeated {
+        return fail(errPasswordMismatch)
+    }
+
+    n, err := vault.ChangePassword(configPath, oldPassword, newPassword, *reencrypt)
+
+    if err != nil {
+        code := fail(err)
+
+        if err == vault.ErrNewPassword {
+            fmt.Fprintln(os.Stderr, "pass: run passwd -abort to start over")
+        }
+
+        return code
+    }
+
+    return output(map[string]int{"reencrypted": n}, func() {
+        if n > 0 {
+            fmt.Printf("Password changed, %d entries re-encrypted.\n", n)
+        } else {
+            fmt.Println("Password changed.")
+        }
+    })
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "flag"
+    "pass/rest"
+    "pass/vault"
+)
+
+func init() {
+    commands["rm"] = command{
+        Usage: "rm [-type type] name",
+        Run:   runRemove,
+    }
+
+    commands["mv"] = command{
+        Usage: "mv [-type type] name newname",
+        Run:   runMove,
+    }
+}
+
+// Removes an entry.
+func runRemove(args []string) int {
+    flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+    id := flags.String("type", "", "type of the entry, if the name is not unique")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 1 {
+        return usageError("rm")
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), *id)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if err = client.VaultDeleteSecret(d); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
+
+// Renames an entry, possibly into another folder.
+func runMove(args []string) int {
+    flags := flag.NewFlagSet("mv", flag.ContinueOnError)
+    id := flags.String("type", "", "type of the entry, if the name is not unique")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 2 {
+        return usageError("mv")
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), *id)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    // Without a blind index, two entries may have the same name, but
+    // that is hardly what one wants.
+    if _, err = findEntry(client, flags.Arg(1), rest.GetType(d.Type).ID); err == nil {
+        return fail(rest.ErrExists)
+    }
+
+    if err = client.VaultRenameSecret(d, flags.Arg(1)); err != nil {
+        return fail(err)
+    }
+
+    return ExitOK
+}
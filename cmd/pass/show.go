@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "pass/vault"
+    "strings"
+)
+
+func init() {
+    commands["show"] = command{
+        Usage: "show [-type type] [-field field] name",
+        Run:   runShow,
+    }
+}
+
+// Shows an entry, or a single field of it.
+func runShow(args []string) int {
+    flags := flag.NewFlagSet("show", flag.ContinueOnError)
+    id := flags.String("type", "", "type of the entry, if the name is not unique")
+    field := flags.String("field", "", "only show this field, e.g., password")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 1 {
+        return usageError("show")
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), *id)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    if *field != "" {
+        value, err := d.Value(*field)
+
+        if err != nil {
+            return fail(err)
+        }
+
+        return output(map[string]string{*field: value}, func() {
+            fmt.Println(value)
+        })
+    }
+
+    e, err := toJSON(d)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    return output(e, func() {
+        printEntry(e)
+    })
+}
+
+func printEntry(e *entryJSON) {
+    line := func(key string, value string) {
+        if value != "" {
+            fmt.Printf("%s: %s\n", key, value)
+        }
+    }
+
+    line("name", e.Name)
+    line("type", e.Type)
+    line("username", e.Username)
+    line("password", e.Password)
+
+    for _, u := range e.URLs {
+        line("url", u)
+    }
+
+    line("match", e.Match)
+
+    line("tags", strings.Join(e.Tags, ", "))
+
+    for _, f := range e.Fields {
+        line(f.Name, f.Value)
+    }
+
+    if len(e.Data) > 0 {
+        line("data", string(e.Data))
+    }
+
+    if e.Notes != "" {
+        fmt.Println("notes:")
+
+        for _, l := range strings.Split(e.Notes, "\n") {
+            fmt.Println("  " + l)
+        }
+    }
+}
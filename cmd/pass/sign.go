@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "encoding/base64"
+    "errors"
+    "flag"
+    "fmt"
+    "golang.org/x/crypto/ed25519"
+    "io/ioutil"
+    "pass/rest"
+    "pass/vault"
+)
+
+// As written by the desktop app.
+const SignatureFileExtension = ".signature"
+
+func init() {
+    commands["sign"] = command{
+        Usage: "sign [-o file] name file",
+        Run:   runSign,
+    }
+}
+
+// Signs a file with the Ed25519 key of a signing entry. The signature
+// is written next to the file, like in the desktop app.
+func runSign(args []string) int {
+    flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+    out := flags.String("o", "", "signature file (default the file with "+SignatureFileExtension+")")
+
+    if err := flags.Parse(args); err != nil {
+        return ExitUsage
+    }
+
+    if flags.NArg() != 2 {
+        return usageError("sign")
+    }
+
+    file := flags.Arg(1)
+
+    if *out == "" {
+        *out = file + SignatureFileExtension
+    }
+
+    data, err := ioutil.ReadFile(file)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    client, err := vault.Open(configPath)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    d, err := findEntry(client, flags.Arg(0), rest.TypeSign)
+
+    if err != nil {
+        return fail(err)
+    }
+
+    keyPair, ok := d.Payload.(*rest.KeyPair)
+
+    if !ok || len(keyPair.Priv) != ed25519.PrivateKeySize {
+        return fail(errors.New("no key has been generated for " + flags.Arg(0)))
+    }
+
+    signature := ed25519.Sign(ed25519.PrivateKey(keyPair.Priv), data)
+
+    if err = ioutil.WriteFile(*out, signature, 0644); err != nil {
+        return fail(err)
+    }
+
+    return output(map[string]string{
+        "signature": *out,
+        "publickey": base64.StdEncoding.EncodeToString(keyPair.Pub),
+    }, func() {
+        fmt.Println(*out)
+    })
+}
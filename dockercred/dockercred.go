@@ -0,0 +1,331 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package dockercred implements the protocol of docker credential
+// helpers, so that docker login keeps registry credentials in Pass
+// instead of ~/.docker/config.json. Credentials are accounts tagged
+// docker, with the server URL as their URL. Registries which docker
+// names by their host, e.g., ghcr.io, are stored with an https URL and
+// the host in the custom field server.
+//
+// See https://github.com/docker/docker-credential-helpers for the
+// protocol.
+package dockercred
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net/url"
+    "pass/rest"
+    "strings"
+)
+
+// Actions of the protocol.
+const (
+    ActionStore   = "store"
+    ActionGet     = "get"
+    ActionErase   = "erase"
+    ActionList    = "list"
+    ActionVersion = "version"
+)
+
+const (
+    // DefaultFolder holds the credentials stored by docker.
+    DefaultFolder = "Docker"
+
+    // Tag of registry credentials.
+    Tag = "docker"
+
+    // Custom field holding the server as docker names it, if that is
+    // no URL.
+    ServerField = "server"
+)
+
+var (
+    // The message is part of the protocol; docker checks for it.
+    ErrCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+    ErrMissingServerURL = errors.New("no credentials server URL")
+    ErrMissingUsername  = errors.New("no credentials username")
+    ErrAction           = errors.New("unknown credential action")
+)
+
+// A Store holds the entries; it is implemented by rest.Client.
+type Store interface {
+    VaultReadAll() ([]*rest.DecodedEntry, error)
+    VaultWriteSecret(data *rest.DecodedEntry) error
+    VaultDeleteSecret(data *rest.DecodedEntry) error
+}
+
+// Credentials as exchanged with docker.
+type Credentials struct {
+    ServerURL string
+    Username  string
+    Secret    string
+}
+
+// Helper answers docker.
+type Helper struct {
+    Vault Store
+
+    // Folder for the credentials stored by docker.
+    Folder string
+}
+
+// Registry credentials, by server URL.
+func (h *Helper) entries() (map[string]*rest.DecodedEntry, error) {
+    all, err := h.Vault.VaultReadAll()
+
+    if err != nil {
+        return nil, err
+    }
+
+    entries := map[string]*rest.DecodedEntry{}
+
+    for _, d := range all {
+        if d.HasTag(Tag) && len(d.URLs) > 0 {
+            entries[serverOf(d)] = d
+        }
+    }
+
+    return entries, nil
+}
+
+// The server of an entry, as docker names it.
+func serverOf(d *rest.DecodedEntry) string {
+    for _, f := range d.Fields {
+        if f.Name == ServerField && f.Value != "" {
+            return f.Value
+        }
+    }
+
+    return d.URLs[0]
+}
+
+// The URL stored for a server. Docker names registries other than
+// Docker Hub by their host, e.g., ghcr.io or localhost:5000, which is
+// no valid URL.
+func storedURL(server string) string {
+    if u, err := url.Parse(server); err == nil && u.Scheme != "" && u.Host != "" {
+        return server
+    }
+
+    return "https://" + server
+}
+
+// The name of a new entry, e.g., Docker/registry.example.com for
+// https://registry.example.com/v2/.
+func (h *Helper) entryName(serverURL string) string {
+    folder := h.Folder
+
+    if folder == "" {
+        folder = DefaultFolder
+    }
+
+    name := serverURL
+
+    if u, err := url.Parse(storedURL(serverURL)); err == nil && u.Host != "" {
+        name = u.Host
+    }
+
+    return rest.JoinPath(folder, strings.Trim(name, "/"))
+}
+
+// Add stores credentials, replacing those for the same server.
+func (h *Helper) Add(c *Credentials) error {
+    if c.ServerURL == "" {
+        return ErrMissingServerURL
+    }
+
+    if c.Username == "" {
+        return ErrMissingUsername
+    }
+
+    entries, err := h.entries()
+
+    if err != nil {
+        return err
+    }
+
+    d, ok := entries[c.ServerURL]
+
+    if !ok {
+        d = &rest.DecodedEntry{
+            Name: &rest.Name{Text: h.entryName(c.ServerURL)},
+            Type: rest.TypeAccount,
+            URLs: []string{storedURL(c.ServerURL)},
+            Tags: []string{Tag},
+        }
+
+        if d.URLs[0] != c.ServerURL {
+            d.Fields = []rest.Field{{Name: ServerField, Type: rest.FieldText, Value: c.ServerURL}}
+        }
+    } else if d.Username == c.Username && d.HasPassword(c.Secret) {
+        return nil
+    }
+
+    d.Username = c.Username
+    d.SetPassword(c.Secret)
+
+    return h.Vault.VaultWriteSecret(d)
+}
+
+// Get returns the username and secret for a server.
+func (h *Helper) Get(serverURL string) (string, string, error) {
+    entries, err := h.entries()
+
+    if err != nil {
+        return "", "", err
+    }
+
+    d, ok := entries[serverURL]
+
+    if !ok {
+        return "", "", ErrCredentialsNotFound
+    }
+
+    return d.Username, d.PasswordText(), nil
+}
+
+// Delete removes the credentials for a server.
+func (h *Helper) Delete(serverURL string) error {
+    entries, err := h.entries()
+
+    if err != nil {
+        return err
+    }
+
+    d, ok := entries[serverURL]
+
+    if !ok {
+        return ErrCredentialsNotFound
+    }
+
+    return h.Vault.VaultDeleteSecret(d)
+}
+
+// List returns the usernames by server URL.
+func (h *Helper) List() (map[string]string, error) {
+    entries, err := h.entries()
+
+    if err != nil {
+        return nil, err
+    }
+
+    list := map[string]string{}
+
+    for serverURL, d := range entries {
+        list[serverURL] = d.Username
+    }
+
+    return list, nil
+}
+
+// Reads the server URL, which docker sends as plain text.
+func readServerURL(in io.Reader) (string, error) {
+    data, err := ioutil.ReadAll(in)
+
+    if err != nil {
+        return "", err
+    }
+
+    serverURL := strings.TrimSpace(string(data))
+
+    if serverURL == "" {
+        return "", ErrMissingServerURL
+    }
+
+    return serverURL, nil
+}
+
+// Serve performs an action, reading its input from in and writing the
+// answer to out.
+func (h *Helper) Serve(action string, in io.Reader, out io.Writer) error {
+    switch action {
+    case ActionStore:
+        c := &Credentials{}
+
+        if err := json.NewDecoder(in).Decode(c); err != nil {
+            return err
+        }
+
+        return h.Add(c)
+    case ActionGet:
+        serverURL, err := readServerURL(in)
+
+        if err != nil {
+            return err
+        }
+
+        username, secret, err := h.Get(serverURL)
+
+        if err != nil {
+            return err
+        }
+
+        return writeJSON(out, &Credentials{
+            ServerURL: serverURL,
+            Username:  username,
+            Secret:    secret,
+        })
+    case ActionErase:
+        serverURL, err := readServerURL(in)
+
+        if err != nil {
+            return err
+        }
+
+        return h.Delete(serverURL)
+    case ActionList:
+        list, err := h.List()
+
+        if err != nil {
+            return err
+        }
+
+        return writeJSON(out, list)
+    }
+
+    return ErrAction
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+    var buffer bytes.Buffer
+
+    if err := json.NewEncoder(&buffer).Encode(v); err != nil {
+        return err
+    }
+
+    _, err := buffer.WriteTo(out)
+    return err
+}
@@ -0,0 +1,151 @@
+package dockercred
+
+import (
+    "bytes"
+    "encoding/json"
+    "pass/rest"
+    "strings"
+    "testing"
+)
+
+type fakeStore struct {
+    entries []*rest.DecodedEntry
+}
+
+func (f *fakeStore) VaultReadAll() ([]*rest.DecodedEntry, error) {
+    return append([]*rest.DecodedEntry{}, f.entries...), nil
+}
+
+func (f *fakeStore) VaultWriteSecret(d *rest.DecodedEntry) error {
+    // Like Vault, refuse what the user interface would.
+    if err := d.Validate(); err != nil {
+        return err
+    }
+
+    for _, e := range f.entries {
+        if e == d {
+            return nil
+        }
+    }
+    f.entries = append(f.entries, d)
+    return nil
+}
+
+func (f *fakeStore) VaultDeleteSecret(d *rest.DecodedEntry) error {
+    for i, e := range f.entries {
+        if e == d {
+            f.entries = append(f.entries[:i], f.entries[i+1:]...)
+        }
+    }
+    return nil
+}
+
+const server = "https://index.docker.io/v1/"
+
+func TestStoreGetListErase(t *testing.T) {
+    store := &fakeStore{entries: []*rest.DecodedEntry{
+        // An account with the same URL, but not from docker.
+        {Name: &rest.Name{Text: "hub"}, Username: "me", URLs: []string{server}},
+    }}
+    helper := Helper{Vault: store}
+
+    err := helper.Serve(ActionStore,
+        strings.NewReader(`{"ServerURL":"`+server+`","Username":"grocid","Secret":"banana"}`), nil)
+
+    if err != nil || len(store.entries) != 2 {
+        t.Fatalf("Store failed: %v", err)
+    }
+
+    if name := store.entries[1].Name.Text; name != "Docker/index.docker.io" {
+        t.Errorf("Unexpected name %s", name)
+    }
+
+    var out bytes.Buffer
+
+    if err = helper.Serve(ActionGet, strings.NewReader(server+"\n"), &out); err != nil {
+        t.Fatalf("Get failed: %s", err)
+    }
+
+    c := Credentials{}
+    json.Unmarshal(out.Bytes(), &c)
+
+    if c != (Credentials{server, "grocid", "banana"}) {
+        t.Errorf("Unexpected credentials %v", c)
+    }
+
+    out.Reset()
+    helper.Serve(ActionList, nil, &out)
+
+    if strings.TrimSpace(out.String()) != `{"`+server+`":"grocid"}` {
+        t.Errorf("Unexpected list %s", out.String())
+    }
+
+    // Storing again replaces the credentials.
+    helper.Serve(ActionStore,
+        strings.NewReader(`{"ServerURL":"`+server+`","Username":"grocid","Secret":"apple"}`), nil)
+
+    if len(store.entries) != 2 || !store.entries[1].HasPassword("apple") {
+        t.Errorf("Credentials were not replaced")
+    }
+
+    if err = helper.Serve(ActionErase, strings.NewReader(server), nil); err != nil {
+        t.Errorf("Erase failed: %s", err)
+    }
+
+    if len(store.entries) != 1 || store.entries[0].Name.Text != "hub" {
+        t.Errorf("Wrong entry erased")
+    }
+
+    if err = helper.Serve(ActionGet, strings.NewReader(server), &out); err != ErrCredentialsNotFound {
+        t.Errorf("Expected not found, got %v", err)
+    }
+}
+
+func TestBareHostRegistry(t *testing.T) {
+    store := &fakeStore{}
+    helper := Helper{Vault: store}
+
+    for _, host := range []string{"ghcr.io", "localhost:5000"} {
+        err := helper.Add(&Credentials{ServerURL: host, Username: "grocid", Secret: "banana"})
+
+        if err != nil {
+            t.Fatalf("Store failed for %s: %v", host, err)
+        }
+
+        d := store.entries[len(store.entries)-1]
+
+        if d.URLs[0] != "https://"+host || d.Name.Text != "Docker/"+host {
+            t.Errorf("Unexpected entry %s with URLs %v", d.Name.Text, d.URLs)
+        }
+
+        if username, secret, err := helper.Get(host); err != nil || username != "grocid" || secret != "banana" {
+            t.Errorf("Get failed for %s: %v", host, err)
+        }
+    }
+
+    list, _ := helper.List()
+
+    if len(list) != 2 || list["ghcr.io"] != "grocid" {
+        t.Errorf("Unexpected list %v", list)
+    }
+
+    if err := helper.Delete("ghcr.io"); err != nil || len(store.entries) != 1 {
+        t.Errorf("Erase failed: %v", err)
+    }
+}
+
+func TestInvalidInput(t *testing.T) {
+    helper := Helper{Vault: &fakeStore{}}
+
+    if err := helper.Serve(ActionStore, strings.NewReader(`{"ServerURL":"x"}`), nil); err != ErrMissingUsername {
+        t.Errorf("Credentials without username stored")
+    }
+
+    if err := helper.Serve(ActionGet, strings.NewReader("\n"), nil); err != ErrMissingServerURL {
+        t.Errorf("Empty server URL accepted")
+    }
+
+    if err := helper.Serve("copy", nil, nil); err != ErrAction {
+        t.Errorf("Unknown action accepted")
+    }
+}
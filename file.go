@@ -6,9 +6,9 @@ import (
     "fmt"
     "github.com/murlokswarm/app"
     "io/ioutil"
-    "log"
     "net/url"
     "os/user"
+    "pass/logger"
     "pass/rest"
 )
 
@@ -93,7 +93,7 @@ func (h *File) OnHref(URL *url.URL) {
         })
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
@@ -111,13 +111,21 @@ func (h *File) OK() {
             return
         }
 
+        var err error
+
         d := h.Data.Name
         if h.Title != (*d).Text {
-            // need to remove old and submit new
+            // Store under the new name and remove the old entry.
+            err = restClient.VaultRenameSecret(&h.Data, h.Title)
         } else {
             // Modify the decoded entry so that it matches
             //the contents of the UI.
-            restClient.VaultWriteSecret(&h.Data)
+            err = restClient.VaultWriteSecret(&h.Data)
+        }
+
+        if err != nil {
+            logger.Error(err)
+            return
         }
     }
 
@@ -133,7 +141,8 @@ func (h *File) SaveFile() {
     usr, err := user.Current()
 
     if err != nil {
-        log.Fatal(err)
+        logger.Error(err)
+        return
     }
 
     hashFunction := sha1.New()
@@ -141,9 +150,14 @@ func (h *File) SaveFile() {
     hexDigest := hex.EncodeToString(hashFunction.Sum(nil))
 
     filename := usr.HomeDir + "/Downloads/" + hexDigest
-    ioutil.WriteFile(filename, h.Data.File, 0644)
+    err = ioutil.WriteFile(filename, h.Data.File, 0644)
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
 
-    log.Println("Wrote file" + filename)
+    logger.Info("Wrote file", filename)
 
     app.Render(h)
 }
@@ -162,7 +176,7 @@ func (h *File) ReadFile() {
             // to permissions, dump error message
             // to log.
             if err != nil {
-                log.Println(err)
+                logger.Error(err)
             } else {
                 h.Data.File = b
                 h.Changed = true
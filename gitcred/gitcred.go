@@ -0,0 +1,370 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package gitcred implements the git credential helper protocol, so
+// that git takes its credentials from Pass instead of a plain-text
+// ~/.git-credentials file. Credentials are accounts whose URLs match
+// the URL git asks for, as they would match a page in the browser, see
+// urlmatch, for the same host. A URL with a path is only used for that
+// path and below.
+//
+// See https://git-scm.com/docs/git-credential for the protocol.
+package gitcred
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "net/url"
+    "pass/lock"
+    "pass/rest"
+    "pass/urlmatch"
+    "strings"
+)
+
+// Operations of the protocol.
+const (
+    OperationGet   = "get"
+    OperationStore = "store"
+    OperationErase = "erase"
+)
+
+// DefaultFolder holds the entries stored by git.
+const DefaultFolder = "Git"
+
+var ErrNewline = errors.New("credential contains a newline")
+
+// A Store holds the entries; it is implemented by rest.Client.
+type Store interface {
+    VaultReadAll() ([]*rest.DecodedEntry, error)
+    VaultWriteSecret(data *rest.DecodedEntry) error
+    VaultDeleteSecret(data *rest.DecodedEntry) error
+}
+
+// A Credential is the description git sends, and the answer.
+type Credential struct {
+    Protocol string
+    Host     string
+    Path     string
+    Username string
+    Password string
+}
+
+// Read reads a credential as key=value lines, up to an empty line or
+// the end of input. Unknown keys are ignored.
+func Read(r io.Reader) (*Credential, error) {
+    c := &Credential{}
+    scanner := bufio.NewScanner(r)
+
+    for scanner.Scan() {
+        line := scanner.Text()
+
+        if line == "" {
+            break
+        }
+
+        i := strings.IndexByte(line, '=')
+
+        if i < 0 {
+            return nil, fmt.Errorf("invalid line %q", line)
+        }
+
+        key, value := line[:i], line[i+1:]
+
+        switch key {
+        case "protocol":
+            c.Protocol = value
+        case "host":
+            c.Host = value
+        case "path":
+            c.Path = value
+        case "username":
+            c.Username = value
+        case "password":
+            c.Password = value
+        case "url":
+            u, err := url.Parse(value)
+
+            if err != nil {
+                return nil, err
+            }
+
+            c.Protocol, c.Host = u.Scheme, u.Host
+            c.Path = strings.TrimPrefix(u.Path, "/")
+
+            if u.User != nil {
+                c.Username = u.User.Username()
+            }
+        }
+    }
+
+    return c, scanner.Err()
+}
+
+// Write writes the username and password, as the answer to get.
+func (c *Credential) Write(w io.Writer) error {
+    if strings.ContainsAny(c.Username+c.Password, "\n\x00") {
+        return ErrNewline
+    }
+
+    if c.Username != "" {
+        if _, err := fmt.Fprintf(w, "username=%s\n", c.Username); err != nil {
+            return err
+        }
+    }
+
+    if c.Password != "" {
+        if _, err := fmt.Fprintf(w, "password=%s\n", c.Password); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// URL returns the URL the credential is for.
+func (c *Credential) URL() string {
+    u := url.URL{Scheme: c.Protocol, Host: c.Host}
+
+    if c.Path != "" {
+        u.Path = "/" + c.Path
+    }
+
+    return u.String()
+}
+
+// Restricts an entry to the URLs which may be used for the
+// credential: an account for the same user, if git names one, whose
+// URLs with a path are only used for that path and below. It reports
+// whether any URL is left.
+func target(c *Credential, d *rest.DecodedEntry) (urlmatch.Target, bool) {
+    t := d.Target()
+
+    if d.Type != "" && d.Type != rest.TypeAccount {
+        return t, false
+    }
+
+    if c.Username != "" && d.Username != c.Username {
+        return t, false
+    }
+
+    // Patterns never match as well as a host, see Get.
+    if t.Mode == urlmatch.ModeRegex {
+        return t, false
+    }
+
+    var urls []string
+
+    for _, s := range d.URLs {
+        u, err := urlmatch.Parse(s)
+
+        if err != nil {
+            continue
+        }
+
+        path := strings.Trim(u.Path, "/")
+
+        if path == "" || c.Path == path || strings.HasPrefix(c.Path, path+"/") {
+            urls = append(urls, s)
+        }
+    }
+
+    t.URLs = urls
+    return t, len(urls) > 0
+}
+
+// Helper answers git.
+type Helper struct {
+    Vault Store
+
+    // Folder for the entries stored by git.
+    Folder string
+
+    // Matches the URLs of entries; with the default equivalent domains
+    // if nil.
+    Matcher *urlmatch.Matcher
+}
+
+func (h *Helper) matcher() *urlmatch.Matcher {
+    if h.Matcher == nil {
+        h.Matcher = urlmatch.NewMatcher(urlmatch.DefaultEquivalents)
+    }
+
+    return h.Matcher
+}
+
+// Finds the entries matching the credential, best first, and their
+// scores.
+func (h *Helper) find(c *Credential) ([]*rest.DecodedEntry, []int, error) {
+    entries, err := h.Vault.VaultReadAll()
+
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var candidates []*rest.DecodedEntry
+    var targets []urlmatch.Target
+
+    for _, d := range entries {
+        if t, ok := target(c, d); ok {
+            candidates = append(candidates, d)
+            targets = append(targets, t)
+        }
+    }
+
+    ranked, err := h.matcher().Rank(c.URL(), targets)
+
+    if err != nil {
+        return nil, nil, err
+    }
+
+    matches := make([]*rest.DecodedEntry, len(ranked))
+    scores := make([]int, len(ranked))
+
+    for i, r := range ranked {
+        matches[i], scores[i] = candidates[r.Index], r.Score
+    }
+
+    return matches, scores, nil
+}
+
+// Get fills in the username and password of the best match and
+// reports whether there was one. If not, git asks the user. Like in
+// Store, only an entry for the same host is used; a login for another
+// host of the domain, or an equivalent one, may be for another service.
+func (h *Helper) Get(c *Credential) (bool, error) {
+    matches, scores, err := h.find(c)
+
+    if err != nil || len(matches) == 0 || scores[0] < urlmatch.ScoreHost {
+        return false, err
+    }
+
+    c.Username = matches[0].Username
+    c.Password = matches[0].PasswordText()
+    return true, nil
+}
+
+// Store saves a credential which git found to work. An entry for the
+// same host with the same username is updated; otherwise an entry is
+// added. Entries which only match by domain are left alone, as they may
+// be for another service.
+func (h *Helper) Store(c *Credential) error {
+    if c.Username == "" || c.Password == "" {
+        return nil
+    }
+
+    matches, scores, err := h.find(c)
+
+    if err != nil {
+        return err
+    }
+
+    if len(matches) > 0 && scores[0] >= urlmatch.ScoreHost {
+        d := matches[0]
+
+        if d.HasPassword(c.Password) {
+            return nil
+        }
+
+        d.SetPassword(c.Password)
+        return h.Vault.VaultWriteSecret(d)
+    }
+
+    folder := h.Folder
+
+    if folder == "" {
+        folder = DefaultFolder
+    }
+
+    name := c.Host
+
+    if c.Path != "" {
+        name += "/" + c.Path
+    }
+
+    return h.Vault.VaultWriteSecret(&rest.DecodedEntry{
+        Name:     &rest.Name{Text: rest.JoinPath(folder, c.Username+"@"+name)},
+        Type:     rest.TypeAccount,
+        Username: c.Username,
+        Password: lock.SecureCopy([]byte(c.Password)),
+        URLs:     []string{c.URL()},
+    })
+}
+
+// Erase removes a credential which git found not to work. Only entries
+// with exactly this password are removed, so that an entry is never
+// lost because of, say, a mistyped username.
+func (h *Helper) Erase(c *Credential) error {
+    if c.Password == "" {
+        return nil
+    }
+
+    matches, _, err := h.find(c)
+
+    if err != nil {
+        return err
+    }
+
+    for _, d := range matches {
+        if d.HasPassword(c.Password) {
+            if err = h.Vault.VaultDeleteSecret(d); err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+// Run performs an operation, reading the credential from in and, for
+// get, writing the answer to out. Unknown operations are ignored, as
+// the protocol requires.
+func (h *Helper) Run(operation string, in io.Reader, out io.Writer) error {
+    c, err := Read(in)
+
+    if err != nil {
+        return err
+    }
+
+    switch operation {
+    case OperationGet:
+        if found, err := h.Get(c); err != nil || !found {
+            return err
+        }
+        return c.Write(out)
+    case OperationStore:
+        return h.Store(c)
+    case OperationErase:
+        return h.Erase(c)
+    }
+
+    return nil
+}
@@ -0,0 +1,200 @@
+package gitcred
+
+import (
+    "bytes"
+    "pass/lock"
+    "pass/rest"
+    "pass/urlmatch"
+    "strings"
+    "testing"
+)
+
+type fakeStore struct {
+    entries []*rest.DecodedEntry
+}
+
+func (f *fakeStore) VaultReadAll() ([]*rest.DecodedEntry, error) {
+    return append([]*rest.DecodedEntry{}, f.entries...), nil
+}
+
+func (f *fakeStore) VaultWriteSecret(d *rest.DecodedEntry) error {
+    for _, e := range f.entries {
+        if e == d {
+            return nil
+        }
+    }
+    f.entries = append(f.entries, d)
+    return nil
+}
+
+func (f *fakeStore) VaultDeleteSecret(d *rest.DecodedEntry) error {
+    for i, e := range f.entries {
+        if e == d {
+            f.entries = append(f.entries[:i], f.entries[i+1:]...)
+        }
+    }
+    return nil
+}
+
+func account(user string, password string, urls ...string) *rest.DecodedEntry {
+    return &rest.DecodedEntry{
+        Name:     &rest.Name{Text: user},
+        Username: user,
+        Password: lock.SecureCopy([]byte(password)),
+        URLs:     urls,
+    }
+}
+
+func TestRead(t *testing.T) {
+    c, err := Read(strings.NewReader("protocol=https\nhost=github.com\npath=grocid/pass.git\nwwwauth[]=Basic\n\nignored=1\n"))
+
+    if err != nil || *c != (Credential{Protocol: "https", Host: "github.com", Path: "grocid/pass.git"}) {
+        t.Errorf("Unexpected credential %v: %v", c, err)
+    }
+
+    c, _ = Read(strings.NewReader("url=https://me@example.com:8443/repo.git\n"))
+
+    if c.Host != "example.com:8443" || c.Path != "repo.git" || c.Username != "me" {
+        t.Errorf("URL was not parsed: %v", c)
+    }
+}
+
+func TestGet(t *testing.T) {
+    store := &fakeStore{entries: []*rest.DecodedEntry{
+        account("host", "a", "https://github.com"),
+        account("repo", "b", "https://github.com/grocid/pass.git"),
+        account("other", "c", "https://gitlab.com"),
+        {Name: &rest.Name{Text: "otp"}, Type: rest.TypeOTP, URLs: []string{"https://github.com"}},
+    }}
+
+    helper := Helper{Vault: store}
+    var out bytes.Buffer
+
+    err := helper.Run(OperationGet,
+        strings.NewReader("protocol=https\nhost=github.com\npath=grocid/pass.git\n"), &out)
+
+    if err != nil || out.String() != "username=repo\npassword=b\n" {
+        t.Errorf("Unexpected answer %q: %v", out.String(), err)
+    }
+
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=github.com\npath=other/repo.git\n"), &out)
+
+    if out.String() != "username=host\npassword=a\n" {
+        t.Errorf("Host entry was not used for other paths: %q", out.String())
+    }
+
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=http\nhost=github.com\n"), &out)
+
+    if out.Len() != 0 {
+        t.Errorf("Entry for another protocol was used: %q", out.String())
+    }
+}
+
+func TestGetMatchesLikeBrowser(t *testing.T) {
+    host := account("host", "a", "https://GitHub.com.:443/")
+    host.Match = urlmatch.ModeHost
+    equivalent := account("equivalent", "b", "https://gitlab.example.net")
+    domain := account("domain", "c", "https://www.example.com")
+
+    store := &fakeStore{entries: []*rest.DecodedEntry{host, equivalent, domain}}
+    helper := Helper{Vault: store, Matcher: urlmatch.NewMatcher([][]string{{"example.com", "example.net"}})}
+    var out bytes.Buffer
+
+    // Ports, case and the trailing dot are normalized.
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=github.com\n"), &out)
+
+    if out.String() != "username=host\npassword=a\n" {
+        t.Errorf("Normalized URL was not matched: %q", out.String())
+    }
+
+    // By host only.
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=gist.github.com\n"), &out)
+
+    if out.Len() != 0 {
+        t.Errorf("Entry matched another host: %q", out.String())
+    }
+
+    // Neither the same domain nor an equivalent one is enough.
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=git.example.com\n"), &out)
+
+    if out.Len() != 0 {
+        t.Errorf("Entry for another host was used: %q", out.String())
+    }
+
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=www.example.com\n"), &out)
+
+    if out.String() != "username=domain\npassword=c\n" {
+        t.Errorf("Unexpected answer %q", out.String())
+    }
+
+    // Nor is a pattern.
+    pattern := account("pattern", "e", `https://[a-z]+\.example\.org/.*`)
+    pattern.Match = urlmatch.ModeRegex
+    store.entries = append(store.entries, pattern)
+
+    out.Reset()
+    helper.Run(OperationGet, strings.NewReader("protocol=https\nhost=git.example.org\n"), &out)
+
+    if out.Len() != 0 {
+        t.Errorf("Pattern was used: %q", out.String())
+    }
+
+    // Storing does not touch an entry which only matches by domain.
+    helper.Run(OperationStore, strings.NewReader("protocol=https\nhost=git.example.com\nusername=domain\npassword=d\n"), nil)
+
+    if !domain.HasPassword("c") || len(store.entries) != 5 {
+        t.Errorf("Entry for another host was updated")
+    }
+}
+
+func TestStoreAndErase(t *testing.T) {
+    store := &fakeStore{}
+    helper := Helper{Vault: store}
+    input := "protocol=https\nhost=github.com\nusername=grocid\npassword=banana\n"
+
+    helper.Run(OperationStore, strings.NewReader(input), nil)
+    helper.Run(OperationStore, strings.NewReader(input), nil)
+
+    if len(store.entries) != 1 {
+        t.Fatalf("Expected one entry, got %d", len(store.entries))
+    }
+
+    d := store.entries[0]
+
+    if d.Name.Text != "Git/grocid@github.com" || d.URLs[0] != "https://github.com" {
+        t.Errorf("Unexpected entry %s %v", d.Name.Text, d.URLs)
+    }
+
+    // A changed password updates the entry.
+    helper.Run(OperationStore, strings.NewReader(strings.Replace(input, "banana", "apple", 1)), nil)
+
+    if len(store.entries) != 1 || !d.HasPassword("apple") {
+        t.Errorf("Password was not updated")
+    }
+
+    // Only the rejected password is erased.
+    helper.Run(OperationErase, strings.NewReader(input), nil)
+
+    if len(store.entries) != 1 {
+        t.Errorf("Entry with another password was erased")
+    }
+
+    helper.Run(OperationErase, strings.NewReader(strings.Replace(input, "banana", "apple", 1)), nil)
+
+    if len(store.entries) != 0 {
+        t.Errorf("Entry was not erased")
+    }
+}
+
+func TestWriteRejectsNewlines(t *testing.T) {
+    c := Credential{Username: "me", Password: "a\nhost=evil.com"}
+
+    if err := c.Write(&bytes.Buffer{}); err != ErrNewline {
+        t.Errorf("Newline was written")
+    }
+}
@@ -0,0 +1,273 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "net/url"
+    "pass/logger"
+    "pass/rest"
+)
+
+type Identity struct {
+    Title         string
+    Error         string
+    DocumentTypes []string
+    Identity      rest.Identity
+    Data          rest.DecodedEntry
+}
+
+func (h *Identity) Render() string {
+    h.DocumentTypes = rest.DocumentTypes
+
+    return `
+<div class="WindowLayout">
+    <div class="SearchLayout">
+        <input type="text"
+               value="{{html .Title}}"
+               placeholder="Account"
+               onchange="DoSearchQuery"
+               autocomplete="off"
+               autocorrect="off"
+               autocapitalize="off"
+               spellcheck="false"
+               selectable="on"
+               class="editable searchfield"/>
+        <div class="animated">
+            <div style="text-align: center;
+                        margin-left: auto;
+                        margin-right: auto;
+                        margin-top: -webkit-calc(20vh - 20px);">
+                <img src="iconpack/` + GetImageName("identity") + `.png"
+                      style="max-width: 128px; "/>
+                <p><input type="text"
+                       value="{{.Title}}"
+                       placeholder="Identity"
+                       onchange="Title"
+                       autocomplete="off"
+                       spellcheck="false"
+                       selectable="on"
+                       class="editable name"/></p>
+            </div>
+            <div class="details">
+                <input type="text" value="{{html .Identity.FirstName}}"
+                       placeholder="First name" onchange="Identity.FirstName"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable username"/>
+                <input type="text" value="{{html .Identity.MiddleName}}"
+                       placeholder="Middle name" onchange="Identity.MiddleName"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable username"/>
+                <input type="text" value="{{html .Identity.LastName}}"
+                       placeholder="Last name" onchange="Identity.LastName"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable username"/>
+                <input type="text" value="{{html .Identity.Birthday}}"
+                       placeholder="Birthday (YYYY-MM-DD)" onchange="Identity.Birthday"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable"/>
+                <input type="text" value="{{html .Identity.Email}}"
+                       placeholder="Email" onchange="Identity.Email"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable"/>
+                <input type="text" value="{{html .Identity.Phone}}"
+                       placeholder="Phone" onchange="Identity.Phone"
+                       autocomplete="off" spellcheck="false" selectable="on"
+                       class="editable"/>
+                {{range $i, $a := .Identity.Addresses}}
+                <div class="field">
+                    <input type="text" value="{{html $a.Label}}"
+                           placeholder="Address (clear all to remove)"
+                           onchange="Identity.Addresses.{{$i}}.Label"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable fieldname"/>
+                    <input type="text" value="{{html $a.Street}}"
+                           placeholder="Street" onchange="Identity.Addresses.{{$i}}.Street"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                    <input type="text" value="{{html $a.PostalCode}}"
+                           placeholder="Postal code" onchange="Identity.Addresses.{{$i}}.PostalCode"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                    <input type="text" value="{{html $a.City}}"
+                           placeholder="City" onchange="Identity.Addresses.{{$i}}.City"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                    <input type="text" value="{{html $a.Region}}"
+                           placeholder="Region" onchange="Identity.Addresses.{{$i}}.Region"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                    <input type="text" value="{{html $a.Country}}"
+                           placeholder="Country" onchange="Identity.Addresses.{{$i}}.Country"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                </div>
+                {{end}}
+                <a class="clickable addfield" onclick="AddAddress">+ Add address</a>
+                {{range $i, $d := .Identity.Documents}}
+                <div class="field">
+                    <select onchange="Identity.Documents.{{$i}}.Type"
+                            class="editable fieldtype">
+                        {{range $.DocumentTypes}}
+                        <option value="{{.}}" {{if eq . $d.Type}}selected{{end}}>{{.}}</option>
+                        {{end}}
+                    </select>
+                    <input type="text" value="{{html $d.Number}}"
+                           placeholder="Number (clear to remove)"
+                           onchange="Identity.Documents.{{$i}}.Number"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable fieldvalue"/>
+                    <input type="text" value="{{html $d.Country}}"
+                           placeholder="Issuing country"
+                           onchange="Identity.Documents.{{$i}}.Country"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                    <input type="text" value="{{html $d.Expiry}}"
+                           placeholder="Expiry (YYYY-MM-DD)"
+                           onchange="Identity.Documents.{{$i}}.Expiry"
+                           autocomplete="off" spellcheck="false" selectable="on"
+                           class="editable"/>
+                </div>
+                {{end}}
+                <a class="clickable addfield" onclick="AddDocument">+ Add passport or ID</a>
+                <textarea placeholder="Notes"
+                          onchange="Data.Notes"
+                          autocomplete="off"
+                          spellcheck="false"
+                          selectable="on"
+                          class="editable notes">{{html .Data.Notes}}</textarea>
+                {{if .Error}}<p class="timestamps">{{.Error}}</p>{{end}}
+            </div>
+          </div>
+          <div class="bottom-toolbar">
+              <div>
+                  <button class="button ok" onclick="OK"/>
+                  <button class="button cancel" onclick="Cancel"/>
+                  <button class="button delete" onclick="Delete"/>
+              </div>
+          </div>
+     </div>
+</div>`
+}
+
+func (h *Identity) OnHref(URL *url.URL) {
+    u := URL.Query()
+    h.Title = u.Get("Name")
+
+    restResponse, err := restClient.VaultReadSecret(
+        &rest.Name{
+            Text:      h.Title,
+            Encrypted: u.Get("Encrypted"),
+        })
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
+
+    h.Data = *restResponse
+
+    if i, ok := h.Data.Payload.(*rest.Identity); ok {
+        h.Identity = *i
+    }
+
+    app.Render(h)
+}
+
+func (h *Identity) AddAddress() {
+    h.Identity.Addresses = append(h.Identity.Addresses, rest.Address{})
+    app.Render(h)
+}
+
+func (h *Identity) AddDocument() {
+    h.Identity.Documents = append(h.Identity.Documents, rest.Document{
+        Type: rest.DocumentPassport,
+    })
+    app.Render(h)
+}
+
+func (h *Identity) OK() {
+    // We do not want empty names.
+    if h.Title == "" {
+        return
+    }
+
+    // Drop addresses and documents which were cleared.
+    addresses := []rest.Address{}
+
+    for _, a := range h.Identity.Addresses {
+        if a != (rest.Address{}) {
+            addresses = append(addresses, a)
+        }
+    }
+
+    documents := []rest.Document{}
+
+    for _, d := range h.Identity.Documents {
+        if d.Number != "" {
+            documents = append(documents, d)
+        }
+    }
+
+    h.Identity.Addresses = addresses
+    h.Identity.Documents = documents
+
+    h.Data.Type = rest.TypeIdentity
+    h.Data.Payload = &h.Identity
+
+    if err := saveEntry(&h.Data, h.Title); err != nil {
+        logger.Error(err)
+        h.Error = err.Error()
+        app.Render(h)
+        return
+    }
+
+    h.Cancel()
+}
+
+func (h *Identity) Cancel() {
+    NavigateBack("")
+}
+
+func (h *Identity) Delete() {
+    if h.Data.Name != nil {
+        restClient.VaultDeleteSecret(&h.Data)
+    }
+    h.Cancel()
+}
+
+func (h *Identity) DoSearchQuery(arg app.ChangeArg) {
+    NavigateBack(arg.Value)
+}
+
+func init() {
+    app.RegisterComponent(&Identity{})
+}
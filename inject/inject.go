@@ -0,0 +1,252 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package inject runs programs with secrets from Pass in their
+// environment, so that they never have to be copied into a shell or
+// written to disk.
+package inject
+
+import (
+    "errors"
+    "os"
+    "os/exec"
+    "os/signal"
+    "pass/rest"
+    "strings"
+    "syscall"
+)
+
+// DefaultField is used when a mapping does not name a field.
+const DefaultField = "password"
+
+var ErrMapping = errors.New("mapping must be of the form VARIABLE=entry[:field]")
+
+// A Source finds entries; it is implemented by rest.Client.
+type Source interface {
+    VaultFindSecret(name string, id string) (*rest.DecodedEntry, error)
+    VaultFindTagged(tag string) ([]*rest.DecodedEntry, error)
+}
+
+// A Mapping sets an environment variable to a field of an entry.
+type Mapping struct {
+    Variable string
+    Entry    string
+    Field    string
+}
+
+// ParseMapping parses VARIABLE=entry[:field], e.g.,
+// DB_PASSWORD=Work/db-prod:password. The field defaults to the
+// password.
+func ParseMapping(s string) (Mapping, error) {
+    i := strings.IndexByte(s, '=')
+
+    if i <= 0 || i == len(s)-1 {
+        return Mapping{}, ErrMapping
+    }
+
+    m := Mapping{
+        Variable: s[:i],
+        Entry:    s[i+1:],
+        Field:    DefaultField,
+    }
+
+    if j := strings.LastIndexByte(m.Entry, ':'); j >= 0 {
+        m.Entry, m.Field = m.Entry[:j], m.Entry[j+1:]
+    }
+
+    if m.Entry == "" || m.Field == "" {
+        return Mapping{}, ErrMapping
+    }
+
+    return m, nil
+}
+
+// VariableName derives an environment variable name from an entry and
+// a field, e.g., GITHUB_PASSWORD for the password of Work/github.
+func VariableName(entry string, field string) string {
+    _, base := rest.SplitPath(entry)
+
+    return strings.Map(func(r rune) rune {
+        switch {
+        case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+            return r
+        case r >= 'a' && r <= 'z':
+            return r - 'a' + 'A'
+        }
+        return '_'
+    }, base+"_"+field)
+}
+
+// Variables returns the variables of an entry, as used for tags: the
+// username and password, if set, and all custom fields.
+func Variables(d *rest.DecodedEntry) map[string]string {
+    vars := map[string]string{}
+
+    for _, field := range []string{"username", "password"} {
+        if v, err := d.Value(field); err == nil && v != "" {
+            vars[VariableName(d.Name.Text, field)] = v
+        }
+    }
+
+    for _, f := range d.Fields {
+        vars[VariableName(d.Name.Text, f.Name)] = f.Value
+    }
+
+    return vars
+}
+
+// Resolve reads the entries and returns the variables. Entries with
+// one of the tags contribute all their variables; explicit mappings
+// take precedence. A missing entry or field is an error.
+func Resolve(s Source, mappings []Mapping, tags []string) (map[string]string, error) {
+    vars := map[string]string{}
+
+    for _, tag := range tags {
+        entries, err := s.VaultFindTagged(tag)
+
+        if err != nil {
+            return nil, err
+        }
+
+        for _, entry := range entries {
+            for k, v := range Variables(entry) {
+                vars[k] = v
+            }
+        }
+    }
+
+    // Entries are only read once, even if several fields are used.
+    entries := map[string]*rest.DecodedEntry{}
+
+    for _, m := range mappings {
+        entry, ok := entries[m.Entry]
+
+        if !ok {
+            var err error
+
+            if entry, err = s.VaultFindSecret(m.Entry, ""); err != nil {
+                return nil, errors.New(m.Entry + ": " + err.Error())
+            }
+
+            entries[m.Entry] = entry
+        }
+
+        v, err := entry.Value(m.Field)
+
+        if err != nil {
+            return nil, errors.New(m.Entry + ": " + m.Field + ": " + err.Error())
+        }
+
+        vars[m.Variable] = v
+    }
+
+    return vars, nil
+}
+
+// Environ returns the environment env with the variables set, replacing
+// any variables with the same name.
+func Environ(env []string, vars map[string]string) []string {
+    result := make([]string, 0, len(env)+len(vars))
+
+    for _, kv := range env {
+        name := kv
+
+        if i := strings.IndexByte(kv, '='); i >= 0 {
+            name = kv[:i]
+        }
+
+        if _, ok := vars[name]; !ok {
+            result = append(result, kv)
+        }
+    }
+
+    for k, v := range vars {
+        result = append(result, k+"="+v)
+    }
+
+    return result
+}
+
+// Run runs a program with the given environment and the standard
+// streams of this process. Signals are forwarded to it. It returns the
+// exit code of the program, or, like a shell, 128 plus the number of
+// the signal which terminated it.
+func Run(name string, args []string, env []string) (int, error) {
+    cmd := exec.Command(name, args...)
+    cmd.Env = env
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+
+    // Start listening before the child exists, so that no signal is
+    // lost in between. An interrupt already reaches the child from the
+    // terminal, so it is only caught, for us to wait for the child.
+    signals := make(chan os.Signal, 8)
+    signal.Notify(signals, append(forwarded, os.Interrupt)...)
+    defer signal.Stop(signals)
+
+    if err := cmd.Start(); err != nil {
+        return 127, err
+    }
+
+    done := make(chan struct{})
+    defer close(done)
+
+    go func() {
+        for {
+            select {
+            case s := <-signals:
+                if s != os.Interrupt {
+                    cmd.Process.Signal(s)
+                }
+            case <-done:
+                return
+            }
+        }
+    }()
+
+    err := cmd.Wait()
+
+    if err == nil {
+        return 0, nil
+    }
+
+    exitErr, ok := err.(*exec.ExitError)
+
+    if !ok {
+        return 1, err
+    }
+
+    if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+        return 128 + int(status.Signal()), nil
+    }
+
+    return exitErr.ExitCode(), nil
+}
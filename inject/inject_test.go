@@ -0,0 +1,126 @@
+package inject
+
+import (
+    "pass/lock"
+    "pass/rest"
+    "sort"
+    "syscall"
+    "testing"
+)
+
+type fakeSource map[string]*rest.DecodedEntry
+
+func (f fakeSource) VaultFindSecret(name string, id string) (*rest.DecodedEntry, error) {
+    if d, ok := f[name]; ok {
+        return d, nil
+    }
+    return nil, rest.ErrNotFound
+}
+
+func (f fakeSource) VaultFindTagged(tag string) ([]*rest.DecodedEntry, error) {
+    entries := []*rest.DecodedEntry{}
+
+    for _, d := range f {
+        if d.HasTag(tag) {
+            entries = append(entries, d)
+        }
+    }
+
+    return entries, nil
+}
+
+func TestParseMapping(t *testing.T) {
+    m, err := ParseMapping("DB=Work/db-prod:username")
+
+    if err != nil || m != (Mapping{"DB", "Work/db-prod", "username"}) {
+        t.Errorf("Unexpected mapping %v: %v", m, err)
+    }
+
+    m, _ = ParseMapping("TOKEN=github")
+
+    if m.Field != DefaultField {
+        t.Errorf("Field did not default to the password")
+    }
+
+    for _, s := range []string{"TOKEN", "=github", "TOKEN=", "TOKEN=github:", "TOKEN=:user"} {
+        if _, err := ParseMapping(s); err != ErrMapping {
+            t.Errorf("Invalid mapping %s accepted", s)
+        }
+    }
+}
+
+func TestVariableName(t *testing.T) {
+    if name := VariableName("Work/db-prod", "api key"); name != "DB_PROD_API_KEY" {
+        t.Errorf("Unexpected name %s", name)
+    }
+}
+
+func TestResolve(t *testing.T) {
+    source := fakeSource{
+        "db": {
+            Name:     &rest.Name{Text: "db"},
+            Username: "admin",
+            Password: lock.SecureCopy([]byte("banana")),
+            Tags:     []string{"deploy"},
+            Fields:   []rest.Field{{Name: "host", Value: "db.local"}},
+        },
+        "github": {Name: &rest.Name{Text: "github"}, Password: lock.SecureCopy([]byte("token"))},
+    }
+
+    vars, err := Resolve(source,
+        []Mapping{{"GITHUB_TOKEN", "github", "password"}, {"DB_USERNAME", "github", "password"}},
+        []string{"deploy"})
+
+    if err != nil {
+        t.Fatalf("Resolve failed: %s", err)
+    }
+
+    expected := map[string]string{
+        "GITHUB_TOKEN": "token",
+        "DB_USERNAME":  "token",
+        "DB_PASSWORD":  "banana",
+        "DB_HOST":      "db.local",
+    }
+
+    for k, v := range expected {
+        if vars[k] != v {
+            t.Errorf("%s is %q, expected %q", k, vars[k], v)
+        }
+    }
+
+    if _, err = Resolve(source, []Mapping{{"X", "missing", "password"}}, nil); err == nil {
+        t.Errorf("Missing entry was not an error")
+    }
+
+    if _, err = Resolve(source, []Mapping{{"X", "github", "pin"}}, nil); err == nil {
+        t.Errorf("Missing field was not an error")
+    }
+}
+
+func TestEnviron(t *testing.T) {
+    env := Environ([]string{"HOME=/home/me", "TOKEN=old"}, map[string]string{"TOKEN": "new"})
+    sort.Strings(env)
+
+    if len(env) != 2 || env[0] != "HOME=/home/me" || env[1] != "TOKEN=new" {
+        t.Errorf("Unexpected environment %v", env)
+    }
+}
+
+func TestRunExitCode(t *testing.T) {
+    code, err := Run("sh", []string{"-c", `test "$TOKEN" = secret && exit 3`},
+        []string{"TOKEN=secret"})
+
+    if err != nil || code != 3 {
+        t.Errorf("Unexpected exit code %d: %v", code, err)
+    }
+
+    code, _ = Run("sh", []string{"-c", "kill -TERM $$"}, nil)
+
+    if code != 128+int(syscall.SIGTERM) {
+        t.Errorf("Unexpected exit code %d after signal", code)
+    }
+
+    if _, err = Run("/nonexistent", nil, nil); err == nil {
+        t.Errorf("Missing program was not an error")
+    }
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package inject
+
+import (
+    "os"
+    "path/filepath"
+    "syscall"
+    "testing"
+    "time"
+)
+
+// Sends the signals to this process, one after the other, once the
+// child has created the file ready.
+func signalWhenReady(ready string, signals ...syscall.Signal) {
+    for i := 0; i < 100; i++ {
+        if _, err := os.Stat(ready); err == nil {
+            for _, s := range signals {
+                syscall.Kill(os.Getpid(), s)
+                time.Sleep(100 * time.Millisecond)
+            }
+            return
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+}
+
+func TestRunForwardsSignals(t *testing.T) {
+    ready := filepath.Join(t.TempDir(), "ready")
+    go signalWhenReady(ready, syscall.SIGUSR1)
+
+    code, err := Run("sh", []string{"-c",
+        `trap "exit 7" USR1; touch "$READY"; while true; do sleep 0.05; done`},
+        []string{"READY=" + ready})
+
+    if err != nil || code != 7 {
+        t.Errorf("Signal was not forwarded, exit code %d: %v", code, err)
+    }
+}
+
+// The terminal sends an interrupt to the child itself, so it must not
+// arrive twice.
+func TestRunDoesNotForwardInterrupt(t *testing.T) {
+    ready := filepath.Join(t.TempDir(), "ready")
+    go signalWhenReady(ready, syscall.SIGINT, syscall.SIGUSR1)
+
+    code, err := Run("sh", []string{"-c",
+        `trap "exit 9" INT; trap "exit 7" USR1; touch "$READY"; while true; do sleep 0.05; done`},
+        []string{"READY=" + ready})
+
+    if err != nil || code != 7 {
+        t.Errorf("Interrupt was forwarded, exit code %d: %v", code, err)
+    }
+}
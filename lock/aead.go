@@ -34,9 +34,13 @@ import (
     "crypto/rand"
     "errors"
     "golang.org/x/crypto/chacha20poly1305"
+    "pass/observe"
 )
 
-func Chacha20Poly1305Encrypt(plaintext []byte, key []byte) ([]byte, error) {
+func Chacha20Poly1305Encrypt(plaintext []byte, key []byte) (ciphertext []byte, err error) {
+    done := observe.Time(Observer, observe.OperationEncrypt)
+    defer func() { done(len(plaintext), 0, err) }()
+
     // Create a key data structure
     chacha20aead, err := chacha20poly1305.New(key)
 
@@ -47,12 +51,39 @@ func Chacha20Poly1305Encrypt(plaintext []byte, key []byte) ([]byte, error) {
     nonce := make([]byte, chacha20poly1305.NonceSize)
     rand.Read(nonce)
 
-    ciphertext := chacha20aead.Seal(nil, nonce, plaintext, nil)
+    ciphertext = chacha20aead.Seal(nil, nonce, plaintext, nil)
 
     return append(nonce, ciphertext...), nil
 }
 
-func Chacha20Poly1305Decrypt(ciphertext []byte, key []byte) ([]byte, error) {
+func Chacha20Poly1305Decrypt(ciphertext []byte, key []byte) (plaintext []byte, err error) {
+    return chacha20Poly1305Open(nil, ciphertext, key)
+}
+
+// Chacha20Poly1305DecryptSecure decrypts into a SecureBuffer, so that
+// the plaintext is never held anywhere else. The caller has to wipe it.
+func Chacha20Poly1305DecryptSecure(ciphertext []byte, key []byte) (*SecureBuffer, error) {
+    size := len(ciphertext) - chacha20poly1305.NonceSize - chacha20poly1305.Overhead
+
+    if size < 0 {
+        size = 0
+    }
+
+    plaintext := NewSecureBuffer(size)
+
+    if _, err := chacha20Poly1305Open(plaintext.Bytes()[:0], ciphertext, key); err != nil {
+        plaintext.Wipe()
+        return nil, err
+    }
+
+    return plaintext, nil
+}
+
+// Decrypts and appends the plaintext to dst.
+func chacha20Poly1305Open(dst []byte, ciphertext []byte, key []byte) (plaintext []byte, err error) {
+    done := observe.Time(Observer, observe.OperationDecrypt)
+    defer func() { done(len(ciphertext), 0, err) }()
+
     // Create a key data structure
     chacha20aead, err := chacha20poly1305.New(key)
 
@@ -60,7 +91,7 @@ func Chacha20Poly1305Decrypt(ciphertext []byte, key []byte) ([]byte, error) {
         return []byte{}, errors.New("failed to decrypt or verify message authentication code")
     }
 
-    plaintext, err := chacha20aead.Open(nil,
+    plaintext, err = chacha20aead.Open(dst,
         ciphertext[:chacha20poly1305.NonceSize],
         ciphertext[chacha20poly1305.NonceSize:], nil)
 
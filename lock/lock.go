@@ -31,6 +31,7 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package lock
 
 import (
+    "crypto/hmac"
     "crypto/rand"
     "crypto/sha256"
     "encoding/base64"
@@ -38,6 +39,7 @@ import (
     "golang.org/x/crypto/argon2"
     "golang.org/x/crypto/pbkdf2"
     "math/big"
+    "pass/observe"
 )
 
 const (
@@ -51,6 +53,10 @@ const (
 
     UseArgon2ForKeyDerivation      = true
     DefaultGeneratedPasswordLength = 32
+
+    // Context for deriving the blind index key from the master key.
+    BlindIndexContext = "pass blind index v1"
+    BlindIndexLength  = 2 * sha256.Size
 )
 
 // The alphabet from which characters are drawn. The entropy is per
@@ -58,16 +64,21 @@ const (
 // setting (length = 32) generates passwords with ~190 bits.
 var alphabet = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
+// Observer is notified of key derivations, encryptions and decryptions.
+// It only ever sees timings and sizes, never keys or plaintexts.
+var Observer observe.Observer = observe.Nop{}
+
 type Lock struct {
-    Key  []byte
+    Key  *SecureBuffer
     Salt []byte
 }
 
 func New(password string, salt []byte) Lock {
     key := DeriveKey([]byte(password), salt)
     l := Lock{
-        Key:  key,
+        Key:  SecureCopy(key),
         Salt: salt}
+    Zero(key)
     return l
 }
 
@@ -99,6 +110,9 @@ func EntropyAlphabet(length int) string {
 }
 
 func DeriveKey(password []byte, salt []byte) []byte {
+    done := observe.Time(Observer, observe.OperationDeriveKey)
+    defer done(0, 0, nil)
+
     // Setting to specify key derivation algorithm.
     if UseArgon2ForKeyDerivation {
         // Return derived key from Argon2.
@@ -118,38 +132,55 @@ func DeriveKey(password []byte, salt []byte) []byte {
 }
 
 func (l *Lock) EncryptAndEncodeBase64(plaintext string) ([]byte, error) {
+    return l.EncryptBase64([]byte(plaintext))
+}
+
+// EncryptBase64 encrypts and encodes plaintext, e.g., the contents of a
+// SecureBuffer, without copying it.
+func (l *Lock) EncryptBase64(plaintext []byte) ([]byte, error) {
     // Encrypt with AEAD.
-    ciphertext, err := Chacha20Poly1305Encrypt([]byte(plaintext), l.Key)
+    ciphertext, err := Chacha20Poly1305Encrypt(plaintext, l.Key.Bytes())
 
     // Return base64 encoded
     return []byte(base64.StdEncoding.EncodeToString(ciphertext)), err
 }
 
-func (l *Lock) Base64DecodeAndDecrypt(base64Ciphertext []byte) (string, error) {
+// DecryptBase64 decodes and decrypts into a SecureBuffer, which the
+// caller has to wipe.
+func (l *Lock) DecryptBase64(base64Ciphertext []byte) (*SecureBuffer, error) {
     // Decode the provided base64 string, use strings for simplicity
     ciphertext, err := base64.StdEncoding.DecodeString(string(base64Ciphertext))
 
+    if err != nil {
+        return nil, err
+    }
+
     // Use key to decrypt ciphertext
-    token, err := Chacha20Poly1305Decrypt(ciphertext, l.Key)
-    return string(token), err
+    return Chacha20Poly1305DecryptSecure(ciphertext, l.Key.Bytes())
 }
 
 func (l *Lock) EncryptAndEncodeHex(plaintext string) (string, error) {
+    return l.EncryptHex([]byte(plaintext))
+}
+
+// EncryptHex encrypts and encodes plaintext, e.g., the contents of a
+// SecureBuffer, without copying it.
+func (l *Lock) EncryptHex(plaintext []byte) (string, error) {
     // Encrypt with AEAD.
-    ciphertext, err := Chacha20Poly1305Encrypt([]byte(plaintext), l.Key)
+    ciphertext, err := Chacha20Poly1305Encrypt(plaintext, l.Key.Bytes())
 
     // Return hexencoded
     return string(hex.EncodeToString(ciphertext)), err
 }
 
-func (l *Lock) HexDecodeAndDecrypt(hexCiphertext string) (string, error) {
+// DecryptHex decodes and decrypts into a SecureBuffer, which the caller
+// has to wipe.
+func (l *Lock) DecryptHex(hexCiphertext string) (*SecureBuffer, error) {
     // Decode the provided hex strings
     ciphertext, _ := hex.DecodeString(hexCiphertext)
 
     // Use key to decrypt ciphertext
-    token, err := Chacha20Poly1305Decrypt(ciphertext, l.Key)
-
-    return string(token), err
+    return Chacha20Poly1305DecryptSecure(ciphertext, l.Key.Bytes())
 }
 
 func (l *Lock) LockToken(token string) (string, string, error) {
@@ -168,15 +199,38 @@ func (l *Lock) LockToken(token string) (string, string, error) {
     return hexToken, hexSalt, nil
 }
 
-func (l *Lock) UnlockToken(hexToken string) (string, error) {
+// UnlockToken decrypts the token. The caller has to wipe it.
+func (l *Lock) UnlockToken(hexToken string) (*SecureBuffer, error) {
 
-    token, err := l.HexDecodeAndDecrypt(hexToken)
+    token, err := l.DecryptHex(hexToken)
 
     // Take care of errors, i.e., if message authentication failed...
     if err != nil {
-        return "", err
+        return nil, err
     }
 
     // ...otherwise, return to UI
     return token, nil
 }
+
+// BlindIndex computes a keyed, deterministic identifier for a name,
+// which can be used as storage key. The HMAC key is derived from the
+// master key, but separated from the encryption key by a context, so
+// knowing an index reveals nothing about the encryption key (and
+// without the key, nothing about the name).
+func (l *Lock) BlindIndex(name string) string {
+    derivation := hmac.New(sha256.New, l.Key.Bytes())
+    derivation.Write([]byte(BlindIndexContext))
+    indexKey := derivation.Sum(nil)
+
+    index := hmac.New(sha256.New, indexKey)
+    index.Write([]byte(name))
+
+    return hex.EncodeToString(index.Sum(nil))
+}
+
+// Wipe overwrites the key, so that it does not linger in memory once
+// Pass is locked. The lock cannot be used afterwards.
+func (l *Lock) Wipe() {
+    l.Key.Wipe()
+}
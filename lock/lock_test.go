@@ -54,10 +54,10 @@ func TestLockAndUnlockToken(t *testing.T) {
         t.Errorf("Token unlock error")
     }
 
-    if decryptedToken != token {
+    if string(decryptedToken.Bytes()) != token {
         t.Errorf("Token was incorrect, got: %s, want: %s.",
-            token,
-            decryptedToken)
+            decryptedToken.Bytes(),
+            token)
     }
 }
 
@@ -74,16 +74,16 @@ func TestEncryptAndDecryptBase64(t *testing.T) {
         t.Errorf("Encryption error")
     }
 
-    decryptedCiphertext, err := l.Base64DecodeAndDecrypt(ciphertext)
+    decryptedCiphertext, err := l.DecryptBase64(ciphertext)
 
     if err != nil {
-        t.Errorf("Decryption error")
+        t.Fatalf("Decryption error")
     }
 
-    if decryptedCiphertext != plaintext {
+    if !decryptedCiphertext.Equal([]byte(plaintext)) {
         t.Errorf("Decrypted ciphertext was incorrect, got: %s, want: %s.",
-            plaintext,
-            decryptedCiphertext)
+            decryptedCiphertext.Bytes(),
+            plaintext)
     }
 }
 
@@ -100,15 +100,57 @@ func TestEncryptAndDecryptHex(t *testing.T) {
         t.Errorf("Encryption error")
     }
 
-    decryptedCiphertext, err := l.HexDecodeAndDecrypt(ciphertext)
+    decryptedCiphertext, err := l.DecryptHex(ciphertext)
 
     if err != nil {
-        t.Errorf("Decryption error")
+        t.Fatalf("Decryption error")
     }
 
-    if decryptedCiphertext != plaintext {
+    if !decryptedCiphertext.Equal([]byte(plaintext)) {
         t.Errorf("Decrypted ciphertext was incorrect, got: %s, want: %s.",
-            plaintext,
-            decryptedCiphertext)
+            decryptedCiphertext.Bytes(),
+            plaintext)
+    }
+}
+
+func TestBlindIndex(t *testing.T) {
+    l := Lock{Key: SecureCopy(Entropy(32))}
+    m := Lock{Key: SecureCopy(Entropy(32))}
+
+    if l.BlindIndex("github") != l.BlindIndex("github") {
+        t.Errorf("Blind index is not deterministic")
+    }
+
+    if l.BlindIndex("github") == l.BlindIndex("gitlab") {
+        t.Errorf("Different names have the same index")
+    }
+
+    if l.BlindIndex("github") == m.BlindIndex("github") {
+        t.Errorf("Different keys give the same index")
+    }
+
+    if len(l.BlindIndex("github")) != BlindIndexLength {
+        t.Errorf("Unexpected length of index: %d", len(l.BlindIndex("github")))
+    }
+}
+
+func TestWipe(t *testing.T) {
+    l := New("mypassword", Entropy(32))
+    key := l.Key.Bytes()
+    l.Wipe()
+
+    for _, b := range key {
+        if b != 0 {
+            t.Fatalf("Key not overwritten")
+        }
+    }
+
+    if !l.Key.Wiped() {
+        t.Errorf("Key still held")
+    }
+
+    // A wiped lock cannot encrypt, rather than encrypting with zeros.
+    if _, err := l.EncryptAndEncodeHex("secret"); err == nil {
+        t.Errorf("Encrypted without a key")
     }
 }
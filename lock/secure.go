@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package lock
+
+import (
+    "crypto/subtle"
+    "runtime"
+)
+
+// A SecureBuffer holds key material or decrypted data. Its memory is
+// locked, where the operating system allows it, so that it is not
+// written to swap, and it is overwritten by Wipe, or at the latest when
+// it is garbage collected. Its contents are only ever handed out as
+// bytes: a string cannot be overwritten, and every conversion to a
+// string leaves a copy behind. Memory is locked by the page, so
+// unlocking one buffer may unlock its neighbours; locking only makes
+// swapping less likely.
+type SecureBuffer struct {
+    data   []byte
+    locked bool
+}
+
+// NewSecureBuffer returns a buffer of size zero bytes.
+func NewSecureBuffer(size int) *SecureBuffer {
+    b := &SecureBuffer{}
+    b.alloc(size)
+    return b
+}
+
+// Replaces the contents with size zero bytes.
+func (b *SecureBuffer) alloc(size int) {
+    b.Wipe()
+    b.data = make([]byte, size)
+
+    if size > 0 {
+        b.locked = mlock(b.data) == nil
+    }
+
+    runtime.SetFinalizer(b, nil)
+    runtime.SetFinalizer(b, (*SecureBuffer).Wipe)
+}
+
+// SecureCopy returns a buffer holding a copy of data. The caller should
+// Zero data if it is no longer needed.
+func SecureCopy(data []byte) *SecureBuffer {
+    b := NewSecureBuffer(len(data))
+    copy(b.data, data)
+    return b
+}
+
+// Zero overwrites data.
+func Zero(data []byte) {
+    for i := range data {
+        data[i] = 0
+    }
+}
+
+// Bytes returns the contents, which must not be kept beyond the life
+// of the buffer. It is nil once the buffer has been wiped.
+func (b *SecureBuffer) Bytes() []byte {
+    if b == nil {
+        return nil
+    }
+    return b.data
+}
+
+// Len returns the size of the contents.
+func (b *SecureBuffer) Len() int {
+    return len(b.Bytes())
+}
+
+// Locked reports whether the memory of the buffer is locked.
+func (b *SecureBuffer) Locked() bool {
+    return b != nil && b.locked
+}
+
+// Wiped reports whether the buffer has been wiped, or never held
+// anything.
+func (b *SecureBuffer) Wiped() bool {
+    return b.Bytes() == nil
+}
+
+// Equal compares the contents in constant time.
+func (b *SecureBuffer) Equal(data []byte) bool {
+    return !b.Wiped() && subtle.ConstantTimeCompare(b.data, data) == 1
+}
+
+// Clone returns a copy, which has to be wiped on its own.
+func (b *SecureBuffer) Clone() *SecureBuffer {
+    return SecureCopy(b.Bytes())
+}
+
+// Wipe overwrites the contents and unlocks the memory. The buffer is
+// empty afterwards. Wiping twice, or a nil buffer, does nothing.
+func (b *SecureBuffer) Wipe() {
+    if b == nil || b.data == nil {
+        return
+    }
+
+    Zero(b.data)
+
+    if b.locked {
+        munlock(b.data)
+        b.locked = false
+    }
+
+    b.data = nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package lock
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestSecureBuffer(t *testing.T) {
+    data := []byte("correct horse battery staple")
+    b := SecureCopy(data)
+    contents := b.Bytes()
+
+    if !bytes.Equal(contents, data) || b.Len() != len(data) || !b.Equal(data) || b.Equal([]byte("wrong")) {
+        t.Fatalf("Unexpected contents %q", contents)
+    }
+
+    // The buffer holds a copy.
+    Zero(data)
+
+    if !b.Equal([]byte("correct horse battery staple")) {
+        t.Errorf("Buffer shares memory with the data it was copied from")
+    }
+
+    c := b.Clone()
+    b.Wipe()
+
+    if !bytes.Equal(contents, make([]byte, len(contents))) {
+        t.Errorf("Memory not overwritten: %q", contents)
+    }
+
+    if !b.Wiped() || b.Bytes() != nil || b.Len() != 0 || b.Locked() || b.Equal(nil) {
+        t.Errorf("Wiped buffer still holds something")
+    }
+
+    // Wiping again does nothing, nor does wiping the original wipe the
+    // clone.
+    b.Wipe()
+
+    if !c.Equal([]byte("correct horse battery staple")) {
+        t.Errorf("Clone wiped along with the original")
+    }
+
+    var none *SecureBuffer
+    none.Wipe()
+
+    if !none.Wiped() || none.Bytes() != nil {
+        t.Errorf("Nil buffer holds something")
+    }
+}
+
+func TestDecryptSecure(t *testing.T) {
+    l := Lock{Key: SecureCopy(Entropy(32))}
+
+    hexCiphertext, err := l.EncryptAndEncodeHex("s.token")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    base64Ciphertext, err := l.EncryptBase64([]byte("s.token"))
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    fromHex, err := l.DecryptHex(hexCiphertext)
+
+    if err != nil || !fromHex.Equal([]byte("s.token")) {
+        t.Errorf("Unexpected plaintext %q: %v", fromHex.Bytes(), err)
+    }
+
+    fromBase64, err := l.DecryptBase64(base64Ciphertext)
+
+    if err != nil || !fromBase64.Equal([]byte("s.token")) {
+        t.Errorf("Unexpected plaintext %q: %v", fromBase64.Bytes(), err)
+    }
+
+    // The plaintext is decrypted into the buffer itself, which is all
+    // there is to wipe.
+    plaintext := fromHex.Bytes()
+    fromHex.Wipe()
+
+    if !bytes.Equal(plaintext, make([]byte, len(plaintext))) {
+        t.Errorf("Plaintext not overwritten")
+    }
+
+    other := Lock{Key: SecureCopy(Entropy(32))}
+
+    if b, err := other.DecryptHex(hexCiphertext); err == nil || b != nil {
+        t.Errorf("Decrypted with the wrong key")
+    }
+
+    if b, err := l.DecryptBase64([]byte("not base64")); err == nil || b != nil {
+        t.Errorf("Decrypted garbage")
+    }
+}
+
+func TestSecureBufferJSON(t *testing.T) {
+    type secret struct {
+        Password *SecureBuffer `json:"password"`
+    }
+
+    type plain struct {
+        Password string `json:"password"`
+    }
+
+    for _, s := range []string{"", "banana", `quo"te\back/slash`, "tab\tnew\nline\x01", "<&>", "grüße ☃ 😀"} {
+        data, err := json.Marshal(secret{SecureCopy([]byte(s))})
+
+        if err != nil {
+            t.Fatalf("Marshal error: %s", err)
+        }
+
+        var p plain
+
+        if err = json.Unmarshal(data, &p); err != nil || p.Password != s {
+            t.Errorf("Encoded %q as %s: %v", s, data, err)
+        }
+
+        // What encoding/json writes, with all its escapes, is read back.
+        data, _ = json.Marshal(plain{s})
+        var d secret
+
+        if err = json.Unmarshal(data, &d); err != nil || !d.Password.Equal([]byte(s)) {
+            t.Errorf("Decoded %s as %q: %v", data, d.Password.Bytes(), err)
+        }
+    }
+
+    var d secret
+
+    if err := json.Unmarshal([]byte(`{"password":"😀 \ud800"}`), &d); err != nil || !d.Password.Equal([]byte("😀 �")) {
+        t.Errorf("Surrogates decoded as %q: %v", d.Password.Bytes(), err)
+    }
+
+    for _, bad := range []string{`{"password":1}`, `{"password":"\x"}`, `{"password":"\u12"}`} {
+        if err := json.Unmarshal([]byte(bad), &d); err == nil {
+            t.Errorf("Accepted %s", bad)
+        }
+    }
+
+    if s := fmt.Sprintf("%v %s %#v", d.Password, d.Password, d); strings.Contains(s, "😀") {
+        t.Errorf("Contents printed: %s", s)
+    }
+}
@@ -0,0 +1,205 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package lock
+
+import (
+    "errors"
+    "fmt"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// Secrets in entries, such as passwords, are kept in SecureBuffers
+// from decryption to display, so they are encoded and decoded as JSON
+// strings directly, rather than by way of a Go string.
+
+var ErrJSON = errors.New("secret is not a JSON string")
+
+const hexDigits = "0123456789abcdef"
+
+// Format prints a placeholder, so that a buffer passed to the logger,
+// or fmt, never reveals its contents.
+func (b *SecureBuffer) Format(f fmt.State, c rune) {
+    fmt.Fprint(f, "[secret]")
+}
+
+// MarshalJSON encodes the contents as a JSON string.
+func (b *SecureBuffer) MarshalJSON() ([]byte, error) {
+    data := b.Bytes()
+    size := 2
+
+    for _, c := range data {
+        switch {
+        case c == '"' || c == '\\':
+            size += 2
+        case c < 0x20:
+            size += 6
+        default:
+            size++
+        }
+    }
+
+    // Sized up front, so that no partial copies are left behind.
+    out := make([]byte, 0, size)
+    out = append(out, '"')
+
+    for _, c := range data {
+        switch {
+        case c == '"' || c == '\\':
+            out = append(out, '\\', c)
+        case c < 0x20:
+            out = append(out, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+        default:
+            out = append(out, c)
+        }
+    }
+
+    return append(out, '"'), nil
+}
+
+// UnmarshalJSON decodes a JSON string into locked memory, replacing the
+// contents.
+func (b *SecureBuffer) UnmarshalJSON(data []byte) error {
+    if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+        return ErrJSON
+    }
+
+    // Unescaping never makes a string longer.
+    tmp := NewSecureBuffer(len(data))
+    defer tmp.Wipe()
+
+    n, err := unquote(tmp.data, data[1:len(data)-1])
+
+    if err != nil {
+        return err
+    }
+
+    b.alloc(n)
+    copy(b.data, tmp.data)
+
+    return nil
+}
+
+// Unescapes the contents of a JSON string into dst and returns their
+// length. Invalid surrogates become U+FFFD, as with encoding/json.
+func unquote(dst []byte, src []byte) (int, error) {
+    n := 0
+
+    for i := 0; i < len(src); i++ {
+        c := src[i]
+
+        if c < 0x20 || c == '"' {
+            return 0, ErrJSON
+        }
+
+        if c != '\\' {
+            dst[n] = c
+            n++
+            continue
+        }
+
+        if i++; i == len(src) {
+            return 0, ErrJSON
+        }
+
+        switch src[i] {
+        case '"', '\\', '/':
+            dst[n] = src[i]
+        case 'b':
+            dst[n] = '\b'
+        case 'f':
+            dst[n] = '\f'
+        case 'n':
+            dst[n] = '\n'
+        case 'r':
+            dst[n] = '\r'
+        case 't':
+            dst[n] = '\t'
+        case 'u':
+            r, ok := hex4(src[i+1:])
+
+            if !ok {
+                return 0, ErrJSON
+            }
+
+            i += 4
+
+            if utf16.IsSurrogate(r) {
+                high := r
+                r = utf8.RuneError
+
+                if i+6 < len(src) && src[i+1] == '\\' && src[i+2] == 'u' {
+                    if low, ok := hex4(src[i+3:]); ok {
+                        if pair := utf16.DecodeRune(high, low); pair != utf8.RuneError {
+                            r = pair
+                            i += 6
+                        }
+                    }
+                }
+            }
+
+            n += utf8.EncodeRune(dst[n:], r)
+            continue
+        default:
+            return 0, ErrJSON
+        }
+
+        n++
+    }
+
+    return n, nil
+}
+
+// Parses four hex digits, without going through a string.
+func hex4(src []byte) (rune, bool) {
+    if len(src) < 4 {
+        return 0, false
+    }
+
+    var r rune
+
+    for _, c := range src[:4] {
+        switch {
+        case '0' <= c && c <= '9':
+            c -= '0'
+        case 'a' <= c && c <= 'f':
+            c -= 'a' - 10
+        case 'A' <= c && c <= 'F':
+            c -= 'A' - 10
+        default:
+            return 0, false
+        }
+
+        r = r<<4 | rune(c)
+    }
+
+    return r, true
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package lock
+
+import (
+    "crypto/rand"
+    "errors"
+)
+
+// Entries are encrypted with a random data key rather than with the
+// key derived from the password. The data key is stored wrapped, i.e.,
+// encrypted, with the derived key, the key encryption key. Changing
+// the password only wraps the data key anew, and each way to unlock
+// the vault, e.g., a password or a key file, wraps the same data key
+// with its own key encryption key.
+
+const (
+    DataKeyLength = 32
+)
+
+var (
+    ErrWrappedKey = errors.New("unwrapped key has the wrong length")
+)
+
+// NewDataKey generates a random data key.
+func NewDataKey() (*SecureBuffer, error) {
+    key := NewSecureBuffer(DataKeyLength)
+
+    if _, err := rand.Read(key.Bytes()); err != nil {
+        key.Wipe()
+        return nil, err
+    }
+
+    return key, nil
+}
+
+// WrapKey encrypts a data key with the key of the lock.
+func (l *Lock) WrapKey(key *SecureBuffer) (string, error) {
+    return l.EncryptHex(key.Bytes())
+}
+
+// UnwrapKey decrypts a data key wrapped with the key of the lock. It
+// fails if the lock has the wrong key. The caller has to wipe the data
+// key.
+func (l *Lock) UnwrapKey(wrapped string) (*SecureBuffer, error) {
+    key, err := l.DecryptHex(wrapped)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if key.Len() != DataKeyLength {
+        key.Wipe()
+        return nil, ErrWrappedKey
+    }
+
+    return key, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package lock
+
+import (
+    "testing"
+)
+
+func TestWrapKey(t *testing.T) {
+    key, err := NewDataKey()
+
+    if err != nil || key.Len() != DataKeyLength {
+        t.Fatalf("No data key: %v", err)
+    }
+
+    other, _ := NewDataKey()
+
+    if other.Equal(key.Bytes()) {
+        t.Errorf("Data keys are not random")
+    }
+
+    kek := Lock{Key: SecureCopy(Entropy(32))}
+    wrapped, err := kek.WrapKey(key)
+
+    if err != nil {
+        t.Fatalf("Wrap error: %s", err)
+    }
+
+    unwrapped, err := kek.UnwrapKey(wrapped)
+
+    if err != nil || !unwrapped.Equal(key.Bytes()) {
+        t.Errorf("Unwrapped another key: %v", err)
+    }
+
+    wrong := Lock{Key: SecureCopy(Entropy(32))}
+
+    if _, err = wrong.UnwrapKey(wrapped); err == nil {
+        t.Errorf("Unwrapped with the wrong key")
+    }
+
+    // Something else encrypted with the key is not a data key.
+    token, _ := kek.EncryptHex([]byte("s.token"))
+
+    if _, err = kek.UnwrapKey(token); err != ErrWrappedKey {
+        t.Errorf("Unwrapped a token: %v", err)
+    }
+}
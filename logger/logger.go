@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package logger
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+type Level int
+
+const (
+    LevelDebug Level = iota
+    LevelInfo
+    LevelWarn
+    LevelError
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+var (
+    level  = int32(LevelInfo)
+    mutex  sync.Mutex
+    output = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func (l Level) String() string {
+    if l < LevelDebug || l > LevelError {
+        return "UNKNOWN"
+    }
+    return levelNames[l]
+}
+
+// ParseLevel converts a level name, as found in the configuration, to
+// a level. The empty string yields the default level.
+func ParseLevel(name string) (Level, error) {
+    if name == "" {
+        return LevelInfo, nil
+    }
+
+    for i, n := range levelNames {
+        if strings.EqualFold(n, name) {
+            return Level(i), nil
+        }
+    }
+
+    return LevelInfo, errors.New("unknown log level: " + name)
+}
+
+func SetLevel(l Level) {
+    atomic.StoreInt32(&level, int32(l))
+}
+
+func GetLevel() Level {
+    return Level(atomic.LoadInt32(&level))
+}
+
+func SetOutput(w io.Writer) {
+    mutex.Lock()
+    output = log.New(w, "", log.LstdFlags)
+    mutex.Unlock()
+}
+
+func write(l Level, v ...interface{}) {
+    if l < GetLevel() {
+        return
+    }
+
+    // Arguments are formatted with Sprintln, so that the redacting
+    // types in this package get to format themselves.
+    message := l.String() + " " + fmt.Sprintln(v...)
+
+    mutex.Lock()
+    defer mutex.Unlock()
+
+    output.Output(3, message)
+}
+
+func Debug(v ...interface{}) {
+    write(LevelDebug, v...)
+}
+
+func Info(v ...interface{}) {
+    write(LevelInfo, v...)
+}
+
+func Warn(v ...interface{}) {
+    write(LevelWarn, v...)
+}
+
+func Error(v ...interface{}) {
+    write(LevelError, v...)
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestLevels(t *testing.T) {
+    var b bytes.Buffer
+    SetOutput(&b)
+    SetLevel(LevelWarn)
+    defer SetLevel(LevelInfo)
+
+    Info("not shown")
+    Warn("shown")
+
+    if strings.Contains(b.String(), "not shown") {
+        t.Errorf("Message below level was written")
+    }
+
+    if !strings.Contains(b.String(), "WARN shown") {
+        t.Errorf("Message at level was not written, got: %s", b.String())
+    }
+}
+
+func TestRedactedInDebugMode(t *testing.T) {
+    var b bytes.Buffer
+    SetOutput(&b)
+    SetLevel(LevelDebug)
+    defer SetLevel(LevelInfo)
+
+    secrets := []string{"s3cr3t-token", "hunter2", "file contents", "github"}
+
+    Debug(Token(secrets[0]), Password(secrets[1]), File(secrets[2]), Name(secrets[3]))
+    fmt.Fprintf(&b, "%v %+v %#v %s %q %x\n",
+        Token(secrets[0]), Password(secrets[1]), File(secrets[2]),
+        Name(secrets[3]), Password(secrets[1]), Token(secrets[0]))
+
+    for _, secret := range secrets {
+        if strings.Contains(b.String(), secret) {
+            t.Errorf("Secret %s leaked: %s", secret, b.String())
+        }
+    }
+}
+
+func TestNameFingerprint(t *testing.T) {
+    SetLevel(LevelDebug)
+    defer SetLevel(LevelInfo)
+
+    github := fmt.Sprint(Name("github"))
+
+    if github != fmt.Sprint(Name("github")) || github == fmt.Sprint(Name("gmail")) {
+        t.Errorf("Fingerprints do not tell names apart: %s", github)
+    }
+
+    // Not a plain digest, which could be looked up.
+    digest := sha256.Sum256([]byte("github"))
+
+    if strings.Contains(github, hex.EncodeToString(digest[:4])) {
+        t.Errorf("Fingerprint is not keyed: %s", github)
+    }
+}
+
+func TestParseLevel(t *testing.T) {
+    l, err := ParseLevel("debug")
+
+    if err != nil || l != LevelDebug {
+        t.Errorf("Could not parse level")
+    }
+
+    if _, err := ParseLevel("verbose"); err == nil {
+        t.Errorf("Unknown level was accepted")
+    }
+}
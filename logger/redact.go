@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package logger
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+)
+
+// Key of the fingerprints of names, drawn anew for every process, so
+// that a fingerprint cannot be matched against the digests of likely
+// names, such as "github".
+var fingerprintKey = func() []byte {
+    key := make([]byte, sha256.Size)
+    rand.Read(key)
+    return key
+}()
+
+// The types below wrap sensitive values before they are handed to the
+// logger (or to fmt in general). They implement fmt.Formatter, so any
+// verb, including %#v, prints a placeholder instead of the value. Not
+// even debug mode reveals secret material; it only adds information
+// which is useful for correlating log lines.
+type (
+    // Token is a (decrypted) Vault token.
+    Token string
+
+    // Password is a password or any other secret string, such as an
+    // OTP seed.
+    Password string
+
+    // File is the contents of a stored file or key.
+    File []byte
+
+    // Name is an entry name. Names are not secret in the same sense
+    // as passwords, but they reveal where the user has accounts.
+    Name string
+)
+
+func (t Token) Format(f fmt.State, c rune) {
+    fmt.Fprint(f, "[token]")
+}
+
+func (p Password) Format(f fmt.State, c rune) {
+    fmt.Fprint(f, "[password]")
+}
+
+func (b File) Format(f fmt.State, c rune) {
+    // The size of a file is visible on the server anyway.
+    if GetLevel() == LevelDebug {
+        fmt.Fprintf(f, "[file, %d bytes]", len(b))
+        return
+    }
+
+    fmt.Fprint(f, "[file]")
+}
+
+func (n Name) Format(f fmt.State, c rune) {
+    // In debug mode, print a short fingerprint of the name, so that
+    // operations on the same entry can be matched up within a run.
+    if GetLevel() == LevelDebug {
+        mac := hmac.New(sha256.New, fingerprintKey)
+        mac.Write([]byte(n))
+        fmt.Fprintf(f, "[name %s]", hex.EncodeToString(mac.Sum(nil)[:4]))
+        return
+    }
+
+    fmt.Fprint(f, "[name]")
+}
@@ -33,9 +33,14 @@ package main
 import (
     "github.com/murlokswarm/app"
     _ "github.com/murlokswarm/mac"
-    "log"
+    "os"
 
+    "pass/lock"
+    "pass/logger"
+    "pass/observe"
+    "pass/urlmatch"
     "pass/util"
+    "pass/vault"
 )
 
 type (
@@ -56,6 +61,12 @@ const (
 
 var config util.Configuration
 
+// Finds entries by URL.
+var matcher = urlmatch.NewMatcher(urlmatch.DefaultEquivalents)
+
+// Receives timings from the rest client and the lock.
+var observer observe.Observer = observe.Nop{}
+
 func GetImageName(filename string) string {
     if !pass.Icons[filename] {
         filename = "default"
@@ -63,18 +74,71 @@ func GetImageName(filename string) string {
     return filename
 }
 
+func setupObserver(c util.Configuration) observe.Observer {
+    observers := observe.Multi{}
+
+    if c.Metrics.Listen != "" {
+        p := observe.NewPrometheus()
+        observers = append(observers, p)
+
+        go func() {
+            if err := p.Serve(c.Metrics.Listen); err != nil {
+                logger.Error(err)
+            }
+        }()
+    }
+
+    if c.Metrics.Trace != "" {
+        f, err := os.OpenFile(c.Metrics.Trace,
+            os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+        if err != nil {
+            logger.Error(err)
+        } else {
+            observers = append(observers, observe.NewTracer(f))
+        }
+    }
+
+    return observers
+}
+
 func main() {
     // Pass is locked by default.
     pass.Locked = true
 
     // Load the config.
-    config, _ = util.GetConfig(app.Resources())
+    var err error
+    config, err = util.GetConfig(app.Resources())
 
-    log.Println(config)
+    if err != nil {
+        logger.Error("Could not load configuration:", err)
+    }
 
-    pass.Icons = util.ListAvailableIcons(app.Resources())
+    // Set the log level. Even in debug mode secrets are never logged.
+    level, err := logger.ParseLevel(config.LogLevel)
 
-    log.Println(pass.Icons)
+    if err != nil {
+        logger.Warn(err)
+    }
+
+    logger.SetLevel(level)
+    logger.Info("Configuration loaded:", config)
+
+    // Setup metrics and tracing, if configured.
+    observer = setupObserver(config)
+    lock.Observer = observer
+
+    matcher = config.Matcher()
+    setupAutoLock(config)
+
+    pass.Icons, err = util.ListAvailableIcons(app.Resources())
+
+    if err != nil {
+        logger.Error("Could not load icons:", err)
+        pass.Icons = map[string]bool{}
+    }
+
+    logger.Debug("Icons:", len(pass.Icons))
 
     app.OnLaunch = func() {
         // Creates the AppMainMenu component.
@@ -97,6 +161,10 @@ func main() {
     }
 
     app.Run()
+
+    if sshAgent != nil {
+        sshAgent.Close()
+    }
 }
 
 func newMainWindow() app.Contexter {
@@ -113,13 +181,15 @@ func newMainWindow() app.Contexter {
         },
     })
 
-    log.Println("NewWindow")
-
-    // Create component...
-    ps := &UnlockScreen{}
+    logger.Debug("NewWindow")
 
-    // ...and mount to window
-    win.Mount(ps)
+    // Skip the password if the agent keeps Pass unlocked, and
+    // otherwise ask for it.
+    if client, err := vault.Resume(config); err == nil {
+        unlocked(client)
+    } else {
+        win.Mount(&UnlockScreen{})
+    }
 
     // Return to context
     return win
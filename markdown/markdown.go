@@ -0,0 +1,287 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package markdown renders a safe subset of Markdown to HTML, for
+// showing notes. All text is escaped, so a note can never inject
+// markup or scripts, and only http, https and mailto links are kept.
+//
+// Supported are headings, paragraphs, emphasis, inline and fenced
+// code, block quotes, lists, task lists, rules and links.
+package markdown
+
+import (
+    "html"
+    "net/url"
+    "regexp"
+    "strings"
+)
+
+var (
+    heading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+    rule      = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+    unordered = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+    ordered   = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+    task      = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+    fence     = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+// Render converts Markdown to HTML.
+func Render(text string) string {
+    lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+
+    var (
+        out       strings.Builder
+        paragraph []string
+        list      string
+    )
+
+    flush := func() {
+        if len(paragraph) > 0 {
+            out.WriteString("<p>" + inline(strings.Join(paragraph, "\n")) + "</p>\n")
+            paragraph = nil
+        }
+    }
+
+    closeList := func() {
+        if list != "" {
+            out.WriteString("</" + list + ">\n")
+            list = ""
+        }
+    }
+
+    openList := func(tag string) {
+        if list != tag {
+            closeList()
+            out.WriteString("<" + tag + ">\n")
+            list = tag
+        }
+    }
+
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+        trimmed := strings.TrimSpace(line)
+
+        switch {
+        case trimmed == "":
+            flush()
+            closeList()
+        case fence.MatchString(line):
+            flush()
+            closeList()
+
+            marker := fence.FindStringSubmatch(line)[1]
+            code := []string{}
+
+            for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), marker); i++ {
+                code = append(code, lines[i])
+            }
+
+            out.WriteString("<pre><code>" +
+                html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+        case heading.MatchString(trimmed):
+            flush()
+            closeList()
+
+            m := heading.FindStringSubmatch(trimmed)
+            level := string('0' + byte(len(m[1])))
+            out.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+        case rule.MatchString(line):
+            flush()
+            closeList()
+            out.WriteString("<hr/>\n")
+        case strings.HasPrefix(trimmed, ">"):
+            flush()
+            closeList()
+
+            quote := []string{}
+
+            for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+                q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+                quote = append(quote, strings.TrimPrefix(q, " "))
+            }
+
+            i--
+            out.WriteString("<blockquote>\n" + Render(strings.Join(quote, "\n")) + "</blockquote>\n")
+        case unordered.MatchString(line):
+            flush()
+            openList("ul")
+            out.WriteString("<li>" + item(unordered.FindStringSubmatch(line)[1]) + "</li>\n")
+        case ordered.MatchString(line):
+            flush()
+            openList("ol")
+            out.WriteString("<li>" + item(ordered.FindStringSubmatch(line)[1]) + "</li>\n")
+        case list != "" && len(paragraph) == 0 && line != trimmed:
+            // An indented line continues the last list item. We
+            // simply render it as a separate line of it.
+            out.WriteString("<li class=\"continued\">" + inline(trimmed) + "</li>\n")
+        default:
+            closeList()
+            paragraph = append(paragraph, trimmed)
+        }
+    }
+
+    flush()
+    closeList()
+
+    return out.String()
+}
+
+// A list item, possibly with a checkbox.
+func item(text string) string {
+    m := task.FindStringSubmatch(text)
+
+    if m == nil {
+        return inline(text)
+    }
+
+    checked := ""
+
+    if m[1] != " " {
+        checked = ` checked="checked"`
+    }
+
+    return `<input type="checkbox" disabled="disabled"` + checked + `/> ` + inline(m[2])
+}
+
+// Characters which can be escaped with a backslash.
+const punctuation = "\\`*_{}[]()#+-.!>~|"
+
+// Renders emphasis, code and links within a block. Everything else is
+// escaped.
+func inline(s string) string {
+    var out strings.Builder
+
+    for i := 0; i < len(s); {
+        c := s[i]
+
+        // Underscores within words, as in snake_case, are no emphasis.
+        if c == '_' && i > 0 && isWordByte(s[i-1]) {
+            out.WriteByte(c)
+            i++
+            continue
+        }
+
+        switch {
+        case c == '\\' && i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0:
+            out.WriteString(html.EscapeString(s[i+1 : i+2]))
+            i += 2
+            continue
+        case c == '\n':
+            out.WriteString("<br/>\n")
+            i++
+            continue
+        case c == '`':
+            if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+                out.WriteString("<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>")
+                i += end + 2
+                continue
+            }
+        case (c == '*' || c == '_') && i+1 < len(s) && s[i+1] == c:
+            marker := s[i : i+2]
+
+            if end := strings.Index(s[i+2:], marker); end > 0 {
+                out.WriteString("<strong>" + inline(s[i+2:i+2+end]) + "</strong>")
+                i += end + 4
+                continue
+            }
+        case c == '*' || c == '_':
+            if end := strings.IndexByte(s[i+1:], c); end > 0 && s[i+1] != ' ' {
+                out.WriteString("<em>" + inline(s[i+1:i+1+end]) + "</em>")
+                i += end + 2
+                continue
+            }
+        case c == '~' && strings.HasPrefix(s[i:], "~~"):
+            if end := strings.Index(s[i+2:], "~~"); end > 0 {
+                out.WriteString("<del>" + inline(s[i+2:i+2+end]) + "</del>")
+                i += end + 4
+                continue
+            }
+        case c == '[':
+            if n, link := parseLink(s[i:]); n > 0 {
+                out.WriteString(link)
+                i += n
+                continue
+            }
+        }
+
+        out.WriteString(html.EscapeString(s[i : i+1]))
+        i++
+    }
+
+    return out.String()
+}
+
+func isWordByte(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Parses a link of the form [text](url) at the start of s and returns
+// its length and HTML. Links with other schemes are shown as text.
+func parseLink(s string) (int, string) {
+    textEnd := strings.Index(s, "](")
+
+    if textEnd < 0 {
+        return 0, ""
+    }
+
+    urlEnd := strings.IndexByte(s[textEnd+2:], ')')
+
+    if urlEnd < 0 {
+        return 0, ""
+    }
+
+    text := inline(s[1:textEnd])
+    target := strings.TrimSpace(s[textEnd+2 : textEnd+2+urlEnd])
+    n := textEnd + 3 + urlEnd
+
+    if !SafeURL(target) {
+        return n, text
+    }
+
+    return n, `<a href="` + html.EscapeString(target) + `">` + text + `</a>`
+}
+
+// SafeURL reports whether a link target may be rendered.
+func SafeURL(target string) bool {
+    u, err := url.Parse(target)
+
+    if err != nil {
+        return false
+    }
+
+    switch strings.ToLower(u.Scheme) {
+    case "http", "https":
+        return u.Host != ""
+    case "mailto":
+        return u.Opaque != ""
+    }
+
+    return false
+}
@@ -0,0 +1,54 @@
+package markdown
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestRender(t *testing.T) {
+    cases := map[string]string{
+        "# Title #":                    "<h1>Title</h1>\n",
+        "### C#":                       "<h3>C#</h3>\n",
+        "Some *emphasis* and **bold**": "<p>Some <em>emphasis</em> and <strong>bold</strong></p>\n",
+        "snake_case_name":              "<p>snake_case_name</p>\n",
+        "Use `a < b`":                  "<p>Use <code>a &lt; b</code></p>\n",
+        "line one\nline two":           "<p>line one<br/>\nline two</p>\n",
+        "- one\n- two":                 "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n",
+        "1. one\n2. two":               "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n",
+        "- [x] done":                   "<ul>\n<li><input type=\"checkbox\" disabled=\"disabled\" checked=\"checked\"/> done</li>\n</ul>\n",
+        "> quoted":                     "<blockquote>\n<p>quoted</p>\n</blockquote>\n",
+        "---":                          "<hr/>\n",
+        "```\n<b>code</b>\n```":        "<pre><code>&lt;b&gt;code&lt;/b&gt;</code></pre>\n",
+        "\\*not emphasis\\*":           "<p>*not emphasis*</p>\n",
+        "[Pass](https://github.com)":   "<p><a href=\"https://github.com\">Pass</a></p>\n",
+    }
+
+    for in, expected := range cases {
+        if out := Render(in); out != expected {
+            t.Errorf("Render(%q) = %q, expected %q", in, out, expected)
+        }
+    }
+}
+
+func TestRenderIsSafe(t *testing.T) {
+    unsafe := []string{
+        "<script>alert(1)</script>",
+        "[click](javascript:alert(1))",
+        "[x](data:text/html,<script>)",
+        "**<img src=x onerror=alert(1)>**",
+        "# <iframe>",
+        "- <style>",
+        "`</code><script>`",
+        "[\"><script>](https://a.com/\"onmouseover=\")",
+    }
+
+    for _, in := range unsafe {
+        out := Render(in)
+
+        for _, tag := range []string{"<script", "<img", "<iframe", "<style", "javascript:", "data:", "\"onmouseover"} {
+            if strings.Contains(out, tag) {
+                t.Errorf("Render(%q) = %q contains %s", in, out, tag)
+            }
+        }
+    }
+}
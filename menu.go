@@ -52,6 +52,12 @@ func (m *AppMainMenu) Render() string {
                   shortcut="meta+n"
                   onclick="ShowAddView" 
                   separator="true" />
+        <menuitem label="Lock" 
+                  shortcut="meta+l"
+                  onclick="Lock" />
+        <menuitem label="Upgrade Vault" 
+                  onclick="UpgradeVault" 
+                  separator="true" />
         <menuitem label="Quit" shortcut="meta+q" selector="terminate:" />     
     </menu>
     <EditMenu />
@@ -73,6 +79,14 @@ func (m *AppMainMenu) ShowAddView() {
     }
 }
 
+func (m *AppMainMenu) Lock() {
+    Lock()
+}
+
+func (m *AppMainMenu) UpgradeVault() {
+    UpgradeVault()
+}
+
 func (m *AppMainMenu) ShowAboutView() {
     s := About{}
     win.Mount(&s)
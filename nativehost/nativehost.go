@@ -0,0 +1,409 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package nativehost is the native messaging host through which the
+// browser extension fills in logins. The browser starts the host and
+// exchanges JSON messages with it over standard input and output, each
+// preceded by its length.
+//
+// Only a paired extension may use the host. Pairing needs a code which
+// the user creates with pass-native-host -pair; the extension receives
+// a key with which it identifies itself from then on. Logins are only
+// handed out for pages matching one of their URLs, see urlmatch.
+//
+// See https://developer.chrome.com/docs/extensions/develop/concepts/native-messaging
+// for the protocol.
+package nativehost
+
+import (
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "io"
+    "pass/rest"
+    "pass/urlmatch"
+    "sort"
+)
+
+// Message types.
+const (
+    TypePair  = "pair"
+    TypeHello = "hello"
+    TypeFind  = "find"
+    TypeGet   = "get"
+    TypeSave  = "save"
+)
+
+// HostName is the name under which the host is registered with the
+// browsers.
+const HostName = "pass_desktop"
+
+// MaximumMessageSize is the limit browsers set for messages from the
+// host; we apply it to both directions.
+const MaximumMessageSize = 1024 * 1024
+
+var (
+    ErrMessageSize = errors.New("message too large")
+    ErrNotPaired   = errors.New("extension is not paired")
+    ErrNoMatch     = errors.New("entry is not for this page")
+    ErrExists      = errors.New("an entry with this name exists")
+    ErrLocked      = errors.New("Pass is locked")
+    ErrType        = errors.New("unknown message type")
+    ErrMissing     = errors.New("missing username or password")
+)
+
+// A Vault holds the entries; it is implemented by rest.Client.
+type Vault interface {
+    VaultReadAll() ([]*rest.DecodedEntry, error)
+    VaultFindSecret(name string, id string) (*rest.DecodedEntry, error)
+    VaultWriteSecret(data *rest.DecodedEntry) error
+}
+
+// A Request is a message from the extension. The id is echoed in the
+// response, so that the extension can tell responses apart.
+type Request struct {
+    ID   string `json:"id,omitempty"`
+    Type string `json:"type"`
+
+    // Pairing and identification.
+    Code   string `json:"code,omitempty"`
+    Name   string `json:"name,omitempty"`
+    Client string `json:"client,omitempty"`
+    Key    string `json:"key,omitempty"`
+
+    // The page and login.
+    URL      string `json:"url,omitempty"`
+    Entry    string `json:"entry,omitempty"`
+    Username string `json:"username,omitempty"`
+    Password string `json:"password,omitempty"`
+}
+
+// A Login is an entry found for a page, without its password.
+type Login struct {
+    Entry    string `json:"entry"`
+    Username string `json:"username"`
+}
+
+// A Response answers a request. Error is set if it failed.
+type Response struct {
+    ID    string `json:"id,omitempty"`
+    Error string `json:"error,omitempty"`
+
+    Client string `json:"client,omitempty"`
+    Key    string `json:"key,omitempty"`
+
+    Logins   []Login `json:"logins,omitempty"`
+    Entry    string  `json:"entry,omitempty"`
+    Username string  `json:"username,omitempty"`
+    Password string  `json:"password,omitempty"`
+}
+
+// ReadMessage reads a message. The length is in native byte order,
+// which is little-endian on all platforms Pass runs on.
+func ReadMessage(r io.Reader, v interface{}) error {
+    var length uint32
+
+    if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+        return err
+    }
+
+    if length > MaximumMessageSize {
+        return ErrMessageSize
+    }
+
+    data := make([]byte, length)
+
+    if _, err := io.ReadFull(r, data); err != nil {
+        return err
+    }
+
+    return json.Unmarshal(data, v)
+}
+
+// WriteMessage writes a message.
+func WriteMessage(w io.Writer, v interface{}) error {
+    data, err := json.Marshal(v)
+
+    if err != nil {
+        return err
+    }
+
+    if len(data) > MaximumMessageSize {
+        return ErrMessageSize
+    }
+
+    if err = binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+        return err
+    }
+
+    _, err = w.Write(data)
+    return err
+}
+
+// Manifest returns the manifest registering the host at path with the
+// browser, chrome or firefox, for the extension.
+func Manifest(browser string, path string, extension string) (interface{}, error) {
+    manifest := map[string]interface{}{
+        "name":        HostName,
+        "description": "Pass Desktop",
+        "path":        path,
+        "type":        "stdio",
+    }
+
+    switch browser {
+    case "chrome":
+        manifest["allowed_origins"] = []string{"chrome-extension://" + extension + "/"}
+    case "firefox":
+        manifest["allowed_extensions"] = []string{extension}
+    default:
+        return nil, errors.New("unknown browser " + browser)
+    }
+
+    return manifest, nil
+}
+
+// Host answers the extension for one session, that is, as long as the
+// browser keeps it running.
+type Host struct {
+    // Open returns the unlocked vault. It is called for every request,
+    // so that the host stops handing out logins when Pass is locked.
+    Open func() (Vault, error)
+
+    Pairings *Pairings
+
+    // Finds the logins for a page; by default, with the default
+    // equivalent domains.
+    Matcher *urlmatch.Matcher
+
+    // Folder for new logins; by default, they are not in a folder.
+    Folder string
+
+    paired bool
+}
+
+// Serve answers requests until the browser closes standard input.
+func (h *Host) Serve(r io.Reader, w io.Writer) error {
+    for {
+        var req Request
+
+        err := ReadMessage(r, &req)
+
+        if err == io.EOF {
+            return nil
+        }
+
+        if err != nil {
+            return err
+        }
+
+        if err = WriteMessage(w, h.Handle(&req)); err != nil {
+            return err
+        }
+    }
+}
+
+// Handle answers a request.
+func (h *Host) Handle(req *Request) *Response {
+    res, err := h.handle(req)
+
+    if err != nil {
+        res = &Response{Error: err.Error()}
+    }
+
+    res.ID = req.ID
+    return res
+}
+
+func (h *Host) handle(req *Request) (*Response, error) {
+    switch req.Type {
+    case TypePair:
+        client, key, err := h.Pairings.Pair(req.Code, req.Name)
+
+        if err != nil {
+            return nil, err
+        }
+
+        h.paired = true
+        return &Response{Client: client, Key: key}, nil
+    case TypeHello:
+        if !h.Pairings.Verify(req.Client, req.Key) {
+            return nil, ErrNotPaired
+        }
+
+        h.paired = true
+        return &Response{}, nil
+    case TypeFind, TypeGet, TypeSave:
+    default:
+        return nil, ErrType
+    }
+
+    if !h.paired {
+        return nil, ErrNotPaired
+    }
+
+    if _, err := urlmatch.Parse(req.URL); err != nil {
+        return nil, err
+    }
+
+    v, err := h.Open()
+
+    if err != nil {
+        return nil, err
+    }
+
+    switch req.Type {
+    case TypeFind:
+        return h.find(v, req.URL)
+    case TypeGet:
+        return h.get(v, req.URL, req.Entry)
+    default:
+        return h.save(v, req)
+    }
+}
+
+func (h *Host) matcher() *urlmatch.Matcher {
+    if h.Matcher == nil {
+        h.Matcher = urlmatch.NewMatcher(urlmatch.DefaultEquivalents)
+    }
+
+    return h.Matcher
+}
+
+// Whether a login is for the page. Other entry types never are.
+func (h *Host) matches(d *rest.DecodedEntry, page string) bool {
+    if d.Type != "" && d.Type != rest.TypeAccount {
+        return false
+    }
+
+    u, err := urlmatch.Parse(page)
+
+    if err != nil {
+        return false
+    }
+
+    score, _ := h.matcher().Match(d.Target(), u)
+    return score != urlmatch.ScoreNone
+}
+
+// Lists the logins for the page, best match first, without their
+// passwords.
+func (h *Host) find(v Vault, page string) (*Response, error) {
+    entries, err := v.VaultReadAll()
+
+    if err != nil {
+        return nil, err
+    }
+
+    var logins []*rest.DecodedEntry
+
+    for _, d := range entries {
+        if d.Type == "" || d.Type == rest.TypeAccount {
+            logins = append(logins, d)
+        }
+    }
+
+    // Equally good matches are sorted by name.
+    sort.SliceStable(logins, func(i, j int) bool {
+        return logins[i].Name.Text < logins[j].Name.Text
+    })
+
+    if logins, err = rest.RankByURL(logins, page, h.matcher()); err != nil {
+        return nil, err
+    }
+
+    res := &Response{Logins: []Login{}}
+
+    for _, d := range logins {
+        res.Logins = append(res.Logins, Login{Entry: d.Name.Text, Username: d.Username})
+    }
+
+    return res, nil
+}
+
+// Hands out the username and password of a login, if it is for the
+// page. The extension can only ask for logins of the page it fills.
+func (h *Host) get(v Vault, page string, name string) (*Response, error) {
+    d, err := v.VaultFindSecret(name, rest.TypeAccount)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if !h.matches(d, page) {
+        return nil, ErrNoMatch
+    }
+
+    return &Response{Entry: d.Name.Text, Username: d.Username, Password: d.PasswordText()}, nil
+}
+
+// Saves a login, with the origin of the page as its URL. An existing
+// login for the page with the same name and username gets the new
+// password; any other entry of that name is left alone.
+func (h *Host) save(v Vault, req *Request) (*Response, error) {
+    if req.Username == "" || req.Password == "" {
+        return nil, ErrMissing
+    }
+
+    u, err := urlmatch.Parse(req.URL)
+
+    if err != nil {
+        return nil, err
+    }
+
+    name := req.Entry
+
+    if name == "" {
+        name = rest.JoinPath(h.Folder, u.Host)
+    }
+
+    d, err := v.VaultFindSecret(name, rest.TypeAccount)
+
+    switch {
+    case err == rest.ErrNotFound:
+        d = &rest.DecodedEntry{
+            Name:     &rest.Name{Text: name},
+            Type:     rest.TypeAccount,
+            Username: req.Username,
+            URLs:     []string{u.Origin()},
+        }
+    case err != nil:
+        return nil, err
+    case !h.matches(d, req.URL) || d.Username != req.Username:
+        return nil, ErrExists
+    }
+
+    d.SetPassword(req.Password)
+
+    if err = v.VaultWriteSecret(d); err != nil {
+        return nil, err
+    }
+
+    return &Response{Entry: d.Name.Text, Username: d.Username}, nil
+}
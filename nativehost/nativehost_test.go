@@ -0,0 +1,230 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package nativehost
+
+import (
+    "bytes"
+    "fmt"
+    "pass/lock"
+    "pass/rest"
+    "path/filepath"
+    "testing"
+)
+
+type fakeVault struct {
+    entries []*rest.DecodedEntry
+    writes  int
+}
+
+func (f *fakeVault) VaultReadAll() ([]*rest.DecodedEntry, error) {
+    return f.entries, nil
+}
+
+func (f *fakeVault) VaultFindSecret(name string, id string) (*rest.DecodedEntry, error) {
+    for _, d := range f.entries {
+        if d.Name.Text == name && (d.Type == id || d.Type == "" && id == rest.TypeAccount) {
+            return d, nil
+        }
+    }
+
+    return nil, rest.ErrNotFound
+}
+
+func (f *fakeVault) VaultWriteSecret(d *rest.DecodedEntry) error {
+    f.writes++
+
+    for _, e := range f.entries {
+        if e == d {
+            return nil
+        }
+    }
+
+    f.entries = append(f.entries, d)
+    return nil
+}
+
+func account(name string, user string, password string, urls ...string) *rest.DecodedEntry {
+    return &rest.DecodedEntry{
+        Name:     &rest.Name{Text: name},
+        Type:     rest.TypeAccount,
+        Username: user,
+        Password: lock.SecureCopy([]byte(password)),
+        URLs:     urls,
+    }
+}
+
+// Sets up a host which is already paired.
+func newHost(t *testing.T, v *fakeVault) *Host {
+    h := &Host{
+        Pairings: NewPairings(filepath.Join(t.TempDir(), "browser.json")),
+        Open:     func() (Vault, error) { return v, nil },
+    }
+
+    h.paired = true
+    return h
+}
+
+func TestMessages(t *testing.T) {
+    var buf bytes.Buffer
+
+    if err := WriteMessage(&buf, map[string]string{"type": "find"}); err != nil {
+        t.Fatal(err)
+    }
+
+    if !bytes.Equal(buf.Bytes()[:4], []byte{15, 0, 0, 0}) {
+        t.Errorf("Unexpected length prefix %v", buf.Bytes()[:4])
+    }
+
+    var req Request
+
+    if err := ReadMessage(&buf, &req); err != nil || req.Type != TypeFind {
+        t.Errorf("Unexpected request %v: %v", req, err)
+    }
+
+    buf.Reset()
+    buf.Write([]byte{0, 0, 0, 0x7f})
+
+    if err := ReadMessage(&buf, &req); err != ErrMessageSize {
+        t.Errorf("Accepted a huge message: %v", err)
+    }
+}
+
+func TestFindAndGet(t *testing.T) {
+    v := &fakeVault{entries: []*rest.DecodedEntry{
+        account("work", "carl", "a", "https://github.com"),
+        account("home", "grocid", "b", "github.com/login"),
+        account("evil", "x", "c", "https://github.com.evil.com"),
+        account("plain", "y", "d", "http://github.com"),
+        account("secure", "z", "e", "https://gist.github.com"),
+        {Name: &rest.Name{Text: "otp"}, Type: rest.TypeOTP, URLs: []string{"https://github.com"}},
+    }}
+
+    h := newHost(t, v)
+    res := h.Handle(&Request{ID: "1", Type: TypeFind, URL: "https://github.com/login"})
+
+    // Best match first, then by name; a login for http is fine on
+    // https and another host of the domain matches last.
+    expected := []Login{{"home", "grocid"}, {"plain", "y"}, {"work", "carl"}, {"secure", "z"}}
+
+    if res.Error != "" || res.ID != "1" || fmt.Sprint(res.Logins) != fmt.Sprint(expected) {
+        t.Errorf("Unexpected logins %v: %s", res.Logins, res.Error)
+    }
+
+    if res.Password != "" {
+        t.Errorf("Password handed out when finding")
+    }
+
+    res = h.Handle(&Request{Type: TypeGet, URL: "https://github.com/session", Entry: "work"})
+
+    if res.Error != "" || res.Username != "carl" || res.Password != "a" {
+        t.Errorf("Unexpected login %v", res)
+    }
+
+    res = h.Handle(&Request{Type: TypeGet, URL: "https://github.com.evil.com", Entry: "work"})
+
+    if res.Error != ErrNoMatch.Error() || res.Password != "" {
+        t.Errorf("Login handed out for another site: %v", res)
+    }
+
+    res = h.Handle(&Request{Type: TypeGet, URL: "http://github.com", Entry: "work"})
+
+    if res.Error != ErrNoMatch.Error() {
+        t.Errorf("Login for https handed out over http: %v", res)
+    }
+}
+
+func TestSave(t *testing.T) {
+    v := &fakeVault{entries: []*rest.DecodedEntry{
+        account("github.com", "carl", "old", "https://github.com"),
+    }}
+
+    h := newHost(t, v)
+    res := h.Handle(&Request{Type: TypeSave, URL: "https://github.com/login", Username: "carl", Password: "new"})
+
+    if res.Error != "" || !v.entries[0].HasPassword("new") || len(v.entries) != 1 {
+        t.Errorf("Password was not updated: %v", res)
+    }
+
+    res = h.Handle(&Request{Type: TypeSave, URL: "https://github.com/login", Username: "other", Password: "x"})
+
+    if res.Error != ErrExists.Error() {
+        t.Errorf("Login of another user was overwritten: %v", res)
+    }
+
+    h.Folder = "Web"
+    res = h.Handle(&Request{Type: TypeSave, URL: "https://gitlab.com:8443/", Username: "carl", Password: "y"})
+
+    if res.Error != "" || res.Entry != "Web/gitlab.com" || len(v.entries) != 2 {
+        t.Fatalf("Login was not added: %v", res)
+    }
+
+    if d := v.entries[1]; d.URLs[0] != "https://gitlab.com:8443" || d.Type != rest.TypeAccount {
+        t.Errorf("Unexpected entry %v", d)
+    }
+}
+
+func TestUnpaired(t *testing.T) {
+    v := &fakeVault{entries: []*rest.DecodedEntry{account("work", "carl", "a", "https://github.com")}}
+    h := newHost(t, v)
+    h.paired = false
+
+    for _, typ := range []string{TypeFind, TypeGet, TypeSave} {
+        res := h.Handle(&Request{Type: typ, URL: "https://github.com", Entry: "work", Username: "a", Password: "b"})
+
+        if res.Error != ErrNotPaired.Error() || res.Password != "" || res.Logins != nil {
+            t.Errorf("Unpaired extension could %s: %v", typ, res)
+        }
+    }
+
+    if res := h.Handle(&Request{Type: TypeHello, Client: "c", Key: "k"}); res.Error != ErrNotPaired.Error() {
+        t.Errorf("Unknown client was accepted")
+    }
+
+    code, _ := h.Pairings.NewCode()
+    res := h.Handle(&Request{Type: TypePair, Code: code, Name: "Firefox"})
+
+    if res.Error != "" || res.Client == "" || res.Key == "" {
+        t.Fatalf("Could not pair: %v", res)
+    }
+
+    // A new session identifies itself with the key.
+    other := newHost(t, v)
+    other.Pairings = h.Pairings
+    other.paired = false
+
+    if res = other.Handle(&Request{Type: TypeHello, Client: res.Client, Key: res.Key}); res.Error != "" {
+        t.Errorf("Paired extension was not accepted: %s", res.Error)
+    }
+
+    if res = other.Handle(&Request{Type: TypeFind, URL: "https://github.com"}); len(res.Logins) != 1 {
+        t.Errorf("Paired extension could not find logins: %v", res)
+    }
+}
@@ -0,0 +1,271 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package nativehost
+
+import (
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "os"
+    "pass/lock"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+const (
+    // PairingTimeout is how long a pairing code is valid.
+    PairingTimeout = 5 * time.Minute
+
+    codeLength   = 8
+    clientLength = 8
+    keyLength    = 32
+)
+
+var ErrPairingCode = errors.New("wrong or expired pairing code")
+
+// A Pairing is a paired extension. Only a hash of its key is kept.
+type Pairing struct {
+    Client  string    `json:"-"`
+    Name    string    `json:"name"`
+    Key     string    `json:"key"`
+    Created time.Time `json:"created"`
+}
+
+// A pairing code waiting to be used; only its hash is kept.
+type pendingCode struct {
+    Code    string    `json:"code"`
+    Expires time.Time `json:"expires"`
+}
+
+// The file holding the pairings.
+type pairingFile struct {
+    Pending *pendingCode `json:"pending,omitempty"`
+
+    Clients map[string]Pairing `json:"clients"`
+}
+
+// Pairings are kept in a file, only readable by the user, which is read
+// for every operation, as codes are created by another process than the
+// host using them.
+type Pairings struct {
+    Path string
+
+    mu  sync.Mutex
+    now func() time.Time
+}
+
+func NewPairings(path string) *Pairings {
+    return &Pairings{Path: path, now: time.Now}
+}
+
+func hash(s string) string {
+    sum := sha256.Sum256([]byte(s))
+    return hex.EncodeToString(sum[:])
+}
+
+func (p *Pairings) load() (*pairingFile, error) {
+    f := &pairingFile{}
+    data, err := ioutil.ReadFile(p.Path)
+
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+
+    if err == nil {
+        if err = json.Unmarshal(data, f); err != nil {
+            return nil, err
+        }
+    }
+
+    if f.Clients == nil {
+        f.Clients = make(map[string]Pairing)
+    }
+
+    return f, nil
+}
+
+// Replaces the file atomically.
+func (p *Pairings) save(f *pairingFile) error {
+    data, err := json.MarshalIndent(f, "", "    ")
+
+    if err != nil {
+        return err
+    }
+
+    if err = os.MkdirAll(filepath.Dir(p.Path), 0700); err != nil {
+        return err
+    }
+
+    tmp, err := ioutil.TempFile(filepath.Dir(p.Path), ".pairings")
+
+    if err != nil {
+        return err
+    }
+
+    defer os.Remove(tmp.Name())
+
+    if _, err = tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+
+    if err = tmp.Close(); err != nil {
+        return err
+    }
+
+    return os.Rename(tmp.Name(), p.Path)
+}
+
+// NewCode creates a pairing code, replacing any earlier one. The code
+// is valid once, for PairingTimeout.
+func (p *Pairings) NewCode() (string, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    f, err := p.load()
+
+    if err != nil {
+        return "", err
+    }
+
+    code := lock.EntropyAlphabet(codeLength)
+
+    f.Pending = &pendingCode{hash(code), p.now().Add(PairingTimeout)}
+
+    return code, p.save(f)
+}
+
+// Pair pairs an extension with the code and returns the client ID and
+// the key with which it identifies itself from then on. The code is
+// used up, also if it is wrong, so that it cannot be guessed.
+func (p *Pairings) Pair(code string, name string) (client string, key string, err error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    f, err := p.load()
+
+    if err != nil {
+        return "", "", err
+    }
+
+    pending := f.Pending
+
+    if pending == nil {
+        return "", "", ErrPairingCode
+    }
+
+    f.Pending = nil
+
+    if p.now().After(pending.Expires) ||
+        subtle.ConstantTimeCompare([]byte(hash(code)), []byte(pending.Code)) != 1 {
+        if err = p.save(f); err != nil {
+            return "", "", err
+        }
+
+        return "", "", ErrPairingCode
+    }
+
+    client = hex.EncodeToString(lock.Entropy(clientLength))
+    key = hex.EncodeToString(lock.Entropy(keyLength))
+
+    f.Clients[client] = Pairing{
+        Name:    name,
+        Key:     hash(key),
+        Created: p.now(),
+    }
+
+    if err = p.save(f); err != nil {
+        return "", "", err
+    }
+
+    return client, key, nil
+}
+
+// Verify reports whether the key is the one of the paired client.
+func (p *Pairings) Verify(client string, key string) bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    f, err := p.load()
+
+    if err != nil {
+        return false
+    }
+
+    pairing, ok := f.Clients[client]
+
+    return ok && subtle.ConstantTimeCompare([]byte(hash(key)), []byte(pairing.Key)) == 1
+}
+
+// List returns the paired extensions, oldest first.
+func (p *Pairings) List() ([]Pairing, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    f, err := p.load()
+
+    if err != nil {
+        return nil, err
+    }
+
+    var pairings []Pairing
+
+    for client, pairing := range f.Clients {
+        pairing.Client = client
+        pairings = append(pairings, pairing)
+    }
+
+    sort.Slice(pairings, func(i, j int) bool {
+        return pairings[i].Created.Before(pairings[j].Created)
+    })
+
+    return pairings, nil
+}
+
+// Remove unpairs an extension.
+func (p *Pairings) Remove(client string) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    f, err := p.load()
+
+    if err != nil {
+        return err
+    }
+
+    delete(f.Clients, client)
+    return p.save(f)
+}
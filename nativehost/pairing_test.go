@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package nativehost
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestPairing(t *testing.T) {
+    p := NewPairings(filepath.Join(t.TempDir(), "browser.json"))
+
+    if _, _, err := p.Pair("", "none"); err != ErrPairingCode {
+        t.Errorf("Paired without a code: %v", err)
+    }
+
+    code, err := p.NewCode()
+
+    if err != nil || len(code) != codeLength {
+        t.Fatalf("Unexpected code %q: %v", code, err)
+    }
+
+    info, err := os.Stat(p.Path)
+
+    if err != nil || info.Mode().Perm() != 0600 {
+        t.Errorf("Pairings readable by others: %v %v", info, err)
+    }
+
+    client, key, err := p.Pair(code, "Chrome")
+
+    if err != nil || !p.Verify(client, key) {
+        t.Fatalf("Could not pair: %v", err)
+    }
+
+    if p.Verify(client, key+"0") || p.Verify("other", key) {
+        t.Errorf("Wrong key or client accepted")
+    }
+
+    if _, _, err = p.Pair(code, "again"); err != ErrPairingCode {
+        t.Errorf("Code was used twice: %v", err)
+    }
+
+    pairings, _ := p.List()
+
+    if len(pairings) != 1 || pairings[0].Client != client || pairings[0].Name != "Chrome" || pairings[0].Key == key {
+        t.Errorf("Unexpected pairings %v", pairings)
+    }
+
+    if err = p.Remove(client); err != nil || p.Verify(client, key) {
+        t.Errorf("Extension still paired: %v", err)
+    }
+}
+
+func TestPairingCodeExpiresAndIsUsedUp(t *testing.T) {
+    p := NewPairings(filepath.Join(t.TempDir(), "browser.json"))
+    now := time.Now()
+    p.now = func() time.Time { return now }
+
+    code, _ := p.NewCode()
+    now = now.Add(PairingTimeout + time.Second)
+
+    if _, _, err := p.Pair(code, "late"); err != ErrPairingCode {
+        t.Errorf("Expired code was accepted: %v", err)
+    }
+
+    // A wrong guess uses the code up.
+    code, _ = p.NewCode()
+    p.Pair("wrong", "guess")
+
+    if _, _, err := p.Pair(code, "right"); err != ErrPairingCode {
+        t.Errorf("Code still valid after a wrong guess: %v", err)
+    }
+}
@@ -0,0 +1,178 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "net/url"
+    "pass/logger"
+    "pass/markdown"
+    "pass/rest"
+    "strings"
+)
+
+type Note struct {
+    Title   string
+    Text    string
+    Editing bool
+    Error   string
+    Data    rest.DecodedEntry
+}
+
+// The rendered note is part of the template, so braces are escaped to
+// keep them from being taken as actions.
+func (h *Note) rendered() string {
+    return strings.NewReplacer("{", "&#123;", "}", "&#125;").Replace(
+        markdown.Render(h.Text))
+}
+
+func (h *Note) Render() string {
+    var body string
+
+    if h.Editing || h.Text == "" {
+        body = `
+                <textarea placeholder="Write your note in Markdown"
+                          onchange="Text"
+                          autofocus="true"
+                          autocomplete="off"
+                          spellcheck="true"
+                          selectable="on"
+                          class="editable note">{{html .Text}}</textarea>`
+    } else {
+        body = `
+                <div class="markdown" selectable="on" onclick="Edit">` +
+            h.rendered() + `</div>`
+    }
+
+    return `
+<div class="WindowLayout">
+    <div class="SearchLayout">
+        <input type="text"
+               value="{{html .Title}}"
+               placeholder="Account"
+               onchange="DoSearchQuery"
+               autocomplete="off"
+               autocorrect="off"
+               autocapitalize="off"
+               spellcheck="false"
+               selectable="on"
+               class="editable searchfield"/>
+        <div class="animated">
+            <p><input type="text"
+                   value="{{.Title}}"
+                   placeholder="Note"
+                   onchange="Title"
+                   autocomplete="off"
+                   spellcheck="false"
+                   selectable="on"
+                   class="editable name"/></p>
+            <div class="details">` + body + `
+                {{if .Error}}<p class="timestamps">{{.Error}}</p>{{end}}
+            </div>
+          </div>
+          <div class="bottom-toolbar">
+              <div>
+                  <button class="button ok" onclick="OK"/>
+                  <button class="button cancel" onclick="Cancel"/>
+                  <button class="button delete" onclick="Delete"/>
+              </div>
+          </div>
+     </div>
+</div>`
+}
+
+func (h *Note) OnHref(URL *url.URL) {
+    u := URL.Query()
+    h.Title = u.Get("Name")
+
+    restResponse, err := restClient.VaultReadSecret(
+        &rest.Name{
+            Text:      h.Title,
+            Encrypted: u.Get("Encrypted"),
+        })
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
+
+    h.Data = *restResponse
+
+    if n, ok := h.Data.Payload.(*rest.Note); ok {
+        h.Text = n.Text
+    }
+
+    app.Render(h)
+}
+
+// Edit switches from the rendered note to its text.
+func (h *Note) Edit() {
+    h.Editing = true
+    app.Render(h)
+}
+
+func (h *Note) OK() {
+    // We do not want empty names.
+    if h.Title == "" {
+        return
+    }
+
+    h.Data.Type = rest.TypeNote
+    h.Data.Payload = &rest.Note{Text: h.Text}
+
+    if err := saveEntry(&h.Data, h.Title); err != nil {
+        logger.Error(err)
+        h.Error = err.Error()
+        app.Render(h)
+        return
+    }
+
+    h.Cancel()
+}
+
+func (h *Note) Cancel() {
+    NavigateBack("")
+}
+
+func (h *Note) Delete() {
+    if h.Data.Name != nil {
+        restClient.VaultDeleteSecret(&h.Data)
+    }
+    h.Cancel()
+}
+
+func (h *Note) DoSearchQuery(arg app.ChangeArg) {
+    NavigateBack(arg.Value)
+}
+
+func init() {
+    app.RegisterComponent(&Note{})
+}
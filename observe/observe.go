@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package observe
+
+import (
+    "time"
+)
+
+const (
+    OutcomeOK    = "ok"
+    OutcomeError = "error"
+
+    // Operation names used by rest and lock.
+    OperationList      = "vault.list"
+    OperationRead      = "vault.read"
+    OperationWrite     = "vault.write"
+    OperationDelete    = "vault.delete"
+    OperationTagRead   = "vault.tag.read"
+    OperationTagWrite  = "vault.tag.write"
+    OperationEncrypt   = "lock.encrypt"
+    OperationDecrypt   = "lock.decrypt"
+    OperationDeriveKey = "lock.derive_key"
+)
+
+// An Event describes a single timed operation, such as a request to
+// Vault, a decryption or a key derivation. Events deliberately carry
+// no free-form data: entry names, paths, tokens and secret values can
+// never end up in them, only the coarse operation name and sizes.
+type Event struct {
+    Operation string
+    Start     time.Time
+    Duration  time.Duration
+    Bytes     int
+    Retries   int
+    Outcome   string
+}
+
+// Observer receives events. Implementations must be safe for
+// concurrent use and should return quickly, since they are called
+// inline on the hot path.
+type Observer interface {
+    Observe(e Event)
+}
+
+// Nop discards all events. It is the default observer.
+type Nop struct{}
+
+func (Nop) Observe(e Event) {}
+
+// Multi fans out every event to a number of observers.
+type Multi []Observer
+
+func (m Multi) Observe(e Event) {
+    for _, o := range m {
+        o.Observe(e)
+    }
+}
+
+// Time starts timing an operation and returns a function which, when
+// called, reports the event to the observer. A nil error yields the
+// ok outcome, anything else is reported as an error.
+func Time(o Observer, operation string) func(bytes int, retries int, err error) {
+    start := time.Now()
+
+    return func(bytes int, retries int, err error) {
+        if o == nil {
+            return
+        }
+
+        outcome := OutcomeOK
+
+        if err != nil {
+            outcome = OutcomeError
+        }
+
+        o.Observe(Event{
+            Operation: operation,
+            Start:     start,
+            Duration:  time.Since(start),
+            Bytes:     bytes,
+            Retries:   retries,
+            Outcome:   outcome,
+        })
+    }
+}
@@ -0,0 +1,86 @@
+package observe
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+    p := NewPrometheus()
+
+    p.Observe(Event{
+        Operation: OperationList,
+        Duration:  3 * time.Millisecond,
+        Bytes:     100,
+        Retries:   1,
+        Outcome:   OutcomeOK,
+    })
+    p.Observe(Event{
+        Operation: OperationList,
+        Duration:  2 * time.Second,
+        Bytes:     20,
+        Outcome:   OutcomeError,
+    })
+
+    var b bytes.Buffer
+    p.WriteTo(&b)
+    out := b.String()
+
+    expected := []string{
+        `pass_operations_total{operation="vault.list",outcome="ok"} 1`,
+        `pass_operations_total{operation="vault.list",outcome="error"} 1`,
+        `pass_operation_bytes_total{operation="vault.list"} 120`,
+        `pass_operation_retries_total{operation="vault.list"} 1`,
+        `pass_operation_duration_seconds_bucket{operation="vault.list",le="0.005"} 1`,
+        `pass_operation_duration_seconds_bucket{operation="vault.list",le="5"} 2`,
+        `pass_operation_duration_seconds_count{operation="vault.list"} 2`,
+    }
+
+    for _, line := range expected {
+        if !strings.Contains(out, line) {
+            t.Errorf("Missing line in exposition: %s", line)
+        }
+    }
+}
+
+func TestListenOnlyLocal(t *testing.T) {
+    if _, err := Listen("0.0.0.0:0"); err == nil {
+        t.Errorf("Exporter must not listen on all interfaces")
+    }
+
+    l, err := Listen("127.0.0.1:0")
+
+    if err != nil {
+        t.Errorf("Could not listen on loopback: %s", err)
+        return
+    }
+
+    l.Close()
+}
+
+func TestTracerSpan(t *testing.T) {
+    var b bytes.Buffer
+    tracer := NewTracer(&b)
+
+    done := Time(tracer, OperationDecrypt)
+    done(64, 0, errors.New("failed"))
+
+    span := Span{}
+
+    if err := json.Unmarshal(b.Bytes(), &span); err != nil {
+        t.Errorf("Span is not valid JSON: %s", err)
+    }
+
+    if span.Name != OperationDecrypt || span.Status.Code != StatusCodeError {
+        t.Errorf("Unexpected span, got: %s %s", span.Name, span.Status.Code)
+    }
+
+    if span.Kind != SpanKindInternal || len(span.TraceID) != 32 {
+        t.Errorf("Unexpected span kind or trace id, got: %s %s",
+            span.Kind, span.TraceID)
+    }
+}
@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package observe
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "pass/socket"
+    "sort"
+    "strings"
+    "sync"
+)
+
+const (
+    MetricsPath = "/metrics"
+    UnixPrefix  = "unix:"
+)
+
+// Latency buckets (in seconds) of the duration histogram. They span
+// from a single decryption up to a slow LIST or an Argon2 derivation.
+var Buckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type series struct {
+    count    uint64
+    errors   uint64
+    bytes    uint64
+    retries  uint64
+    sum      float64
+    buckets  []uint64
+    outcomes map[string]uint64
+}
+
+// Prometheus aggregates events and exposes them in the Prometheus
+// text exposition format.
+type Prometheus struct {
+    mutex  sync.Mutex
+    series map[string]*series
+}
+
+func NewPrometheus() *Prometheus {
+    return &Prometheus{
+        series: make(map[string]*series),
+    }
+}
+
+func (p *Prometheus) Observe(e Event) {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    s, ok := p.series[e.Operation]
+
+    if !ok {
+        s = &series{
+            buckets:  make([]uint64, len(Buckets)),
+            outcomes: make(map[string]uint64),
+        }
+        p.series[e.Operation] = s
+    }
+
+    seconds := e.Duration.Seconds()
+
+    s.count++
+    s.sum += seconds
+    s.bytes += uint64(e.Bytes)
+    s.retries += uint64(e.Retries)
+    s.outcomes[e.Outcome]++
+
+    // Buckets are cumulative, so every bucket with an upper bound
+    // above the observation is incremented.
+    for i, bound := range Buckets {
+        if seconds <= bound {
+            s.buckets[i]++
+        }
+    }
+}
+
+// WriteTo writes all collected metrics to w.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+    var b bytes.Buffer
+
+    p.mutex.Lock()
+
+    // Sort operations to get a stable output.
+    operations := make([]string, 0, len(p.series))
+
+    for operation := range p.series {
+        operations = append(operations, operation)
+    }
+
+    sort.Strings(operations)
+
+    b.WriteString("# HELP pass_operations_total Number of operations by outcome.\n")
+    b.WriteString("# TYPE pass_operations_total counter\n")
+
+    for _, operation := range operations {
+        s := p.series[operation]
+        outcomes := make([]string, 0, len(s.outcomes))
+
+        for outcome := range s.outcomes {
+            outcomes = append(outcomes, outcome)
+        }
+
+        sort.Strings(outcomes)
+
+        for _, outcome := range outcomes {
+            fmt.Fprintf(&b, "pass_operations_total{operation=%q,outcome=%q} %d\n",
+                operation, outcome, s.outcomes[outcome])
+        }
+    }
+
+    b.WriteString("# HELP pass_operation_bytes_total Bytes processed by operation.\n")
+    b.WriteString("# TYPE pass_operation_bytes_total counter\n")
+
+    for _, operation := range operations {
+        fmt.Fprintf(&b, "pass_operation_bytes_total{operation=%q} %d\n",
+            operation, p.series[operation].bytes)
+    }
+
+    b.WriteString("# HELP pass_operation_retries_total Retries by operation.\n")
+    b.WriteString("# TYPE pass_operation_retries_total counter\n")
+
+    for _, operation := range operations {
+        fmt.Fprintf(&b, "pass_operation_retries_total{operation=%q} %d\n",
+            operation, p.series[operation].retries)
+    }
+
+    b.WriteString("# HELP pass_operation_duration_seconds Latency of operations.\n")
+    b.WriteString("# TYPE pass_operation_duration_seconds histogram\n")
+
+    for _, operation := range operations {
+        s := p.series[operation]
+
+        for i, bound := range Buckets {
+            fmt.Fprintf(&b, "pass_operation_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n",
+                operation, bound, s.buckets[i])
+        }
+
+        fmt.Fprintf(&b, "pass_operation_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n",
+            operation, s.count)
+        fmt.Fprintf(&b, "pass_operation_duration_seconds_sum{operation=%q} %g\n",
+            operation, s.sum)
+        fmt.Fprintf(&b, "pass_operation_duration_seconds_count{operation=%q} %d\n",
+            operation, s.count)
+    }
+
+    p.mutex.Unlock()
+
+    return b.WriteTo(w)
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+    p.WriteTo(w)
+}
+
+// Listen opens a local socket for the exporter. The address is either
+// a unix socket, given as "unix:/path/to/socket", or a TCP address
+// which must be bound to the loopback interface. The metrics do not
+// contain any secrets, but there is no reason to expose them either.
+func Listen(address string) (net.Listener, error) {
+    if strings.HasPrefix(address, UnixPrefix) {
+        path := strings.TrimPrefix(address, UnixPrefix)
+
+        // Remove a stale socket from an earlier run. Only the current
+        // user may scrape.
+        if err := socket.RemoveStale(path); err != nil {
+            return nil, err
+        }
+
+        return socket.ListenPrivate(path)
+    }
+
+    host, _, err := net.SplitHostPort(address)
+
+    if err != nil {
+        return nil, err
+    }
+
+    ip := net.ParseIP(host)
+
+    if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+        return nil, errors.New("metrics must be served on a local socket")
+    }
+
+    return net.Listen("tcp", address)
+}
+
+// Serve exposes the metrics on the given local address. It blocks
+// until the listener fails.
+func (p *Prometheus) Serve(address string) error {
+    listener, err := Listen(address)
+
+    if err != nil {
+        return err
+    }
+
+    mux := http.NewServeMux()
+    mux.Handle(MetricsPath, p)
+
+    return http.Serve(listener, mux)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package observe
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "sync"
+)
+
+const (
+    SpanKindClient   = "SPAN_KIND_CLIENT"
+    SpanKindInternal = "SPAN_KIND_INTERNAL"
+
+    StatusCodeOK    = "STATUS_CODE_OK"
+    StatusCodeError = "STATUS_CODE_ERROR"
+
+    ServiceName = "pass"
+)
+
+type (
+    SpanStatus struct {
+        Code string `json:"code"`
+    }
+
+    // Span mirrors the fields of an OpenTelemetry span, so that the
+    // emitted lines can be fed to a collector with little effort.
+    Span struct {
+        TraceID           string                 `json:"traceId"`
+        SpanID            string                 `json:"spanId"`
+        Name              string                 `json:"name"`
+        Kind              string                 `json:"kind"`
+        StartTimeUnixNano int64                  `json:"startTimeUnixNano"`
+        EndTimeUnixNano   int64                  `json:"endTimeUnixNano"`
+        Attributes        map[string]interface{} `json:"attributes"`
+        Status            SpanStatus             `json:"status"`
+    }
+)
+
+// Tracer emits one span per event as a line of JSON. All spans of a
+// Tracer share the same trace id, i.e., one trace per session.
+type Tracer struct {
+    mutex   sync.Mutex
+    traceID string
+    encoder *json.Encoder
+}
+
+func randomID(length int) string {
+    r := make([]byte, length)
+    rand.Read(r)
+
+    return hex.EncodeToString(r)
+}
+
+func NewTracer(w io.Writer) *Tracer {
+    return &Tracer{
+        traceID: randomID(16),
+        encoder: json.NewEncoder(w),
+    }
+}
+
+// Requests to Vault are client spans, everything else happens within
+// the process.
+func spanKind(operation string) string {
+    switch operation {
+    case OperationList, OperationRead, OperationWrite, OperationDelete,
+        OperationTagRead, OperationTagWrite:
+        return SpanKindClient
+    }
+
+    return SpanKindInternal
+}
+
+func (t *Tracer) Observe(e Event) {
+    status := StatusCodeOK
+
+    if e.Outcome != OutcomeOK {
+        status = StatusCodeError
+    }
+
+    span := Span{
+        TraceID:           t.traceID,
+        SpanID:            randomID(8),
+        Name:              e.Operation,
+        Kind:              spanKind(e.Operation),
+        StartTimeUnixNano: e.Start.UnixNano(),
+        EndTimeUnixNano:   e.Start.Add(e.Duration).UnixNano(),
+        Attributes: map[string]interface{}{
+            "service.name": ServiceName,
+            "pass.bytes":   e.Bytes,
+            "pass.retries": e.Retries,
+            "pass.outcome": e.Outcome,
+        },
+        Status: SpanStatus{
+            Code: status,
+        },
+    }
+
+    t.mutex.Lock()
+    t.encoder.Encode(&span)
+    t.mutex.Unlock()
+}
@@ -33,9 +33,9 @@ package main
 import (
     "github.com/murlokswarm/app"
     "net/url"
+    "pass/logger"
     "pass/otp"
     "pass/rest"
-    "log"
 )
 
 type OTP struct {
@@ -94,13 +94,13 @@ func (h *OTP) OnHref(URL *url.URL) {
         })
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
     // Read contents from data segment.
     h.Data = *restResponse
-    h.OTP = otp.ComputeOTPCode(h.Data.Password)
+    h.OTP = otp.ComputeOTPCode(h.Data.Password.Bytes())
 
     app.Render(h)
 
@@ -120,7 +120,7 @@ func (h *OTP) Delete() {
 
 func (h *OTP) RefreshOTP(arg app.ChangeArg) {
     // Compute OTP based on retrieved data segment.
-    h.OTP = otp.ComputeOTPCode(h.Data.Password)
+    h.OTP = otp.ComputeOTPCode(h.Data.Password.Bytes())
     app.Render(h)
 }
 
@@ -39,14 +39,19 @@ import (
     "time"
 )
 
-func ComputeOTPCode(secret string) string {
+// ComputeOTPCode computes the current code for a base32 encoded
+// secret, which is taken as bytes so that it can stay in a locked
+// buffer.
+func ComputeOTPCode(secret []byte) string {
+    key := make([]byte, base32.StdEncoding.DecodedLen(len(secret)))
+    defer zero(key)
 
-    key, err := base32.StdEncoding.DecodeString(secret)
+    n, err := base32.StdEncoding.Decode(key, secret)
     if err != nil {
         return "n/a"
     }
 
-    hash := hmac.New(sha1.New, key)
+    hash := hmac.New(sha1.New, key[:n])
     err = binary.Write(hash, binary.BigEndian,
         int64(time.Now().Unix()/30))
 
@@ -59,3 +64,9 @@ func ComputeOTPCode(secret string) string {
     truncated := binary.BigEndian.Uint32(h[offset:offset+4]) & 0x7fffffff
     return fmt.Sprintf("%06d", int(truncated%1000000))
 }
+
+func zero(data []byte) {
+    for i := range data {
+        data[i] = 0
+    }
+}
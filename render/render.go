@@ -0,0 +1,148 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package render fills in templates, such as .netrc files, database
+// configurations or Kubernetes manifests, with secrets from Pass.
+//
+// Templates use the syntax of text/template with a secret function,
+// which takes the name of an entry and optionally a field, by default
+// the password:
+//
+//     machine github.com login {{ secret "github" "username" }} password {{ secret "github" }}
+package render
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "io/ioutil"
+    "os"
+    "pass/rest"
+    "pass/util"
+    "path/filepath"
+    "text/template"
+)
+
+// Rendered files are only readable by the current user.
+const FileMode = 0600
+
+// A Source finds entries; it is implemented by rest.Client.
+type Source interface {
+    VaultFindSecret(name string, id string) (*rest.DecodedEntry, error)
+}
+
+// A renderer reads every entry at most once per render.
+type renderer struct {
+    source Source
+    cache  map[string]*rest.DecodedEntry
+}
+
+func (r *renderer) secret(name string, field ...string) (string, error) {
+    if len(field) > 1 {
+        return "", errors.New("secret takes an entry and at most one field")
+    }
+
+    entry, ok := r.cache[name]
+
+    if !ok {
+        var err error
+
+        if entry, err = r.source.VaultFindSecret(name, ""); err != nil {
+            return "", errors.New(name + ": " + err.Error())
+        }
+
+        r.cache[name] = entry
+    }
+
+    f := "password"
+
+    if len(field) == 1 {
+        f = field[0]
+    }
+
+    value, err := entry.Value(f)
+
+    if err != nil {
+        return "", errors.New(name + ": " + f + ": " + err.Error())
+    }
+
+    return value, nil
+}
+
+// Render fills in a template and writes the result to w. Nothing is
+// written unless the whole template could be rendered. The name is
+// used in error messages.
+func Render(s Source, name string, text string, w io.Writer) error {
+    r := &renderer{
+        source: s,
+        cache:  map[string]*rest.DecodedEntry{},
+    }
+
+    t, err := template.New(name).
+        Option("missingkey=error").
+        Funcs(template.FuncMap{"secret": r.secret}).
+        Parse(text)
+
+    if err != nil {
+        return err
+    }
+
+    var out bytes.Buffer
+
+    if err = t.Execute(&out, nil); err != nil {
+        return err
+    }
+
+    _, err = out.WriteTo(w)
+    return err
+}
+
+// RenderFile renders the template in the file in to the file out,
+// which is replaced atomically and only readable by the current user.
+// If out is "-", the result is written to standard output.
+func RenderFile(s Source, in string, out string) error {
+    text, err := ioutil.ReadFile(in)
+
+    if err != nil {
+        return err
+    }
+
+    if out == "-" {
+        return Render(s, filepath.Base(in), string(text), os.Stdout)
+    }
+
+    var result bytes.Buffer
+
+    if err = Render(s, filepath.Base(in), string(text), &result); err != nil {
+        return err
+    }
+
+    return util.WriteFileAtomic(out, result.Bytes(), FileMode)
+}
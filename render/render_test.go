@@ -0,0 +1,100 @@
+package render
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "pass/lock"
+    "pass/rest"
+    "path/filepath"
+    "testing"
+)
+
+type fakeSource struct {
+    entries map[string]*rest.DecodedEntry
+    reads   int
+}
+
+func (f *fakeSource) VaultFindSecret(name string, id string) (*rest.DecodedEntry, error) {
+    f.reads++
+
+    if d, ok := f.entries[name]; ok {
+        return d, nil
+    }
+
+    return nil, rest.ErrNotFound
+}
+
+func newSource() *fakeSource {
+    return &fakeSource{entries: map[string]*rest.DecodedEntry{
+        "github": {Name: &rest.Name{Text: "github"}, Username: "grocid", Password: lock.SecureCopy([]byte("banana"))},
+    }}
+}
+
+func TestRender(t *testing.T) {
+    source := newSource()
+
+    var out bytes.Buffer
+    err := Render(source, "netrc",
+        `machine github.com login {{ secret "github" "username" }} password {{ secret "github" }}`,
+        &out)
+
+    if err != nil {
+        t.Fatalf("Render failed: %s", err)
+    }
+
+    if out.String() != "machine github.com login grocid password banana" {
+        t.Errorf("Unexpected output %q", out.String())
+    }
+
+    if source.reads != 1 {
+        t.Errorf("Entry was read %d times", source.reads)
+    }
+}
+
+func TestRenderErrors(t *testing.T) {
+    for _, text := range []string{
+        `{{ secret "gitlab" }}`,
+        `{{ secret "github" "pin" }}`,
+        `{{ secret "github" "username" "password" }}`,
+        `{{ secret`,
+        `{{ .Missing }}`,
+    } {
+        var out bytes.Buffer
+
+        if err := Render(newSource(), "t", "before "+text, &out); err == nil {
+            t.Errorf("%s rendered without error", text)
+        }
+
+        if out.Len() != 0 {
+            t.Errorf("Partial output written for %s", text)
+        }
+    }
+}
+
+func TestRenderFile(t *testing.T) {
+    dir := t.TempDir()
+    in := filepath.Join(dir, "netrc.tmpl")
+    out := filepath.Join(dir, "netrc")
+
+    ioutil.WriteFile(in, []byte(`password {{ secret "github" }}`), 0644)
+    ioutil.WriteFile(out, []byte("old"), 0644)
+
+    if err := RenderFile(newSource(), in, out); err != nil {
+        t.Fatalf("RenderFile failed: %s", err)
+    }
+
+    data, _ := ioutil.ReadFile(out)
+    info, _ := os.Stat(out)
+
+    if string(data) != "password banana" || info.Mode().Perm() != FileMode {
+        t.Errorf("Unexpected file %q with mode %v", data, info.Mode())
+    }
+
+    // Only the output is left behind.
+    files, _ := ioutil.ReadDir(dir)
+
+    if len(files) != 2 {
+        t.Errorf("Temporary files left behind: %d files", len(files))
+    }
+}
@@ -0,0 +1,384 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "pass/lock"
+    "pass/logger"
+)
+
+var (
+    ErrNotFound    = errors.New("no such entry")
+    ErrNoBlindName = errors.New("entry does not contain its name")
+    ErrMoveVerify  = errors.New("moved entry does not match the original")
+)
+
+func isBlindIndex(key string) bool {
+    if len(key) != lock.BlindIndexLength {
+        return false
+    }
+
+    _, err := hex.DecodeString(key)
+    return err == nil
+}
+
+// Fetches and decrypts the payload stored under a key.
+func (r *Client) readUserData(key string) (*UserData, error) {
+    vaultResponse, err := r.Request(http.MethodGet, "/"+key, nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if len(vaultResponse.Data.Encrypted) == 0 {
+        return nil, ErrNotFound
+    }
+
+    decryptedData, err := r.Lock.DecryptBase64(vaultResponse.Data.Encrypted)
+
+    if err != nil {
+        return nil, err
+    }
+
+    userData := UserData{}
+    err = json.Unmarshal(decryptedData.Bytes(), &userData)
+    decryptedData.Wipe()
+
+    if err != nil {
+        return nil, err
+    }
+
+    return &userData, nil
+}
+
+// IndexName is the input to the blind index of an entry. Entries of
+// different types may have the same name, so the type is encoded with
+// its suffix, like names were encoded before types were stored in the
+// payload. Accounts have no suffix.
+func IndexName(name string, id string) string {
+    t := GetType(id)
+
+    if t.ID == TypeAccount {
+        return name
+    }
+
+    return name + t.Suffix
+}
+
+// StorageKey returns the key a new entry with the given name and type
+// is stored under. With blind indexing, the key is stable and can be
+// computed from the name; otherwise, it is a randomized encryption of
+// the name.
+func (r *Client) StorageKey(name string, id string) (string, error) {
+    if r.BlindIndex {
+        return r.Lock.BlindIndex(IndexName(name, id)), nil
+    }
+
+    return r.EncHex(name)
+}
+
+// The name stored in the key of an entry, if it is encrypted, and the
+// given name otherwise.
+func (r *Client) keyName(data *Name) string {
+    if name, err := r.decName((*data).Encrypted); err == nil {
+        return name
+    }
+
+    return (*data).Text
+}
+
+// DecodeStorageKey recovers the name and type of an entry from its key.
+// Names of legacy entries, stored under an encrypted name with a type
+// suffix, are decrypted directly. Otherwise, the type (and, for a blind
+// index, which cannot be inverted, the name) is in the payload, so we
+// need to fetch the entry once. Since keys only change when an entry
+// is renamed, the result is cached for subsequent listings.
+func (r *Client) DecodeStorageKey(key string) (Name, error) {
+    text, err := r.decName(key)
+
+    if err == nil {
+        if title, id := SplitTypeSuffix(text); id != "" {
+            return Name{Text: title, Type: id, Encrypted: key}, nil
+        }
+    } else if !isBlindIndex(key) {
+        return Name{}, err
+    }
+
+    if name, ok := r.names[key]; ok {
+        return name, nil
+    }
+
+    userData, err := r.readUserData(key)
+
+    if err != nil {
+        return Name{}, err
+    }
+
+    // Entries which were not upgraded yet need their name from the key.
+    if err = UpgradeUserData(userData, text); err != nil && err != ErrNewerSchema {
+        return Name{}, err
+    }
+
+    if userData.Name == "" {
+        return Name{}, ErrNoBlindName
+    }
+
+    name := Name{
+        Text:      userData.Name,
+        Type:      GetType(userData.Type).ID,
+        Encrypted: key,
+    }
+
+    if r.names == nil {
+        r.names = make(map[string]Name)
+    }
+
+    r.names[key] = name
+
+    return name, nil
+}
+
+// VaultLookupSecret fetches an entry of the given type directly by its
+// name, without the need of a LIST. It requires the entry to be stored
+// under its blind index.
+func (r *Client) VaultLookupSecret(name string, id string) (*DecodedEntry, error) {
+    return r.VaultReadSecret(&Name{
+        Text:      name,
+        Type:      id,
+        Encrypted: r.Lock.BlindIndex(IndexName(name, id)),
+    })
+}
+
+// VaultFindSecret fetches an entry of the given type by its full name,
+// including folders. Entries under their blind index are fetched
+// directly; others are found by listing all entries.
+func (r *Client) VaultFindSecret(name string, id string) (*DecodedEntry, error) {
+    if r.BlindIndex {
+        entry, err := r.VaultLookupSecret(name, id)
+
+        if err != ErrNotFound {
+            return entry, err
+        }
+    }
+
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        return nil, err
+    }
+
+    for _, n := range *names {
+        if n.Text == name && GetType(n.Type) == GetType(id) {
+            return r.VaultReadSecret(&n)
+        }
+    }
+
+    return nil, ErrNotFound
+}
+
+// MigrateBlindIndex moves all entries stored under an encrypted name
+// to their blind index. Entries are written to the new key and read
+// back before the old key is removed, so an interrupted migration can simply be run
+// again, which removes the originals of entries already written. It
+// returns the number of entries moved.
+func (r *Client) MigrateBlindIndex() (int, error) {
+    r.LocalUpdate = true
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        return 0, err
+    }
+
+    entries := append([]Name{}, (*names)...)
+    existing := make(map[string]bool)
+
+    for _, entry := range entries {
+        if isBlindIndex(entry.Encrypted) {
+            existing[entry.Encrypted] = true
+        }
+    }
+
+    count := 0
+
+    for i := range entries {
+        oldKey := entries[i].Encrypted
+
+        if isBlindIndex(oldKey) {
+            continue
+        }
+
+        newKey := r.Lock.BlindIndex(IndexName(entries[i].Text, entries[i].Type))
+        entry, err := r.VaultReadSecret(&entries[i])
+
+        if err != nil {
+            return count, err
+        }
+
+        if existing[newKey] {
+            moved, err := r.isMoved(entry, newKey)
+
+            if err != nil {
+                return count, err
+            }
+
+            // Two entries with the same name cannot both have the
+            // same index. Leave the second one where it is.
+            if !moved {
+                logger.Warn("Not migrating duplicate entry", &entries[i])
+                continue
+            }
+
+            // A previous migration was interrupted after writing the
+            // entry to its index, but before removing the original.
+            if _, err = r.Request(http.MethodDelete, "/"+oldKey, nil); err != nil {
+                return count, err
+            }
+
+            count++
+            continue
+        }
+
+        entry.Name = &Name{
+            Text:      entry.Name.Text,
+            Type:      entry.Type,
+            Encrypted: newKey,
+        }
+
+        if err = r.moveSecret(entry, oldKey); err != nil {
+            return count, err
+        }
+
+        existing[newKey] = true
+        count++
+    }
+
+    return count, r.UpdateTag()
+}
+
+// Writes the entry, as it is, under the key in its name, or its storage
+// key if there is none, and reads it back before the original under
+// oldKey is removed. Vault may refuse
+// a write, e.g., due to a policy or a quota, without the request
+// failing, so only what is read back is trusted.
+func (r *Client) moveSecret(entry *DecodedEntry, oldKey string) error {
+    if err := r.writeSecret(entry); err != nil {
+        return err
+    }
+
+    moved, err := r.isMoved(entry, entry.Name.Encrypted)
+
+    if err == ErrNotFound || err == nil && !moved {
+        return ErrMoveVerify
+    }
+
+    if err != nil {
+        return err
+    }
+
+    return r.VaultDeleteSecret(&DecodedEntry{Name: &Name{Encrypted: oldKey}})
+}
+
+// Tells whether the entry stored under key is a copy of the entry,
+// e.g., written by MigrateBlindIndex. Only the name, the schema version
+// and the time of the last change may differ.
+func (r *Client) isMoved(entry *DecodedEntry, key string) (bool, error) {
+    stored, err := r.VaultReadSecret(&Name{Text: entry.Name.Text, Type: entry.Type, Encrypted: key})
+
+    if err != nil {
+        return false, err
+    }
+
+    return sameEntry(entry, stored), nil
+}
+
+// Tells whether two entries hold the same data, ignoring their names,
+// schema versions and times of the last change. Passwords are compared
+// by their contents, as the buffers holding them may differ otherwise.
+func sameEntry(a, b *DecodedEntry) bool {
+    if a.Type != b.Type || a.Username != b.Username || !bytes.Equal(a.File, b.File) ||
+        a.Match != b.Match || a.Notes != b.Notes ||
+        !a.Created.Equal(b.Created) || !a.LastUsed.Equal(b.LastUsed) ||
+        !sameStrings(a.URLs, b.URLs) || !sameStrings(a.Tags, b.Tags) ||
+        len(a.Fields) != len(b.Fields) {
+        return false
+    }
+
+    for i := range a.Fields {
+        if a.Fields[i] != b.Fields[i] {
+            return false
+        }
+    }
+
+    if a.Password.Len() != b.Password.Len() ||
+        a.Password.Len() > 0 && !a.Password.Equal(b.Password.Bytes()) {
+        return false
+    }
+
+    return samePayload(a, b)
+}
+
+// Compares the type specific payloads of two entries of the same type
+// in their stored form.
+func samePayload(a, b *DecodedEntry) bool {
+    t, ok := LookupType(a.Type)
+
+    if !ok || t.Codec == nil {
+        return bytes.Equal(a.data, b.data)
+    }
+
+    payloadA, errA := t.Codec.Encode(a.Payload)
+    payloadB, errB := t.Codec.Encode(b.Payload)
+
+    // The payloads may hold a private key.
+    defer lock.Zero(payloadA)
+    defer lock.Zero(payloadB)
+
+    return errA == nil && errB == nil && bytes.Equal(payloadA, payloadB)
+}
+
+func sameStrings(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+
+    return true
+}
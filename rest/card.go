@@ -0,0 +1,251 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    TypeCard = "card"
+
+    // Brands detected from the card number.
+    BrandVisa       = "Visa"
+    BrandMastercard = "Mastercard"
+    BrandAmex       = "American Express"
+    BrandDiscover   = "Discover"
+    BrandDiners     = "Diners Club"
+    BrandJCB        = "JCB"
+    BrandUnionPay   = "UnionPay"
+    BrandMaestro    = "Maestro"
+)
+
+var (
+    ErrCardNumber = errors.New("card number is not valid")
+    ErrCardExpiry = errors.New("card expiry is not a date (MM/YY)")
+    ErrCardCVV    = errors.New("card security code is not valid")
+)
+
+// Card is the payload of payment card entries. The number is stored
+// as entered; spaces and dashes are ignored.
+type Card struct {
+    Holder string `json:"holder"`
+    Number string `json:"number"`
+    Expiry string `json:"expiry"`
+    CVV    string `json:"cvv"`
+}
+
+// Digits returns the card number without spaces and dashes.
+func (c *Card) Digits() string {
+    return strings.Map(func(r rune) rune {
+        if r == ' ' || r == '-' {
+            return -1
+        }
+        return r
+    }, c.Number)
+}
+
+// Luhn reports whether a number passes the Luhn check, which catches
+// all single-digit errors and most transpositions.
+func Luhn(number string) bool {
+    if len(number) < 2 {
+        return false
+    }
+
+    sum := 0
+    double := false
+
+    for i := len(number) - 1; i >= 0; i-- {
+        d := int(number[i] - '0')
+
+        if d < 0 || d > 9 {
+            return false
+        }
+
+        if double {
+            if d *= 2; d > 9 {
+                d -= 9
+            }
+        }
+
+        sum += d
+        double = !double
+    }
+
+    return sum%10 == 0
+}
+
+// Whether the first digits of a number are within [low, high].
+func hasPrefixInRange(number string, low, high int) bool {
+    n := len(strconv.Itoa(low))
+
+    if len(number) < n {
+        return false
+    }
+
+    prefix, err := strconv.Atoi(number[:n])
+    return err == nil && prefix >= low && prefix <= high
+}
+
+// Brand returns the brand of the card, detected from its number, or
+// the empty string if it is not known.
+func (c *Card) Brand() string {
+    number := c.Digits()
+
+    switch {
+    case hasPrefixInRange(number, 4, 4):
+        return BrandVisa
+    case hasPrefixInRange(number, 51, 55), hasPrefixInRange(number, 2221, 2720):
+        return BrandMastercard
+    case hasPrefixInRange(number, 34, 34), hasPrefixInRange(number, 37, 37):
+        return BrandAmex
+    case hasPrefixInRange(number, 6011, 6011), hasPrefixInRange(number, 644, 649),
+        hasPrefixInRange(number, 65, 65):
+        return BrandDiscover
+    case hasPrefixInRange(number, 300, 305), hasPrefixInRange(number, 36, 36),
+        hasPrefixInRange(number, 38, 39):
+        return BrandDiners
+    case hasPrefixInRange(number, 3528, 3589):
+        return BrandJCB
+    case hasPrefixInRange(number, 62, 62):
+        return BrandUnionPay
+    case hasPrefixInRange(number, 50, 50), hasPrefixInRange(number, 56, 69):
+        return BrandMaestro
+    }
+
+    return ""
+}
+
+// Masked returns the number with all but the last four digits hidden.
+func (c *Card) Masked() string {
+    number := c.Digits()
+
+    if len(number) <= 4 {
+        return number
+    }
+
+    return "•••• " + number[len(number)-4:]
+}
+
+// ExpiryTime returns the end of the month the card expires in. The
+// expiry is given as MM/YY or MM/YYYY.
+func (c *Card) ExpiryTime() (time.Time, error) {
+    parts := strings.Split(strings.TrimSpace(c.Expiry), "/")
+
+    if len(parts) != 2 {
+        return time.Time{}, ErrCardExpiry
+    }
+
+    month, err := strconv.Atoi(parts[0])
+
+    if err != nil || month < 1 || month > 12 {
+        return time.Time{}, ErrCardExpiry
+    }
+
+    year, err := strconv.Atoi(parts[1])
+
+    switch {
+    case err != nil:
+        return time.Time{}, ErrCardExpiry
+    case len(parts[1]) == 2:
+        year += 2000
+    case len(parts[1]) != 4:
+        return time.Time{}, ErrCardExpiry
+    }
+
+    // The first day of the following month.
+    return time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// Expired reports whether the card has expired at the given time.
+func (c *Card) Expired(now time.Time) bool {
+    expiry, err := c.ExpiryTime()
+    return err == nil && !now.Before(expiry)
+}
+
+func (c *Card) Validate() error {
+    number := c.Digits()
+
+    if number != "" && (len(number) < 12 || len(number) > 19 || !Luhn(number)) {
+        return ErrCardNumber
+    }
+
+    if c.Expiry != "" {
+        if _, err := c.ExpiryTime(); err != nil {
+            return err
+        }
+    }
+
+    if c.CVV != "" {
+        length := 3
+
+        if c.Brand() == BrandAmex {
+            length = 4
+        }
+
+        if _, err := strconv.Atoi(c.CVV); err != nil || len(c.CVV) != length {
+            return ErrCardCVV
+        }
+    }
+
+    return nil
+}
+
+func validateCard(d *DecodedEntry) error {
+    if d.Payload == nil {
+        return nil
+    }
+
+    card, ok := d.Payload.(*Card)
+
+    if !ok {
+        return ErrUnknownType
+    }
+
+    return card.Validate()
+}
+
+func init() {
+    RegisterType(&EntryType{
+        ID:     TypeCard,
+        Label:  CardLabel,
+        Icon:   "card",
+        View:   "Card",
+        Suffix: CardSuffix,
+        Codec: JSONCodec{
+            New: func() interface{} { return &Card{} },
+        },
+        Validate: validateCard,
+    })
+}
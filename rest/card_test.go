@@ -0,0 +1,118 @@
+package rest
+
+import (
+    "testing"
+    "time"
+)
+
+func TestLuhn(t *testing.T) {
+    for _, number := range []string{"4111111111111111", "79927398713", "378282246310005"} {
+        if !Luhn(number) {
+            t.Errorf("Valid number %s rejected", number)
+        }
+    }
+
+    for _, number := range []string{"4111111111111112", "79927398710", "1", "4111x11111111111"} {
+        if Luhn(number) {
+            t.Errorf("Invalid number %s accepted", number)
+        }
+    }
+}
+
+func TestCardBrand(t *testing.T) {
+    brands := map[string]string{
+        "4111 1111 1111 1111": BrandVisa,
+        "5555-5555-5555-4444": BrandMastercard,
+        "2223003122003222":    BrandMastercard,
+        "378282246310005":     BrandAmex,
+        "6011111111111117":    BrandDiscover,
+        "30569309025904":      BrandDiners,
+        "3530111333300000":    BrandJCB,
+        "6200000000000005":    BrandUnionPay,
+        "6759649826438453":    BrandMaestro,
+        "9999999999999995":    "",
+    }
+
+    for number, brand := range brands {
+        c := Card{Number: number}
+
+        if c.Brand() != brand {
+            t.Errorf("%s detected as %q, not %q", number, c.Brand(), brand)
+        }
+    }
+}
+
+func TestCardExpiry(t *testing.T) {
+    c := Card{Expiry: "02/24"}
+    expiry, err := c.ExpiryTime()
+
+    if err != nil || !expiry.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+        t.Errorf("Unexpected expiry %v: %v", expiry, err)
+    }
+
+    if c.Expired(time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)) {
+        t.Errorf("Card expired before the end of the month")
+    }
+
+    if !c.Expired(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+        t.Errorf("Card did not expire")
+    }
+
+    for _, expiry := range []string{"13/24", "2/2024x", "0224", "02/124"} {
+        c.Expiry = expiry
+
+        if _, err := c.ExpiryTime(); err != ErrCardExpiry {
+            t.Errorf("Invalid expiry %s accepted", expiry)
+        }
+    }
+}
+
+func TestCardValidate(t *testing.T) {
+    valid := []Card{
+        {},
+        {Number: "4111 1111 1111 1111", Expiry: "12/2030", CVV: "123"},
+        {Number: "378282246310005", CVV: "1234"},
+    }
+
+    for _, c := range valid {
+        if err := c.Validate(); err != nil {
+            t.Errorf("Valid card rejected: %s", err)
+        }
+    }
+
+    invalid := map[error]Card{
+        ErrCardNumber: {Number: "4111 1111 1111 1112"},
+        ErrCardExpiry: {Expiry: "next year"},
+        ErrCardCVV:    {Number: "378282246310005", CVV: "123"},
+    }
+
+    for expected, c := range invalid {
+        if err := c.Validate(); err != expected {
+            t.Errorf("Expected %s, got %v", expected, err)
+        }
+    }
+}
+
+func TestCardRoundTrip(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    card := &Card{Holder: "Carl", Number: "4111111111111111", Expiry: "01/30", CVV: "321"}
+    entry := DecodedEntry{Name: &Name{Text: "visa,card"}, Payload: card}
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    read, err := r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if err != nil || read.Type != TypeCard || *read.Payload.(*Card) != *card {
+        t.Errorf("Card did not survive a round trip: %v", err)
+    }
+
+    entry.Payload = &Card{Number: "4111111111111112"}
+
+    if err := r.VaultWriteSecret(&entry); err != ErrCardNumber {
+        t.Errorf("Invalid card was written")
+    }
+}
@@ -1,31 +1,79 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
 package rest
 
 import "strings"
 
+// Before types were stored in the payload, the type of an entry was
+// encoded as a suffix of its name. These are still understood, both
+// when reading old entries and when creating entries by name. Newer
+// types have a suffix too, so that they can be created by name and
+// have their own blind index.
 const (
     UserCredentialsSuffix = ",0"
     OTPSuffix             = ",1"
     FileSuffix            = ",2"
     SignSuffix            = ",3"
+    SSHSuffix             = ",ssh"
+    CardSuffix            = ",card"
+    IdentitySuffix        = ",identity"
+    NoteSuffix            = ",note"
     AccountLabel          = "Account"
     OTPLabel              = "OTP"
     FileLabel             = "File"
     SignLabel             = "Sign"
+    SSHLabel              = "SSH key"
+    CardLabel             = "Card"
+    IdentityLabel         = "Identity"
+    NoteLabel             = "Note"
 )
 
-func DecodeName(name string) (string, string, string) {
-
-    if strings.HasSuffix(name, FileSuffix) {
-        return name[:len(name)-2], FileLabel, "file"
+// SplitTypeSuffix strips a legacy type suffix from a name and returns
+// the title and the type it encodes. Names without a suffix have no
+// type, i.e., the empty string.
+func SplitTypeSuffix(name string) (string, string) {
+    for _, t := range types {
+        if t.Suffix != "" && strings.HasSuffix(name, t.Suffix) {
+            return name[:len(name)-len(t.Suffix)], t.ID
+        }
     }
 
-    if strings.HasSuffix(name, OTPSuffix) {
-        return name[:len(name)-2], OTPLabel, "otp"
-    }
+    return name, ""
+}
 
-    if strings.HasSuffix(name, SignSuffix) {
-        return name[:len(name)-2], SignLabel, "sign"
-    }
+// DecodeName returns the title, label and icon of a name with a legacy
+// type suffix.
+func DecodeName(name string) (string, string, string) {
+    title, id := SplitTypeSuffix(name)
+    t := GetType(id)
 
-    return name, AccountLabel, name
+    return title, t.Label, t.IconName(title)
 }
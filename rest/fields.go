@@ -0,0 +1,294 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "net/url"
+    "pass/lock"
+    "pass/otp"
+    "pass/urlmatch"
+    "strings"
+    "time"
+)
+
+// Types of custom fields. Hidden fields are treated like passwords by
+// the user interface.
+const (
+    FieldText   = "text"
+    FieldHidden = "hidden"
+    FieldURL    = "url"
+    FieldDate   = "date"
+
+    DateLayout = "2006-01-02"
+)
+
+var FieldTypes = []string{FieldText, FieldHidden, FieldURL, FieldDate}
+
+var (
+    ErrFieldName = errors.New("custom field without a name")
+    ErrFieldType = errors.New("unknown custom field type")
+    ErrFieldURL  = errors.New("custom field is not a valid URL")
+    ErrFieldDate = errors.New("custom field is not a date (YYYY-MM-DD)")
+    ErrURL       = errors.New("not a valid URL")
+    ErrNoField   = errors.New("entry has no such field")
+)
+
+// A Field is a custom, typed field of an entry, e.g., a security
+// question or a recovery email.
+type Field struct {
+    Name  string `json:"name"`
+    Type  string `json:"type"`
+    Value string `json:"value"`
+}
+
+func isURL(s string) bool {
+    u, err := url.Parse(s)
+    return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func (f *Field) Validate() error {
+    if f.Name == "" {
+        return ErrFieldName
+    }
+
+    switch f.Type {
+    case FieldText, FieldHidden:
+        return nil
+    case FieldURL:
+        if f.Value != "" && !isURL(f.Value) {
+            return ErrFieldURL
+        }
+        return nil
+    case FieldDate:
+        if _, err := time.Parse(DateLayout, f.Value); f.Value != "" && err != nil {
+            return ErrFieldDate
+        }
+        return nil
+    }
+
+    return ErrFieldType
+}
+
+// Validate checks the URLs and custom fields of an entry, as well as
+// whatever its type requires, before it is written.
+func (d *DecodedEntry) Validate() error {
+    if err := urlmatch.ValidateMode(d.Match, nil); err != nil {
+        return err
+    }
+
+    // With the regex match mode, the URLs are patterns.
+    if d.Match == urlmatch.ModeRegex {
+        if err := urlmatch.ValidateMode(d.Match, d.URLs); err != nil {
+            return err
+        }
+    } else {
+        for _, u := range d.URLs {
+            if !isURL(u) {
+                return ErrURL
+            }
+        }
+    }
+
+    for i := range d.Fields {
+        if err := d.Fields[i].Validate(); err != nil {
+            return err
+        }
+    }
+
+    if t, ok := LookupType(d.Type); ok && t.Validate != nil {
+        return t.Validate(d)
+    }
+
+    return nil
+}
+
+// SplitList splits a comma or newline separated list, as entered in
+// the user interface, and drops empty items.
+func SplitList(s string) []string {
+    items := []string{}
+
+    for _, item := range strings.FieldsFunc(s, func(c rune) bool {
+        return c == ',' || c == '\n'
+    }) {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+
+    return items
+}
+
+// HasTag reports whether the entry is tagged with tag, ignoring case.
+func (d *DecodedEntry) HasTag(tag string) bool {
+    for _, t := range d.Tags {
+        if strings.EqualFold(t, tag) {
+            return true
+        }
+    }
+    return false
+}
+
+// VaultTouchSecret records that an entry was used, e.g., that its
+// password was handed to another program.
+// A use does not count as a modification.
+func (r *Client) VaultTouchSecret(data *DecodedEntry) error {
+    data.LastUsed = time.Now().UTC()
+    return r.writeSecret(data)
+}
+
+// PasswordText returns the password, or the secret of an OTP entry, as
+// a string, which cannot be wiped. Only front ends should call it, to
+// show or hand on the password.
+func (d *DecodedEntry) PasswordText() string {
+    return string(d.Password.Bytes())
+}
+
+// SetPassword replaces the password, e.g., with one entered by the
+// user, wiping the old one.
+func (d *DecodedEntry) SetPassword(password string) {
+    d.Password.Wipe()
+    d.Password = lock.SecureCopy([]byte(password))
+}
+
+// HasPassword reports whether the password is the given one, comparing
+// in constant time. An entry without a password has the empty one.
+func (d *DecodedEntry) HasPassword(password string) bool {
+    if d.Password.Wiped() {
+        return password == ""
+    }
+
+    return d.Password.Equal([]byte(password))
+}
+
+// Value returns a field of an entry by name, for handing secrets to
+// other programs. Besides custom fields, which are matched ignoring
+// case, the names are username, password, url, notes and, depending
+// on the type of the entry, code (the current OTP code), text (of a
+// note), holder, number, expiry and cvv (of a card) and public (the
+// SSH public key).
+func (d *DecodedEntry) Value(field string) (string, error) {
+    value := ""
+
+    switch payload := d.Payload.(type) {
+    case *Note:
+        if field == "text" {
+            return payload.Text, nil
+        }
+    case *Card:
+        switch field {
+        case "holder":
+            value = payload.Holder
+        case "number":
+            value = payload.Digits()
+        case "expiry":
+            value = payload.Expiry
+        case "cvv":
+            value = payload.CVV
+        }
+    case *SSHKey:
+        if field == "public" {
+            return payload.PublicKey()
+        }
+    }
+
+    switch field {
+    case "username":
+        value = d.Username
+    case "password":
+        value = d.PasswordText()
+    case "notes":
+        value = d.Notes
+    case "url":
+        if len(d.URLs) > 0 {
+            value = d.URLs[0]
+        }
+    case "code":
+        if d.Type == TypeOTP {
+            value = otp.ComputeOTPCode(d.Password.Bytes())
+        }
+    }
+
+    if value != "" {
+        return value, nil
+    }
+
+    for _, f := range d.Fields {
+        if strings.EqualFold(f.Name, field) {
+            return f.Value, nil
+        }
+    }
+
+    return "", ErrNoField
+}
+
+// VaultReadAll reads and decrypts all entries, e.g., to search their
+// tags or URLs, which are only stored encrypted.
+func (r *Client) VaultReadAll() ([]*DecodedEntry, error) {
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        return nil, err
+    }
+
+    entries := []*DecodedEntry{}
+
+    for _, name := range append([]Name{}, (*names)...) {
+        entry, err := r.VaultReadSecret(&name)
+
+        if err != nil {
+            return nil, err
+        }
+
+        entries = append(entries, entry)
+    }
+
+    return entries, nil
+}
+
+// VaultFindTagged returns all entries tagged with tag.
+func (r *Client) VaultFindTagged(tag string) ([]*DecodedEntry, error) {
+    all, err := r.VaultReadAll()
+
+    if err != nil {
+        return nil, err
+    }
+
+    entries := []*DecodedEntry{}
+
+    for _, entry := range all {
+        if entry.HasTag(tag) {
+            entries = append(entries, entry)
+        }
+    }
+
+    return entries, nil
+}
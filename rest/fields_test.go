@@ -0,0 +1,122 @@
+package rest
+
+import (
+    "pass/lock"
+    "testing"
+)
+
+func TestFieldValidation(t *testing.T) {
+    valid := []Field{
+        {Name: "Security question", Type: FieldText, Value: "Blue"},
+        {Name: "PIN", Type: FieldHidden, Value: "1234"},
+        {Name: "Recovery", Type: FieldURL, Value: "https://example.com/recover"},
+        {Name: "Expires", Type: FieldDate, Value: "2020-01-31"},
+    }
+
+    for _, f := range valid {
+        if err := f.Validate(); err != nil {
+            t.Errorf("Valid field %s rejected: %s", f.Name, err)
+        }
+    }
+
+    invalid := []Field{
+        {Name: "", Type: FieldText},
+        {Name: "Kind", Type: "colour"},
+        {Name: "Recovery", Type: FieldURL, Value: "not a url"},
+        {Name: "Expires", Type: FieldDate, Value: "31/01/2020"},
+    }
+
+    for _, f := range invalid {
+        if err := f.Validate(); err == nil {
+            t.Errorf("Invalid field %s accepted", f.Name)
+        }
+    }
+}
+
+func TestSplitList(t *testing.T) {
+    items := SplitList(" work, private\n\nhttps://a.com ,")
+
+    if len(items) != 3 || items[0] != "work" || items[2] != "https://a.com" {
+        t.Errorf("Unexpected items: %v", items)
+    }
+}
+
+func TestRichEntryRoundTrip(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    entry := DecodedEntry{
+        Name:     &Name{Text: "github"},
+        Password: lock.SecureCopy([]byte("banana")),
+        URLs:     []string{"https://github.com/login"},
+        Notes:    "Recovery codes are in the safe.",
+        Fields:   []Field{{Name: "PIN", Type: FieldHidden, Value: "1234"}},
+        Tags:     []string{"work"},
+    }
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    if entry.Created.IsZero() || entry.Modified.IsZero() {
+        t.Errorf("Timestamps were not set")
+    }
+
+    read, err := r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if err != nil {
+        t.Fatalf("Read error: %s", err)
+    }
+
+    if read.Notes != entry.Notes || len(read.Fields) != 1 ||
+        read.Fields[0].Value != "1234" || !read.HasTag("Work") ||
+        !read.Created.Equal(entry.Created) {
+        t.Errorf("Entry did not survive a round trip")
+    }
+
+    if err := r.VaultTouchSecret(read); err != nil || read.LastUsed.IsZero() {
+        t.Errorf("Touch failed: %v", err)
+    }
+
+    if !read.Modified.Equal(entry.Modified) {
+        t.Errorf("Touch modified the entry")
+    }
+
+    entry.URLs = []string{"github.com"}
+
+    if err := r.VaultWriteSecret(&entry); err != ErrURL {
+        t.Errorf("Invalid URL was written")
+    }
+}
+
+func TestValue(t *testing.T) {
+    d := DecodedEntry{
+        Username: "grocid",
+        Password: lock.SecureCopy([]byte("banana")),
+        URLs:     []string{"https://github.com/login"},
+        Fields:   []Field{{Name: "PIN", Value: "1234"}},
+    }
+
+    values := map[string]string{
+        "username": "grocid",
+        "password": "banana",
+        "url":      "https://github.com/login",
+        "pin":      "1234",
+    }
+
+    for field, expected := range values {
+        if v, err := d.Value(field); err != nil || v != expected {
+            t.Errorf("%s is %q: %v", field, v, err)
+        }
+    }
+
+    if _, err := d.Value("notes"); err != ErrNoField {
+        t.Errorf("Empty field was found")
+    }
+
+    d.Payload = &Card{Number: "4111 1111 1111 1111"}
+
+    if v, _ := d.Value("number"); v != "4111111111111111" {
+        t.Errorf("Unexpected card number %s", v)
+    }
+}
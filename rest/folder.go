@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// Folders are part of the entry name, e.g., "Work/Servers/github". As
+// names are only ever stored encrypted (or as a blind index, with the
+// name encrypted in the payload), the server learns nothing about the
+// folder structure.
+const FolderSeparator = "/"
+
+var ErrExists = errors.New("an entry with that name already exists")
+
+// SplitPath splits a name into its folder and its base name.
+func SplitPath(name string) (string, string) {
+    i := strings.LastIndex(name, FolderSeparator)
+
+    if i < 0 {
+        return "", name
+    }
+
+    return name[:i], name[i+1:]
+}
+
+// JoinPath is the inverse of SplitPath. Empty path elements are
+// dropped, so that the result never starts or ends with a separator.
+func JoinPath(folder string, base string) string {
+    folder = strings.Trim(folder, FolderSeparator)
+
+    if folder == "" {
+        return base
+    }
+
+    return folder + FolderSeparator + base
+}
+
+// ParentFolder returns the folder containing folder.
+func ParentFolder(folder string) string {
+    parent, _ := SplitPath(folder)
+    return parent
+}
+
+// InFolder reports whether the name is directly inside folder.
+func InFolder(name string, folder string) bool {
+    f, _ := SplitPath(name)
+    return f == folder
+}
+
+// Folders returns the sorted names of all folders directly below
+// parent, as full paths. Folders exist implicitly, i.e., as long as
+// they contain at least one entry.
+func Folders(names []Name, parent string) []string {
+    seen := make(map[string]bool)
+    prefix := ""
+
+    if parent != "" {
+        prefix = parent + FolderSeparator
+    }
+
+    for _, name := range names {
+        folder, _ := SplitPath(name.Text)
+
+        if !strings.HasPrefix(folder, prefix) || folder == parent {
+            continue
+        }
+
+        // Only keep the first path element below the parent.
+        child := strings.SplitN(folder[len(prefix):], FolderSeparator, 2)[0]
+        seen[prefix+child] = true
+    }
+
+    folders := make([]string, 0, len(seen))
+
+    for folder := range seen {
+        folders = append(folders, folder)
+    }
+
+    sort.Strings(folders)
+
+    return folders
+}
+
+// VaultRenameSecret stores an entry under a new name, which may be in
+// another folder, and removes it from its old key. The new entry is
+// written before the old one is removed, so an entry is never lost.
+func (r *Client) VaultRenameSecret(data *DecodedEntry, name string) error {
+    old := *(*data).Name
+
+    if name == old.Text {
+        return r.VaultWriteSecret(data)
+    }
+
+    newKey, err := r.StorageKey(name, (*data).Type)
+
+    if err != nil {
+        return err
+    }
+
+    // A blind index is determined by the name, so we would overwrite
+    // an existing entry with the same name.
+    if r.BlindIndex {
+        if _, err = r.readUserData(newKey); err == nil {
+            return ErrExists
+        } else if err != ErrNotFound {
+            return err
+        }
+    }
+
+    (*data).Name = &Name{
+        Text:      name,
+        Type:      (*data).Type,
+        Encrypted: newKey,
+    }
+
+    if err = r.VaultWriteSecret(data); err != nil {
+        (*data).Name = &old
+        return err
+    }
+
+    if old.Encrypted == "" || old.Encrypted == newKey {
+        return nil
+    }
+
+    delete(r.names, old.Encrypted)
+    delete(r.notes, old.Encrypted)
+    delete(r.targets, old.Encrypted)
+
+    _, err = r.Request(http.MethodDelete, "/"+old.Encrypted, nil)
+
+    if err != nil {
+        return err
+    }
+
+    return r.UpdateTag()
+}
+
+// VaultMoveSecret moves an entry to another folder, keeping its base
+// name.
+func (r *Client) VaultMoveSecret(data *DecodedEntry, folder string) error {
+    _, base := SplitPath((*data).Name.Text)
+    return r.VaultRenameSecret(data, JoinPath(folder, base))
+}
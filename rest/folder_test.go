@@ -0,0 +1,66 @@
+package rest
+
+import (
+    "pass/lock"
+    "testing"
+)
+
+func TestFolders(t *testing.T) {
+    names := []Name{
+        {Text: "github"},
+        {Text: "Work/jira"},
+        {Text: "Work/Servers/db,1"},
+        {Text: "Work/Servers/web"},
+        {Text: "Private/bank"},
+    }
+
+    root := Folders(names, "")
+
+    if len(root) != 2 || root[0] != "Private" || root[1] != "Work" {
+        t.Errorf("Unexpected root folders: %v", root)
+    }
+
+    work := Folders(names, "Work")
+
+    if len(work) != 1 || work[0] != "Work/Servers" {
+        t.Errorf("Unexpected subfolders: %v", work)
+    }
+
+    if !InFolder("Work/Servers/db,1", "Work/Servers") || InFolder("Work/jira", "") {
+        t.Errorf("Folder membership is wrong")
+    }
+
+    if JoinPath("/Work/", "jira") != "Work/jira" || JoinPath("", "jira") != "jira" {
+        t.Errorf("Paths are not joined properly")
+    }
+}
+
+func TestMoveSecret(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    r.BlindIndex = true
+    entry := DecodedEntry{Name: &Name{Text: "github"}, Password: lock.SecureCopy([]byte("banana"))}
+    r.VaultWriteSecret(&entry)
+
+    other := DecodedEntry{Name: &Name{Text: "Work/github"}, Password: lock.SecureCopy([]byte("apple"))}
+    r.VaultWriteSecret(&other)
+
+    if err := r.VaultMoveSecret(&entry, "Work"); err != ErrExists {
+        t.Errorf("Moving over an existing entry was not refused")
+    }
+
+    if err := r.VaultMoveSecret(&entry, "Private/Old"); err != nil {
+        t.Fatalf("Move failed: %s", err)
+    }
+
+    if len(vault.data) != 3 {
+        t.Errorf("Old entry was not removed, %d keys left", len(vault.data))
+    }
+
+    moved, err := r.VaultLookupSecret("Private/Old/github", TypeAccount)
+
+    if err != nil || !moved.HasPassword("banana") {
+        t.Errorf("Moved entry not found: %v", err)
+    }
+}
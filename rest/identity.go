@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "strings"
+    "time"
+)
+
+const (
+    TypeIdentity = "identity"
+
+    // Kinds of identity documents.
+    DocumentPassport = "passport"
+    DocumentID       = "id"
+    DocumentLicense  = "license"
+)
+
+var DocumentTypes = []string{DocumentPassport, DocumentID, DocumentLicense}
+
+var (
+    ErrIdentityDate  = errors.New("identity date is not a date (YYYY-MM-DD)")
+    ErrIdentityEmail = errors.New("not a valid email address")
+    ErrDocumentType  = errors.New("unknown identity document type")
+    ErrDocument      = errors.New("identity document without a number")
+)
+
+// Identity is the payload of identity entries. Dates are given as
+// YYYY-MM-DD, like date fields.
+type Identity struct {
+    FirstName  string     `json:"firstname"`
+    MiddleName string     `json:"middlename,omitempty"`
+    LastName   string     `json:"lastname"`
+    Birthday   string     `json:"birthday,omitempty"`
+    Email      string     `json:"email,omitempty"`
+    Phone      string     `json:"phone,omitempty"`
+    Addresses  []Address  `json:"addresses,omitempty"`
+    Documents  []Document `json:"documents,omitempty"`
+}
+
+type Address struct {
+    Label      string `json:"label,omitempty"`
+    Street     string `json:"street"`
+    PostalCode string `json:"postalcode"`
+    City       string `json:"city"`
+    Region     string `json:"region,omitempty"`
+    Country    string `json:"country"`
+}
+
+// A Document is a passport, an ID card or a driving license.
+type Document struct {
+    Type    string `json:"type"`
+    Number  string `json:"number"`
+    Country string `json:"country,omitempty"`
+    Expiry  string `json:"expiry,omitempty"`
+}
+
+// FullName returns the name of the person.
+func (i *Identity) FullName() string {
+    return strings.Join(strings.Fields(
+        i.FirstName+" "+i.MiddleName+" "+i.LastName), " ")
+}
+
+// Expired reports whether the document has expired at the given time.
+func (d *Document) Expired(now time.Time) bool {
+    expiry, err := time.Parse(DateLayout, d.Expiry)
+    return err == nil && !now.Before(expiry.AddDate(0, 0, 1))
+}
+
+func (d *Document) Validate() error {
+    valid := false
+
+    for _, t := range DocumentTypes {
+        valid = valid || d.Type == t
+    }
+
+    if !valid {
+        return ErrDocumentType
+    }
+
+    if d.Number == "" {
+        return ErrDocument
+    }
+
+    return validateDate(d.Expiry)
+}
+
+func validateDate(date string) error {
+    if date == "" {
+        return nil
+    }
+
+    if _, err := time.Parse(DateLayout, date); err != nil {
+        return ErrIdentityDate
+    }
+
+    return nil
+}
+
+func (i *Identity) Validate() error {
+    if err := validateDate(i.Birthday); err != nil {
+        return err
+    }
+
+    if i.Email != "" && !strings.Contains(i.Email, "@") {
+        return ErrIdentityEmail
+    }
+
+    for j := range i.Documents {
+        if err := i.Documents[j].Validate(); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func validateIdentity(d *DecodedEntry) error {
+    if d.Payload == nil {
+        return nil
+    }
+
+    identity, ok := d.Payload.(*Identity)
+
+    if !ok {
+        return ErrUnknownType
+    }
+
+    return identity.Validate()
+}
+
+func init() {
+    RegisterType(&EntryType{
+        ID:     TypeIdentity,
+        Label:  IdentityLabel,
+        Icon:   "identity",
+        View:   "Identity",
+        Suffix: IdentitySuffix,
+        Codec: JSONCodec{
+            New: func() interface{} { return &Identity{} },
+        },
+        Validate: validateIdentity,
+    })
+}
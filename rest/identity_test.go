@@ -0,0 +1,84 @@
+package rest
+
+import (
+    "testing"
+    "time"
+)
+
+func TestIdentityValidate(t *testing.T) {
+    identity := Identity{
+        FirstName: "Carl",
+        LastName:  "Löndahl",
+        Birthday:  "1985-06-01",
+        Email:     "carl@example.com",
+        Addresses: []Address{{Street: "Storgatan 1", City: "Lund", Country: "SE"}},
+        Documents: []Document{{Type: DocumentPassport, Number: "X123", Expiry: "2030-01-31"}},
+    }
+
+    if err := identity.Validate(); err != nil {
+        t.Fatalf("Valid identity rejected: %s", err)
+    }
+
+    if identity.FullName() != "Carl Löndahl" {
+        t.Errorf("Unexpected name %q", identity.FullName())
+    }
+
+    invalid := map[error]func(i *Identity){
+        ErrIdentityDate:  func(i *Identity) { i.Birthday = "01/06/1985" },
+        ErrIdentityEmail: func(i *Identity) { i.Email = "carl" },
+        ErrDocumentType:  func(i *Identity) { i.Documents[0].Type = "visa" },
+        ErrDocument:      func(i *Identity) { i.Documents[0].Number = "" },
+    }
+
+    for expected, change := range invalid {
+        i := identity
+        i.Documents = append([]Document{}, identity.Documents...)
+        change(&i)
+
+        if err := i.Validate(); err != expected {
+            t.Errorf("Expected %s, got %v", expected, err)
+        }
+    }
+}
+
+func TestDocumentExpired(t *testing.T) {
+    d := Document{Type: DocumentID, Number: "1", Expiry: "2030-01-31"}
+
+    if d.Expired(time.Date(2030, 1, 31, 23, 0, 0, 0, time.UTC)) {
+        t.Errorf("Document expired on its last day")
+    }
+
+    if !d.Expired(time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)) {
+        t.Errorf("Document did not expire")
+    }
+}
+
+func TestIdentityRoundTrip(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    entry := DecodedEntry{
+        Name: &Name{Text: "me"},
+        Type: TypeIdentity,
+        Payload: &Identity{
+            FirstName: "Carl",
+            Documents: []Document{{Type: DocumentID, Number: "1234"}},
+        },
+    }
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    read, err := r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if err != nil || read.Type != TypeIdentity {
+        t.Fatalf("Read error: %v", err)
+    }
+
+    identity := read.Payload.(*Identity)
+
+    if identity.FirstName != "Carl" || len(identity.Documents) != 1 {
+        t.Errorf("Identity did not survive a round trip")
+    }
+}
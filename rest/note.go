@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "strings"
+)
+
+const (
+    TypeNote = "note"
+
+    // Notes are text; anything larger belongs in a file.
+    MaximumNoteSize = 64 * 1024
+)
+
+var ErrNoteSize = errors.New("note is too large")
+
+// Note is the payload of secure notes. The text is Markdown.
+type Note struct {
+    Text string `json:"text"`
+}
+
+func validateNote(d *DecodedEntry) error {
+    if note, ok := d.Payload.(*Note); ok && len(note.Text) > MaximumNoteSize {
+        return ErrNoteSize
+    }
+    return nil
+}
+
+// MatchText reports whether every word of the query occurs in the
+// text, ignoring case.
+func MatchText(text string, query string) bool {
+    text = strings.ToLower(text)
+
+    for _, word := range strings.Fields(strings.ToLower(query)) {
+        if !strings.Contains(text, word) {
+            return false
+        }
+    }
+
+    return true
+}
+
+// SearchNotes searches the text of the notes among the given entries
+// and returns the keys of those that match. The server only ever sees
+// the encrypted notes; they are decrypted and searched here. Decrypted
+// notes are cached until they are changed, so that only the first
+// search reads them all.
+func (r *Client) SearchNotes(names []Name, query string) (map[string]bool, error) {
+    matches := map[string]bool{}
+
+    if strings.TrimSpace(query) == "" {
+        return matches, nil
+    }
+
+    if r.notes == nil {
+        r.notes = make(map[string]string)
+    }
+
+    for i := range names {
+        if names[i].Type != TypeNote {
+            continue
+        }
+
+        key := names[i].Encrypted
+        text, ok := r.notes[key]
+
+        if !ok {
+            entry, err := r.VaultReadSecret(&names[i])
+
+            if err != nil {
+                return matches, err
+            }
+
+            if note, ok := entry.Payload.(*Note); ok {
+                text = note.Text
+            }
+
+            r.notes[key] = text
+        }
+
+        if MatchText(text, query) {
+            matches[key] = true
+        }
+    }
+
+    return matches, nil
+}
+
+func init() {
+    RegisterType(&EntryType{
+        ID:     TypeNote,
+        Label:  NoteLabel,
+        Icon:   "note",
+        View:   "Note",
+        Suffix: NoteSuffix,
+        Codec: JSONCodec{
+            New: func() interface{} { return &Note{} },
+        },
+        Validate: validateNote,
+    })
+}
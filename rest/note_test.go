@@ -0,0 +1,69 @@
+package rest
+
+import (
+    "pass/lock"
+    "strings"
+    "testing"
+)
+
+func TestMatchText(t *testing.T) {
+    text := "The **wifi** password at the cabin is on the fridge."
+
+    if !MatchText(text, "Cabin WIFI") || !MatchText(text, "") {
+        t.Errorf("Matching text was not found")
+    }
+
+    if MatchText(text, "cabin garage") {
+        t.Errorf("Text matched a word it does not contain")
+    }
+}
+
+func TestSearchNotes(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    entries := []DecodedEntry{
+        {Name: &Name{Text: "cabin,note"}, Payload: &Note{Text: "Wifi: hunter2"}},
+        {Name: &Name{Text: "recipes,note"}, Payload: &Note{Text: "Pancakes"}},
+        {Name: &Name{Text: "wifi"}, Password: lock.SecureCopy([]byte("hunter2"))},
+    }
+
+    for i := range entries {
+        if err := r.VaultWriteSecret(&entries[i]); err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+
+    names, _ := r.VaultListSecrets()
+    matches, err := r.SearchNotes(*names, "HUNTER2")
+
+    if err != nil || len(matches) != 1 || !matches[entries[0].Name.Encrypted] {
+        t.Fatalf("Unexpected matches %v: %v", matches, err)
+    }
+
+    // The notes are cached, so searching again reads nothing.
+    reads := vault.reads
+    r.SearchNotes(*names, "pancakes")
+
+    if vault.reads != reads {
+        t.Errorf("Notes were read again")
+    }
+
+    // ...until they change.
+    entries[1].Payload = &Note{Text: "Waffles"}
+    r.VaultWriteSecret(&entries[1])
+    matches, _ = r.SearchNotes(*names, "pancakes")
+
+    if len(matches) != 0 {
+        t.Errorf("Search found an old note")
+    }
+
+    large := DecodedEntry{
+        Name:    &Name{Text: "large,note"},
+        Payload: &Note{Text: strings.Repeat("x", MaximumNoteSize+1)},
+    }
+
+    if err := r.VaultWriteSecret(&large); err != ErrNoteSize {
+        t.Errorf("Large note was written")
+    }
+}
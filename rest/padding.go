@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "math/bits"
+    "pass/lock"
+)
+
+// A Padding maps the length of a serialized entry to the length it is
+// padded to. Entries which end up in the same bucket are
+// indistinguishable by the length of their ciphertexts.
+type Padding func(length int) int
+
+const (
+    PaddingNameMinimum    = "minimum"
+    PaddingNamePowerOfTwo = "pow2"
+    PaddingNamePadme      = "padme"
+
+    DefaultPadding = PaddingNamePadme
+
+    // The smallest Padmé bucket. It is larger than a typical account
+    // with its URLs and timestamps, so that the length of the password
+    // does not show.
+    MinimumBucketLength = 512
+)
+
+var ErrUnknownPadding = errors.New("unknown padding policy")
+
+// MinimumPadding only pads short entries up to MinimumDataLength. This
+// was the behaviour of earlier versions.
+func MinimumPadding(length int) int {
+    if length < MinimumDataLength {
+        return MinimumDataLength
+    }
+    return length
+}
+
+// PowerOfTwoPadding rounds up to the next power of two. It leaks at
+// most log log of the length, but wastes up to half of the space.
+func PowerOfTwoPadding(length int) int {
+    length = MinimumPadding(length)
+
+    if length&(length-1) == 0 {
+        return length
+    }
+
+    return 1 << uint(bits.Len(uint(length)))
+}
+
+// PadmePadding implements Padmé (Nikitin et al., "Reducing Metadata
+// Leakage from Encrypted Files and Communication with PURBs"). Like
+// powers of two it leaks O(log log L) bits, but the overhead is at
+// most 12%, which matters for files. Entries shorter than
+// MinimumBucketLength all share the smallest bucket.
+func PadmePadding(length int) int {
+    if length < MinimumBucketLength {
+        return MinimumBucketLength
+    }
+
+    e := bits.Len(uint(length)) - 1
+    s := bits.Len(uint(e))
+    mask := (1 << uint(e-s)) - 1
+
+    return (length + mask) &^ mask
+}
+
+// GetPadding returns the policy with the given name, as found in the
+// configuration. The empty name yields the default policy.
+func GetPadding(name string) (Padding, error) {
+    switch name {
+    case "":
+        return GetPadding(DefaultPadding)
+    case PaddingNameMinimum:
+        return MinimumPadding, nil
+    case PaddingNamePowerOfTwo:
+        return PowerOfTwoPadding, nil
+    case PaddingNamePadme:
+        return PadmePadding, nil
+    }
+
+    return nil, ErrUnknownPadding
+}
+
+// Pad serializes the user data, with its padding chosen such that the
+// result has exactly the length of a bucket.
+func (r *Client) Pad(userData *UserData) ([]byte, error) {
+    policy := r.Padding
+
+    if policy == nil {
+        policy = MinimumPadding
+    }
+
+    // Measure the entry without any padding...
+    userData.Padding = ""
+    jsonUserData, err := json.Marshal(userData)
+
+    if err != nil {
+        return nil, err
+    }
+
+    // ...and fill up to the bucket. Hex characters are not escaped by
+    // the JSON encoder, so every character adds exactly one byte.
+    length := policy(len(jsonUserData)) - len(jsonUserData)
+
+    if length <= 0 {
+        return jsonUserData, nil
+    }
+
+    lock.Zero(jsonUserData)
+    userData.Padding = hex.EncodeToString(lock.Entropy((length + 1) / 2))[:length]
+
+    return json.Marshal(userData)
+}
+
+// Repad reads and writes back every entry, so that all entries are
+// padded according to the current policy. The entries are written as
+// they are, keeping their timestamps, even those which would not pass
+// validation today. It returns the number of entries rewritten.
+func (r *Client) Repad() (int, error) {
+    // Make sure we get a fresh list.
+    r.LocalUpdate = true
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        return 0, err
+    }
+
+    // Copy the list, since every write invalidates the cached one.
+    entries := append([]Name{}, (*names)...)
+    count := 0
+
+    for i := range entries {
+        entry, err := r.VaultReadSecret(&entries[i])
+
+        if err != nil {
+            return count, err
+        }
+
+        if err = r.writeSecret(entry); err != nil {
+            return count, err
+        }
+
+        count++
+    }
+
+    return count, nil
+}
@@ -0,0 +1,117 @@
+package rest
+
+import (
+    "pass/lock"
+    "testing"
+    "time"
+)
+
+func TestPaddingBuckets(t *testing.T) {
+    cases := []struct {
+        padding  Padding
+        length   int
+        expected int
+    }{
+        {MinimumPadding, 10, MinimumDataLength},
+        {MinimumPadding, 200, 200},
+        {PowerOfTwoPadding, 10, 128},
+        {PowerOfTwoPadding, 128, 128},
+        {PowerOfTwoPadding, 129, 256},
+        {PadmePadding, 10, MinimumBucketLength},
+        {PadmePadding, 500, MinimumBucketLength},
+        {PadmePadding, 1000, 1024},
+        {PadmePadding, 100000, 100352},
+    }
+
+    for _, c := range cases {
+        if got := c.padding(c.length); got != c.expected {
+            t.Errorf("Wrong bucket for %d, got: %d, want: %d.",
+                c.length, got, c.expected)
+        }
+    }
+}
+
+func TestPadmeOverhead(t *testing.T) {
+    for length := 1; length < 1<<20; length += 997 {
+        padded := PadmePadding(length)
+
+        if padded < length {
+            t.Errorf("Padded length %d is shorter than %d", padded, length)
+        }
+
+        if length > MinimumBucketLength && float64(padded-length)/float64(length) > 0.12 {
+            t.Errorf("Overhead for %d is too large: %d", length, padded)
+        }
+    }
+}
+
+func TestPadHidesPasswordLength(t *testing.T) {
+    mylock := lock.Lock{Key: lock.SecureCopy(lock.Entropy(32))}
+    r := New(&mylock)
+    r.Padding = PadmePadding
+
+    short, _ := r.Pad(&UserData{Username: "user", Password: lock.SecureCopy([]byte("a"))})
+    long, _ := r.Pad(&UserData{Username: "user", Password: lock.SecureCopy([]byte(lock.EntropyAlphabet(20)))})
+
+    if len(short) != len(long) {
+        t.Errorf("Lengths differ, got: %d and %d.", len(short), len(long))
+    }
+
+    if len(short) != PadmePadding(len(short)) {
+        t.Errorf("Serialized entry is not aligned to a bucket: %d", len(short))
+    }
+
+    // Nor for a typical account, with URLs, a tag and timestamps.
+    now := time.Now().UTC()
+    account := UserData{
+        Version:  SchemaVersion,
+        Name:     "Work/github",
+        Type:     TypeAccount,
+        Username: "carl@example.com",
+        URLs:     []string{"https://github.com/login", "https://gist.github.com"},
+        Tags:     []string{"work"},
+        Created:  now,
+        Modified: now,
+        LastUsed: now,
+    }
+
+    account.Password = lock.SecureCopy([]byte("a"))
+    short, _ = r.Pad(&account)
+    account.Password = lock.SecureCopy([]byte(lock.EntropyAlphabet(64)))
+    long, _ = r.Pad(&account)
+
+    if len(short) != len(long) {
+        t.Errorf("Lengths of accounts differ, got: %d and %d.", len(short), len(long))
+    }
+}
+
+func TestRepadKeepsEntries(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    modified := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    // A legacy entry with a URL which is no longer accepted.
+    entries := []*DecodedEntry{
+        {Name: &Name{Text: "github"}, Type: TypeAccount, URLs: []string{"https://github.com"}, Modified: modified},
+        {Name: &Name{Text: "ghcr"}, Type: TypeAccount, URLs: []string{"ghcr.io"}, Modified: modified},
+    }
+
+    for _, d := range entries {
+        if err := r.writeSecret(d); err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+
+    if n, err := r.Repad(); err != nil || n != 2 {
+        t.Fatalf("Re-padded %d entries: %v", n, err)
+    }
+
+    for _, d := range entries {
+        entry, err := r.VaultReadSecret(d.Name)
+
+        if err != nil || !entry.Modified.Equal(modified) {
+            t.Errorf("Entry marked as modified: %v %v", entry.Modified, err)
+        }
+    }
+}
@@ -0,0 +1,317 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "pass/lock"
+    "strings"
+)
+
+// Changing the master password changes the key every entry and every
+// encrypted name is encrypted with. Entries are copied under the new
+// key rather than rewritten in place: an entry stored with one key
+// cannot be read with the other, so clients with the old password do
+// not see the copies, and clients with the new password do not see the
+// originals. The vault stays usable with the old password until the
+// configuration is switched to the new one, and the originals are only
+// deleted after that.
+
+var (
+    ErrVerify = errors.New("re-encrypted entry does not match the original")
+
+    // An entry which cannot be decrypted with our key, e.g., a copy
+    // already made with the new key.
+    errForeign = errors.New("entry is encrypted with another key")
+)
+
+// Fetches and decrypts the payload stored under a key, as it is. The
+// caller has to wipe it.
+func (r *Client) readPlaintext(key string) (*lock.SecureBuffer, error) {
+    vaultResponse, err := r.Request(http.MethodGet, "/"+key, nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if len(vaultResponse.Data.Encrypted) == 0 {
+        return nil, ErrNotFound
+    }
+
+    plaintext, err := r.Lock.DecryptBase64(vaultResponse.Data.Encrypted)
+
+    if err != nil {
+        return nil, errForeign
+    }
+
+    return plaintext, nil
+}
+
+// Encrypts and stores a payload under a key, as it is.
+func (r *Client) writePlaintext(key string, plaintext []byte) error {
+    encrypted, err := r.Lock.EncryptBase64(plaintext)
+
+    if err != nil {
+        return err
+    }
+
+    jsonVaultRequestEncrypted, _ := json.Marshal(MyRequestEncrypted{Encrypted: encrypted})
+    _, err = r.Request(http.MethodPut, "/"+key, bytes.NewBuffer(jsonVaultRequestEncrypted))
+
+    return err
+}
+
+// Stores a payload and reads it back, to make sure it arrived.
+func (r *Client) writeVerified(key string, plaintext []byte) error {
+    if err := r.writePlaintext(key, plaintext); err != nil {
+        return err
+    }
+
+    stored, err := r.readPlaintext(key)
+
+    if err == ErrNotFound || err == errForeign {
+        return ErrVerify
+    }
+
+    if err != nil {
+        return err
+    }
+
+    defer stored.Wipe()
+
+    if !bytes.Equal(stored.Bytes(), plaintext) {
+        return ErrVerify
+    }
+
+    return nil
+}
+
+// Returns the keys of the mount, except the tag.
+func (r *Client) listKeys() ([]string, error) {
+    vaultResponse, err := r.Request(MethodList, "", nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if len(vaultResponse.Errors) > 0 {
+        return nil, errors.New(vaultResponse.Errors[0])
+    }
+
+    keys := make([]string, 0, len(vaultResponse.Data.Keys))
+
+    for _, key := range vaultResponse.Data.Keys {
+        if "/"+key != TagPath && !strings.HasSuffix(key, "/") {
+            keys = append(keys, key)
+        }
+    }
+
+    return keys, nil
+}
+
+// The key under which the client to stores the copy of the entry
+// stored under key: its encrypted name, encrypted anew, or the blind
+// index of its name.
+func (r *Client) copyKey(to *Client, key string, plaintext []byte) (string, error) {
+    if text, err := r.decName(key); err == nil {
+        return to.EncHex(text)
+    }
+
+    // Only the name and type, so that no secrets are decoded.
+    var userData struct {
+        Name string `json:"name"`
+        Type string `json:"type"`
+    }
+
+    if err := json.Unmarshal(plaintext, &userData); err != nil {
+        return "", err
+    }
+
+    if userData.Name == "" {
+        return "", ErrNoBlindName
+    }
+
+    return to.Lock.BlindIndex(IndexName(userData.Name, userData.Type)), nil
+}
+
+// Rekey copies every entry which r can read to the client to, which
+// has the new key, and returns the number of entries copied.
+//
+// The map keys, from the keys of the entries to the keys of their
+// copies, is the journal of the copy: save is called to store it
+// before an entry is copied, so that an interrupted copy can be
+// resumed, by calling Rekey with the map again, without leaving copies
+// behind which nobody knows of. Every copy is read back and compared
+// with the original. Rekey only returns once a pass over the vault
+// finds every entry copied and unchanged, so entries written or
+// deleted meanwhile with the old password are taken along.
+func (r *Client) Rekey(to *Client, keys map[string]string, save func() error) (int, error) {
+    total := 0
+
+    for {
+        changed, err := r.rekeyPass(to, keys, save)
+        total += changed
+
+        if err != nil {
+            return total, err
+        }
+
+        if changed == 0 {
+            break
+        }
+    }
+
+    return total, to.UpdateTag()
+}
+
+// Makes one pass over the vault and returns the number of copies made
+// or removed.
+func (r *Client) rekeyPass(to *Client, keys map[string]string, save func() error) (int, error) {
+    listed, err := r.listKeys()
+
+    if err != nil {
+        return 0, err
+    }
+
+    copies := make(map[string]bool, len(keys))
+
+    for _, copyKey := range keys {
+        copies[copyKey] = true
+    }
+
+    present := make(map[string]bool, len(listed))
+    changed := 0
+
+    for _, key := range listed {
+        if copies[key] {
+            continue
+        }
+
+        copied, err := r.rekeyEntry(to, key, keys, save)
+
+        if err == errForeign || err == ErrNotFound {
+            continue
+        }
+
+        if err != nil {
+            return changed, err
+        }
+
+        present[key] = true
+
+        if copied {
+            changed++
+        }
+    }
+
+    // Entries deleted since they were copied.
+    for key, copyKey := range keys {
+        if present[key] {
+            continue
+        }
+
+        if err = to.deleteKey(copyKey); err != nil {
+            return changed, err
+        }
+
+        delete(keys, key)
+
+        if err = save(); err != nil {
+            return changed, err
+        }
+
+        changed++
+    }
+
+    return changed, nil
+}
+
+// Copies an entry unless an identical copy exists, and tells whether
+// it did.
+func (r *Client) rekeyEntry(to *Client, key string, keys map[string]string, save func() error) (bool, error) {
+    plaintext, err := r.readPlaintext(key)
+
+    if err != nil {
+        return false, err
+    }
+
+    defer plaintext.Wipe()
+
+    copyKey, ok := keys[key]
+
+    if ok {
+        stored, err := to.readPlaintext(copyKey)
+
+        if err == nil {
+            equal := bytes.Equal(stored.Bytes(), plaintext.Bytes())
+            stored.Wipe()
+
+            if equal {
+                return false, nil
+            }
+        } else if err != ErrNotFound && err != errForeign {
+            return false, err
+        }
+    } else {
+        if copyKey, err = r.copyKey(to, key, plaintext.Bytes()); err != nil {
+            return false, err
+        }
+
+        keys[key] = copyKey
+
+        if err = save(); err != nil {
+            return false, err
+        }
+    }
+
+    return true, to.writeVerified(copyKey, plaintext.Bytes())
+}
+
+func (r *Client) deleteKey(key string) error {
+    _, err := r.Request(http.MethodDelete, "/"+key, nil)
+    return err
+}
+
+// DeleteKeys deletes the entries stored under the given keys, e.g., the
+// originals once the copies made by Rekey are in use. Deleting needs
+// the token only, not the key the entries are encrypted with.
+func (r *Client) DeleteKeys(keys []string) error {
+    for _, key := range keys {
+        if err := r.deleteKey(key); err != nil {
+            return err
+        }
+    }
+
+    return r.UpdateTag()
+}
@@ -0,0 +1,210 @@
+package rest
+
+import (
+    "errors"
+    "pass/lock"
+    "testing"
+)
+
+// A client of the same vault with another key.
+func newRekeyClient(r *Client) *Client {
+    mylock := lock.Lock{Key: lock.SecureCopy(lock.Entropy(32))}
+    to := New(&mylock)
+    to.EntryPoint = r.EntryPoint
+    to.Client = r.Client
+    to.BlindIndex = r.BlindIndex
+
+    return &to
+}
+
+func writeRekeyEntries(t *testing.T, r *Client) {
+    entries := []DecodedEntry{
+        {Name: &Name{Text: "github"}, Password: lock.SecureCopy([]byte("banana"))},
+        {Name: &Name{Text: "shopping"}, Type: TypeNote, Payload: &Note{Text: "milk"}},
+    }
+
+    for i := range entries {
+        if err := r.VaultWriteSecret(&entries[i]); err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+}
+
+func listRekeyNames(t *testing.T, r *Client) map[string]bool {
+    r.names = make(map[string]Name)
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        t.Fatalf("List error: %s", err)
+    }
+
+    result := make(map[string]bool)
+
+    for _, name := range *names {
+        result[name.Text] = true
+    }
+
+    return result
+}
+
+func TestRekey(t *testing.T) {
+    for _, blind := range []bool{false, true} {
+        r, vault, done := newFakeClient(t)
+        r.BlindIndex = blind
+        writeRekeyEntries(t, r)
+
+        to := newRekeyClient(r)
+        keys := make(map[string]string)
+        n, err := r.Rekey(to, keys, func() error { return nil })
+
+        if err != nil || n != 2 || len(keys) != 2 {
+            t.Fatalf("Rekey copied %d entries: %v", n, err)
+        }
+
+        // Each client sees its own entries only.
+        if names := listRekeyNames(t, r); len(names) != 2 {
+            t.Errorf("Copies visible with the old key: %v", names)
+        }
+
+        if names := listRekeyNames(t, to); len(names) != 2 || !names["github"] || !names["shopping"] {
+            t.Errorf("Copies not visible with the new key: %v", names)
+        }
+
+        entry, err := to.VaultFindSecret("github", "")
+
+        if err != nil || !entry.HasPassword("banana") {
+            t.Errorf("Copy not readable: %v", err)
+        }
+
+        // A second run has nothing left to do.
+        if n, err = r.Rekey(to, keys, func() error { return nil }); err != nil || n != 0 {
+            t.Errorf("Second rekey copied %d entries: %v", n, err)
+        }
+
+        old := make([]string, 0, len(keys))
+
+        for key := range keys {
+            old = append(old, key)
+        }
+
+        if err = to.DeleteKeys(old); err != nil {
+            t.Fatalf("Delete error: %s", err)
+        }
+
+        if names := listRekeyNames(t, r); len(names) != 0 {
+            t.Errorf("Originals not deleted: %v", names)
+        }
+
+        // The copies and the tag.
+        if len(vault.data) != 3 {
+            t.Errorf("Unexpected entries left: %d", len(vault.data))
+        }
+
+        done()
+    }
+}
+
+func TestRekeyResume(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    writeRekeyEntries(t, r)
+    to := newRekeyClient(r)
+    keys := make(map[string]string)
+
+    // Interrupted when the journal of the second entry is saved.
+    saves := 0
+    fail := errors.New("interrupted")
+    _, err := r.Rekey(to, keys, func() error {
+        saves++
+
+        if saves == 2 {
+            return fail
+        }
+
+        return nil
+    })
+
+    if err != fail {
+        t.Fatalf("Rekey not interrupted: %v", err)
+    }
+
+    // Only entries in the journal have been copied.
+    for key := range vault.data {
+        if key == "updated" {
+            continue
+        }
+
+        if _, err := to.readPlaintext(key); err == errForeign {
+            continue
+        }
+
+        found := false
+
+        for _, copyKey := range keys {
+            found = found || copyKey == key
+        }
+
+        if !found {
+            t.Errorf("Copy not in the journal: %s", key)
+        }
+    }
+
+    if _, err = r.Rekey(to, keys, func() error { return nil }); err != nil {
+        t.Fatalf("Resume error: %s", err)
+    }
+
+    if names := listRekeyNames(t, to); len(names) != 2 {
+        t.Errorf("Resume did not copy everything: %v", names)
+    }
+}
+
+func TestRekeyChanges(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    writeRekeyEntries(t, r)
+    to := newRekeyClient(r)
+    keys := make(map[string]string)
+
+    if _, err := r.Rekey(to, keys, func() error { return nil }); err != nil {
+        t.Fatalf("Rekey error: %s", err)
+    }
+
+    // Changed and deleted with the old key after being copied.
+    entry, err := r.VaultFindSecret("github", "")
+
+    if err != nil {
+        t.Fatalf("Find error: %s", err)
+    }
+
+    entry.SetPassword("apple")
+
+    if err = r.VaultWriteSecret(entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    note, err := r.VaultFindSecret("shopping", TypeNote)
+
+    if err != nil {
+        t.Fatalf("Find error: %s", err)
+    }
+
+    if err = r.VaultDeleteSecret(note); err != nil {
+        t.Fatalf("Delete error: %s", err)
+    }
+
+    n, err := r.Rekey(to, keys, func() error { return nil })
+
+    if err != nil || n != 2 || len(keys) != 1 {
+        t.Fatalf("Rekey changed %d entries: %v", n, err)
+    }
+
+    if entry, err = to.VaultFindSecret("github", ""); err != nil || !entry.HasPassword("apple") {
+        t.Errorf("Change not copied: %v", err)
+    }
+
+    if names := listRekeyNames(t, to); len(names) != 1 {
+        t.Errorf("Deletion not copied: %v", names)
+    }
+}
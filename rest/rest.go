@@ -32,24 +32,43 @@ package rest
 
 import (
     "bytes"
+    "context"
     "crypto/tls"
     "crypto/x509"
     "encoding/json"
     "fmt"
     "io/ioutil"
-    "log"
     "net/http"
     "pass/lock"
+    "pass/logger"
+    "pass/observe"
+    "pass/urlmatch"
     "time"
     "errors"
 )
 
 type (
     UserData struct {
-        Password string `json:"password"`
-        Username string `json:"username"`
-        File     []byte `json:"file"`
-        Padding  string `json:"padding"`
+        Version  int                `json:"version"`
+        Name     string             `json:"name,omitempty"`
+        Password *lock.SecureBuffer `json:"password"`
+        Username string             `json:"username"`
+        File     []byte             `json:"file"`
+
+        // The type of the entry and its type specific payload.
+        Type string          `json:"type,omitempty"`
+        Data json.RawMessage `json:"data,omitempty"`
+
+        URLs     []string  `json:"urls,omitempty"`
+        Match    string    `json:"match,omitempty"`
+        Notes    string    `json:"notes,omitempty"`
+        Fields   []Field   `json:"fields,omitempty"`
+        Tags     []string  `json:"tags,omitempty"`
+        Created  time.Time `json:"created"`
+        Modified time.Time `json:"modified"`
+        LastUsed time.Time `json:"lastused"`
+
+        Padding string `json:"padding"`
     }
 
     MyRequestTag struct {
@@ -71,14 +90,41 @@ type (
 
     Name struct {
         Text      string
+        Type      string
         Encrypted string
     }
 
     DecodedEntry struct {
         Name     *Name
+        Type     string
         Username string
-        Password string
         File     []byte
+
+        // The password, or the secret of an OTP entry. It stays in
+        // locked memory until a front end shows it.
+        Password *lock.SecureBuffer
+
+        // The decoded type specific payload, e.g., a *KeyPair.
+        Payload interface{}
+
+        // The stored payload of an entry of a type we do not know, so
+        // that it survives when the entry is written back.
+        data json.RawMessage
+
+        URLs []string
+
+        // How the URLs are matched, see urlmatch. By domain if empty.
+        Match string
+
+        Notes    string
+        Fields   []Field
+        Tags     []string
+        Created  time.Time
+        Modified time.Time
+        LastUsed time.Time
+
+        // The schema version the entry was stored with.
+        Version int
     }
 )
 
@@ -88,28 +134,88 @@ const (
     MinimumDataLength = 3 * 32
 
     MethodList = "LIST"
+
+    // Number of times an idempotent request is retried when the
+    // transport fails, e.g., due to a dropped connection.
+    MaxRequestRetries = 2
+    RetryBackoff      = 250 * time.Millisecond
 )
 
+var (
+    ErrNoStorageKey = errors.New("no encrypted data stored")
+    ErrCanceled     = errors.New("client was canceled")
+)
+
+// Entries and names format themselves without revealing their contents,
+// so that they can be passed to the logger (or fmt) safely.
+func (n Name) Format(f fmt.State, c rune) {
+    fmt.Fprint(f, logger.Name(n.Text))
+}
+
+func (d DecodedEntry) Format(f fmt.State, c rune) {
+    var name logger.Name
+
+    if d.Name != nil {
+        name = logger.Name(d.Name.Text)
+    }
+
+    fmt.Fprint(f, "{", name, " ", logger.Password(""), " ",
+        logger.File(d.File), " ", len(d.URLs), " urls ", len(d.Fields),
+        " fields}")
+}
+
 type Client struct {
     LocalUpdate  bool
     CachedTag    string
     SearchResult []Name
 
-    DecryptedToken string
+    // The token, which is only turned into a string for the request
+    // header, since net/http takes nothing else. The string is built
+    // once per token and kept in tokenHeader.
+    DecryptedToken *lock.SecureBuffer
+    tokenHeader    string
+    tokenFor       *lock.SecureBuffer
     EncryptionKey  []byte
     Lock           *lock.Lock
 
     Client     *http.Client
     EntryPoint string
+
+    Observer observe.Observer
+    Padding  Padding
+
+    // If set, new entries are stored under a blind index of their
+    // name. Names and types which had to be fetched from the payload
+    // are cached by their key.
+    BlindIndex bool
+    names      map[string]Name
+
+    // Decrypted notes by key, for full-text search.
+    notes map[string]string
+
+    // URLs and match modes by key, for searching by URL.
+    targets map[string]urlmatch.Target
+
+    // Done once the client is canceled, which aborts its requests.
+    ctx    context.Context
+    cancel context.CancelFunc
 }
 
 func New(lock *lock.Lock) Client {
+    ctx, cancel := context.WithCancel(context.Background())
 
     r := Client{
         LocalUpdate:    true,
         Client:         nil,
         Lock:           lock,
-        DecryptedToken: "",
+        DecryptedToken: nil,
+        Observer:       observe.Nop{},
+        Padding:        PadmePadding,
+        names:          make(map[string]Name),
+        notes:          make(map[string]string),
+        targets:        make(map[string]urlmatch.Target),
+        ctx:            ctx,
+        cancel:         cancel,
     }
 
     return r
@@ -122,12 +228,78 @@ func (r *Client) Unlock(encryptedToken string) error {
         return err
     }
 
+    r.DecryptedToken.Wipe()
     r.DecryptedToken = t
-    log.Println(t)
+    logger.Debug("Token unlocked.")
 
     return nil
 }
 
+// Wipe overwrites the key and the token and forgets the names and
+// everything decrypted which the client keeps, when Pass is locked.
+// The client cannot be used afterwards.
+func (r *Client) Wipe() {
+    r.Cancel()
+
+    if r.Lock != nil {
+        r.Lock.Wipe()
+    }
+
+    lock.Zero(r.EncryptionKey)
+    r.EncryptionKey = nil
+    r.DecryptedToken.Wipe()
+    r.DecryptedToken = nil
+    r.tokenHeader = ""
+    r.tokenFor = nil
+    r.SearchResult = nil
+    r.Invalidate()
+}
+
+// Invalidate forgets the names, notes and URLs cached by the client,
+// e.g., after another client has rewritten the entries, so that they
+// are fetched again.
+func (r *Client) Invalidate() {
+    r.CachedTag = "-"
+    r.names = make(map[string]Name)
+    r.notes = make(map[string]string)
+    r.targets = make(map[string]urlmatch.Target)
+}
+
+// Cancel aborts the requests of the client, and makes all further ones
+// fail with ErrCanceled, e.g., to stop a clone at work in the background
+// when Pass is locked. It may be called while the client is in use.
+func (r *Client) Cancel() {
+    if r.cancel != nil {
+        r.cancel()
+    }
+}
+
+// The context of the requests.
+func (r *Client) context() context.Context {
+    if r.ctx == nil {
+        return context.Background()
+    }
+
+    return r.ctx
+}
+
+// Clone returns a client for the same vault, with copies of the key and
+// the token and caches of its own, which can be used concurrently with
+// r. It has to be wiped separately.
+func (r *Client) Clone() *Client {
+    l := lock.Lock{Key: r.Lock.Key.Clone(), Salt: r.Lock.Salt}
+    c := New(&l)
+    c.DecryptedToken = r.DecryptedToken.Clone()
+    c.Client = r.Client
+    c.EntryPoint = r.EntryPoint
+    c.CachedTag = "-"
+    c.Observer = r.Observer
+    c.Padding = r.Padding
+    c.BlindIndex = r.BlindIndex
+
+    return &c
+}
+
 func (r *Client) Init(hostname string, port int, CA string) {
     // Setup entrypoint
     r.EntryPoint = fmt.Sprintf("https://%s:%v/v1/secret", hostname, port)
@@ -153,9 +325,8 @@ func (r *Client) EncHex(data string) (string, error) {
     return encData, err
 }
 
-func (r *Client) DecHex(data string) (string, error) {
-    encData, err := r.Lock.HexDecodeAndDecrypt(data)
-    return encData, err
+func (r *Client) DecHex(data string) (*lock.SecureBuffer, error) {
+    return r.Lock.DecryptHex(data)
 }
 
 func (r *Client) EncBase64(data string) ([]byte, error) {
@@ -163,31 +334,132 @@ func (r *Client) EncBase64(data string) ([]byte, error) {
     return encData, err
 }
 
-func (r *Client) DecBase64(data []byte) (string, error) {
-    encData, err := r.Lock.Base64DecodeAndDecrypt(data)
-    return encData, err
+func (r *Client) DecBase64(data []byte) (*lock.SecureBuffer, error) {
+    return r.Lock.DecryptBase64(data)
+}
+
+// The text of an encrypted name. Names are no secrets, but they are
+// shown and compared as strings anyway.
+func (r *Client) decName(data string) (string, error) {
+    b, err := r.DecHex(data)
+
+    if err != nil {
+        return "", err
+    }
+
+    defer b.Wipe()
+    return string(b.Bytes()), nil
+}
+
+// The value of the token header. Turning the token into a string copies
+// it to memory which cannot be wiped, so this is done only once for
+// each token the client is given.
+func (r *Client) header() string {
+    if r.tokenFor != r.DecryptedToken {
+        r.tokenHeader = string(r.DecryptedToken.Bytes())
+        r.tokenFor = r.DecryptedToken
+    }
+
+    return r.tokenHeader
+}
+
+// The operation reported to the observer. Only the method and whether
+// the tag path was accessed is used; the path itself contains the
+// (encrypted) entry name and is never recorded.
+func operationName(operation string, s string) string {
+    if s == TagPath {
+        if operation == http.MethodGet {
+            return observe.OperationTagRead
+        }
+        return observe.OperationTagWrite
+    }
+
+    switch operation {
+    case MethodList:
+        return observe.OperationList
+    case http.MethodGet:
+        return observe.OperationRead
+    case http.MethodPut:
+        return observe.OperationWrite
+    case http.MethodDelete:
+        return observe.OperationDelete
+    }
+
+    return operation
+}
+
+func isIdempotent(operation string) bool {
+    return operation == http.MethodGet || operation == MethodList
 }
 
 func (r *Client) Request(operation string, s string, data *bytes.Buffer) (MyResponse, error) {
     var err error
     var req *http.Request
+    var resp *http.Response
+    var payload []byte
+    var retries, size int
 
-    // These two cases need to be handled separarely, i.e., the buffer must
-    // explicitly be set to nil, we cannot pass a pointer with nil, or
-    // program will throw a SIGSEGV.
+    start := time.Now()
+    outcome := observe.OutcomeOK
+
+    // Keep the payload around, so that the request can be rebuilt
+    // should we need to retry it.
     if data != nil {
-        req, err = http.NewRequest(operation, r.EntryPoint+s, data)
-    } else {
-        req, err = http.NewRequest(operation, r.EntryPoint+s, nil)
+        payload = data.Bytes()
+        size = len(payload)
     }
 
-    if err != nil {
-        return MyResponse{}, err
-    }
+    defer func() {
+        if err != nil {
+            outcome = observe.OutcomeError
+        }
 
-    // Add header and do a GET for the specified entry...
-    req.Header.Add(VaultTokenHeader, r.DecryptedToken)
-    resp, err := r.Client.Do(req)
+        r.observe(observe.Event{
+            Operation: operationName(operation, s),
+            Start:     start,
+            Duration:  time.Since(start),
+            Bytes:     size,
+            Retries:   retries,
+            Outcome:   outcome,
+        })
+    }()
+
+    ctx := r.context()
+
+    for {
+        if ctx.Err() != nil {
+            err = ErrCanceled
+            return MyResponse{}, err
+        }
+
+        // These two cases need to be handled separarely, i.e., the buffer must
+        // explicitly be set to nil, we cannot pass a pointer with nil, or
+        // program will throw a SIGSEGV.
+        if data != nil {
+            req, err = http.NewRequestWithContext(ctx, operation, r.EntryPoint+s, bytes.NewReader(payload))
+        } else {
+            req, err = http.NewRequestWithContext(ctx, operation, r.EntryPoint+s, nil)
+        }
+
+        if err != nil {
+            return MyResponse{}, err
+        }
+
+        // Add header and do a GET for the specified entry...
+        req.Header.Add(VaultTokenHeader, r.header())
+        resp, err = r.Client.Do(req)
+
+        // ...and retry reads if the transport failed.
+        if err != nil && ctx.Err() != nil {
+            err = ErrCanceled
+        } else if err != nil && isIdempotent(operation) && retries < MaxRequestRetries {
+            retries++
+            time.Sleep(RetryBackoff * time.Duration(retries))
+            continue
+        }
+
+        break
+    }
 
     // This should not happen, unless entry was deleted in the meantime...
     if err != nil {
@@ -198,12 +470,29 @@ func (r *Client) Request(operation string, s string, data *bytes.Buffer) (MyResp
     defer resp.Body.Close()
     body, err := ioutil.ReadAll(resp.Body)
 
+    if err != nil {
+        return MyResponse{}, err
+    }
+
+    // Record the status class, e.g., http_4xx, for failed requests.
+    if resp.StatusCode >= 300 {
+        outcome = fmt.Sprintf("http_%dxx", resp.StatusCode/100)
+    }
+
+    size += len(body)
+
     response := MyResponse{}
     json.Unmarshal([]byte(body), &response)
 
     return response, nil
 }
 
+func (r *Client) observe(e observe.Event) {
+    if r.Observer != nil {
+        r.Observer.Observe(e)
+    }
+}
+
 func (r *Client) UpdateTag() error {
     // Let the client know that we did an tag update and therefore
     // do not need to check it.
@@ -252,24 +541,65 @@ func (r *Client) IsTagUpdated() bool {
 }
 
 func (r *Client) VaultReadSecret(data *Name) (*DecodedEntry, error) {
-    log.Println("READ")
+    logger.Debug("READ", data)
+
+    // Entries stored under a blind index can be found by name alone.
+    if (*data).Encrypted == "" {
+        if !r.BlindIndex {
+            return nil, ErrNoStorageKey
+        }
+        (*data).Encrypted = r.Lock.BlindIndex(IndexName((*data).Text, (*data).Type))
+    }
 
-    // Retrieve data for a specific account.
-    vaultResponse, err := r.Request(http.MethodGet, "/"+(*data).Encrypted, nil)
+    userData, err := r.readUserData((*data).Encrypted)
 
     if err != nil {
         return nil, err
     }
 
-    // Decrypt
-    decryptedData, err := r.DecBase64(vaultResponse.Data.Encrypted)
+    // Bring the entry up to the current schema. Old entries only have
+    // their name (and type) in the key.
+    version := userData.Version
+    err = UpgradeUserData(userData, r.keyName(data))
+
+    if err != nil && err != ErrNewerSchema {
+        return nil, err
+    }
+
+    // Since then, the payload carries the name and type of the entry,
+    // which are authoritative.
+    if userData.Name != "" {
+        (*data).Text = userData.Name
+    }
+
+    (*data).Type = userData.Type
 
     // ...generate a DecodedEntry struct...
-    decodedEntry := DecodedEntry{}
-    err = json.Unmarshal([]byte(decryptedData), &decodedEntry)
+    decodedEntry := DecodedEntry{
+        Type:     userData.Type,
+        Username: userData.Username,
+        Password: userData.Password,
+        File:     userData.File,
+        URLs:     userData.URLs,
+        Match:    userData.Match,
+        Notes:    userData.Notes,
+        Fields:   userData.Fields,
+        Tags:     userData.Tags,
+        Created:  userData.Created,
+        Modified: userData.Modified,
+        LastUsed: userData.LastUsed,
+        Version:  version,
+    }
 
-    if err != nil {
-        return nil, err
+    // ...decode the type specific payload...
+    if t, ok := LookupType(userData.Type); ok && t.Codec != nil {
+        decodedEntry.Payload, err = t.Codec.Decode(userData.Data)
+
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        decodedEntry.data = userData.Data
     }
 
     // ...with the proper information...
@@ -280,33 +610,97 @@ func (r *Client) VaultReadSecret(data *Name) (*DecodedEntry, error) {
 }
 
 func (r *Client) VaultWriteSecret(data *DecodedEntry) error {
-    var padding string
+    // New entries may still be created with a legacy type suffix.
+    if (*data).Type == "" {
+        title, id := SplitTypeSuffix((*data).Name.Text)
 
-    log.Println("WRITE", data)
+        if (*data).Name.Encrypted == "" {
+            (*data).Name.Text = title
+        }
+
+        (*data).Type = GetType(id).ID
+    }
+
+    (*data).Name.Type = (*data).Type
+
+    if err := (*data).Validate(); err != nil {
+        return err
+    }
 
-    // Get some padding data, so that, ciphertext length
-    // does not reveal information about password lenth.
-    contentLength := len(data.Username) + len(data.Username) + len(data.File)
+    // Keep track of when the entry was created and modified.
+    now := time.Now().UTC()
 
-    if contentLength < MinimumDataLength {
-        padding = string(lock.Entropy(MinimumDataLength - contentLength))
+    if (*data).Name.Encrypted == "" && (*data).Created.IsZero() {
+        (*data).Created = now
+    }
+
+    (*data).Modified = now
+
+    return r.writeSecret(data)
+}
+
+func (r *Client) writeSecret(data *DecodedEntry) error {
+    logger.Debug("WRITE", data)
+
+    // Writing back an entry from a newer client would drop whatever
+    // it stored that we do not know about.
+    if (*data).Version > SchemaVersion {
+        return ErrNewerSchema
+    }
+
+    // An empty password, rather than null, for older clients.
+    password := (*data).Password
+
+    if password == nil {
+        password = lock.NewSecureBuffer(0)
     }
 
     userData := &UserData{
+        Version:  SchemaVersion,
+        Name:     (*data).Name.Text,
+        Type:     (*data).Type,
+        Data:     (*data).data,
         Username: (*data).Username,
-        Password: (*data).Password,
+        Password: password,
         File:     (*data).File,
-        Padding:  padding,
+        URLs:     (*data).URLs,
+        Match:    (*data).Match,
+        Notes:    (*data).Notes,
+        Fields:   (*data).Fields,
+        Tags:     (*data).Tags,
+        Created:  (*data).Created,
+        Modified: (*data).Modified,
+        LastUsed: (*data).LastUsed,
+    }
+
+    var err error
+
+    if t, ok := LookupType((*data).Type); ok && t.Codec != nil {
+        userData.Data, err = t.Codec.Encode((*data).Payload)
+
+        if err != nil {
+            return err
+        }
     }
 
-    // Encode data as JSON...
-    jsonUserData, _ := json.Marshal(userData)
+    // Encode data as JSON, padded so that the ciphertext length
+    // does not reveal information about password or file length.
+    jsonUserData, err := r.Pad(userData)
 
-    // ...and encrypt.
-    encryptedUserData, _ := r.EncBase64(string(jsonUserData))
+    if err != nil {
+        return err
+    }
+
+    // ...and encrypt, overwriting the plaintext.
+    encryptedUserData, _ := r.Lock.EncryptBase64(jsonUserData)
+    lock.Zero(jsonUserData)
 
     if (*data).Name.Encrypted == "" {
-        (*data).Name.Encrypted, _ = r.EncHex((*data).Name.Text)
+        (*data).Name.Encrypted, err = r.StorageKey((*data).Name.Text, (*data).Type)
+
+        if err != nil {
+            return err
+        }
     }
 
     vaultRequestEncrypted := MyRequestEncrypted{
@@ -315,21 +709,23 @@ func (r *Client) VaultWriteSecret(data *DecodedEntry) error {
     jsonVaultRequestEncrypted, _ := json.Marshal(vaultRequestEncrypted)
 
     // Create the actual request.
-    _, err := r.Request(http.MethodPut, "/"+(*data).Name.Encrypted,
+    _, err = r.Request(http.MethodPut, "/"+(*data).Name.Encrypted,
         bytes.NewBuffer(jsonVaultRequestEncrypted))
 
     if err != nil {
         return err
     }
 
-    log.Println("OK")
+    (*data).Version = SchemaVersion
+    delete(r.notes, (*data).Name.Encrypted)
+    delete(r.targets, (*data).Name.Encrypted)
 
     return r.UpdateTag()
 }
 
 func (r *Client) VaultDeleteSecret(data *DecodedEntry) error {
     if (*data).Name.Encrypted == "" {
-        log.Fatal("No encrypted data stored")
+        return ErrNoStorageKey
     }
 
     _, err := r.Request(http.MethodDelete, "/"+(*data).Name.Encrypted, nil)
@@ -338,13 +734,16 @@ func (r *Client) VaultDeleteSecret(data *DecodedEntry) error {
         return err
     }
 
+    delete(r.notes, (*data).Name.Encrypted)
+    delete(r.targets, (*data).Name.Encrypted)
+
     return r.UpdateTag()
 }
 
 //VaultRenameSecret
 
 func (r *Client) VaultListSecrets() (*[]Name, error) {
-    log.Println("LIST")
+    logger.Debug("LIST")
 
     if r.IsTagUpdated() {
         // Do a LIST to get all entries.
@@ -361,19 +760,15 @@ func (r *Client) VaultListSecrets() (*[]Name, error) {
         r.SearchResult = make([]Name, 0)
 
         for _, key := range vaultResponse.Data.Keys {
-            decrypted, err := r.DecHex(key)
+            name, err := r.DecodeStorageKey(key)
 
             if err == nil {
-                r.SearchResult = append(r.SearchResult,
-                    Name{
-                        Text:      decrypted,
-                        Encrypted: key,
-                    })
+                r.SearchResult = append(r.SearchResult, name)
             }
         }
 
     } else {
-        log.Println("No tag change: using cached results")
+        logger.Debug("No tag change: using cached results")
     }
     return &r.SearchResult, nil
 }
@@ -16,22 +16,30 @@ const (
     port     = 8200
 )
 
-func TestListVaultSecrets(t *testing.T) {
+// These tests run against a live Vault instance, configured by the
+// constants above. Without a server they are skipped.
+func newTestClient(t *testing.T) Client {
+    if server == "" {
+        t.Skip("no Vault server configured")
+    }
+
     s, _ := hex.DecodeString(salt)
     mylock := lock.New(password, s)
     r := New(&mylock)
     r.Unlock(token)
     r.Init(server, port, CA)
+
+    return r
+}
+
+func TestListVaultSecrets(t *testing.T) {
+    r := newTestClient(t)
     m, _ := r.VaultListSecrets()
     fmt.Println(*m)
 }
 
 func TestListAndGetFirstSecret(t *testing.T) {
-    s, _ := hex.DecodeString(salt)
-    mylock := lock.New(password, s)
-    r := New(&mylock)
-    r.Unlock(token)
-    r.Init(server, port, CA)
+    r := newTestClient(t)
     m, _ := r.VaultListSecrets()
     if r.IsTagUpdated() {
         t.Errorf("Tag update not properly working...")
@@ -40,16 +48,12 @@ func TestListAndGetFirstSecret(t *testing.T) {
 }
 
 func TestListAndGetFirstSecretAndWriteItAgain(t *testing.T) {
-    s, _ := hex.DecodeString(salt)
-    mylock := lock.New(password, s)
-    r := New(&mylock)
-    r.Unlock(token)
-    r.Init(server, port, CA)
+    r := newTestClient(t)
     m, _ := r.VaultListSecrets()
-    f := r.VaultReadSecret(&(*m)[0])
+    f, _ := r.VaultReadSecret(&(*m)[0])
     (*f).Username = "zzz"
     r.VaultWriteSecret(f)
-    f = r.VaultReadSecret(&(*m)[0])
+    f, _ = r.VaultReadSecret(&(*m)[0])
     if (*f).Username != "zzz" {
         t.Errorf("Write error")
     }
@@ -58,10 +62,5 @@ func TestListAndGetFirstSecretAndWriteItAgain(t *testing.T) {
 }
 
 func CreateEmptySecretAndDeleteIt(t *testing.T) {
-    s, _ := hex.DecodeString(salt)
-    mylock := lock.New(password, s)
-    r := New(&mylock)
-    r.Unlock(token)
-    r.Init(server, port, CA)
-
+    newTestClient(t)
 }
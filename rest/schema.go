@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "errors"
+    "fmt"
+)
+
+// SchemaVersion is the version of the stored entry format written by
+// this client. Every change to UserData which needs a conversion of
+// existing entries bumps it and registers a migration.
+//
+//   0  unversioned entries: username, password, file and padding.
+//   1  the version and the entry name are stored in the payload.
+//   2  URLs, notes, custom fields, tags and timestamps. Clients of
+//      version 1 would drop these, so they must not write them back.
+//   3  the type is stored in the payload rather than as a suffix of
+//      the name, and signing keys moved from file to data.
+//   4  the match mode of the URLs.
+const SchemaVersion = 4
+
+// A Migration upgrades an entry from one schema version to the next.
+// It gets the (decrypted) name of the entry, which older entries only
+// carry in their storage key.
+type Migration func(userData *UserData, name string) error
+
+var (
+    ErrNewerSchema = errors.New("entry was written by a newer version of Pass")
+
+    migrations = map[int]Migration{
+        0: migrateNameIntoPayload,
+        1: migrateNothing,
+        2: migrateTypeIntoPayload,
+        3: migrateNothing,
+    }
+)
+
+// RegisterMigration adds the migration from version to version + 1.
+func RegisterMigration(version int, m Migration) {
+    migrations[version] = m
+}
+
+func migrateNameIntoPayload(userData *UserData, name string) error {
+    if userData.Name == "" {
+        userData.Name = name
+    }
+    return nil
+}
+
+// Used when fields were only added. The bump of the version keeps older
+// clients from overwriting them.
+func migrateNothing(userData *UserData, name string) error {
+    return nil
+}
+
+func migrateTypeIntoPayload(userData *UserData, name string) error {
+    // The key of an entry is authoritative for its type, since clients
+    // of earlier versions stored the name without suffix in the payload
+    // in some cases.
+    _, id := SplitTypeSuffix(name)
+    title, payloadID := SplitTypeSuffix(userData.Name)
+
+    if id == "" {
+        id = payloadID
+    }
+
+    if title == "" {
+        title, _ = SplitTypeSuffix(name)
+    }
+
+    userData.Name = title
+    userData.Type = GetType(id).ID
+
+    if userData.Type == TypeSign && len(userData.Data) == 0 && len(userData.File) > 0 {
+        userData.Data = userData.File
+        userData.File = nil
+    }
+
+    return nil
+}
+
+// UpgradeUserData runs all migrations needed to bring an entry up to
+// the current schema. Entries from a newer client are left as they are
+// and ErrNewerSchema is returned; they can be read, but must not be
+// written back, since we would lose whatever fields we do not know.
+func UpgradeUserData(userData *UserData, name string) error {
+    if userData.Version > SchemaVersion {
+        return ErrNewerSchema
+    }
+
+    for userData.Version < SchemaVersion {
+        m, ok := migrations[userData.Version]
+
+        if !ok {
+            return fmt.Errorf("no migration from schema version %d", userData.Version)
+        }
+
+        if err := m(userData, name); err != nil {
+            return err
+        }
+
+        userData.Version++
+    }
+
+    return nil
+}
+
+// MigrateSchema rewrites every entry stored with an older schema, so
+// that old entries need not be upgraded on every read. It returns the
+// number of entries rewritten.
+func (r *Client) MigrateSchema() (int, error) {
+    r.LocalUpdate = true
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        return 0, err
+    }
+
+    entries := append([]Name{}, (*names)...)
+    count := 0
+
+    for i := range entries {
+        entry, err := r.VaultReadSecret(&entries[i])
+
+        if err != nil {
+            return count, err
+        }
+
+        if entry.Version >= SchemaVersion {
+            continue
+        }
+
+        if err = r.rekeySecret(entry); err != nil {
+            return count, err
+        }
+
+        count++
+    }
+
+    return count, nil
+}
+
+// Writes back an upgraded entry, as it is, keeping its timestamps. If
+// the name in its key is no longer its name, i.e., the key still
+// carries a type suffix, the entry moves to a new key.
+func (r *Client) rekeySecret(entry *DecodedEntry) error {
+    oldKey := entry.Name.Encrypted
+    keyName, err := r.decName(oldKey)
+    entry.Name.Type = entry.Type
+
+    if err != nil || keyName == entry.Name.Text {
+        return r.writeSecret(entry)
+    }
+
+    entry.Name.Encrypted = ""
+
+    if err = r.moveSecret(entry, oldKey); err != nil {
+        entry.Name.Encrypted = oldKey
+        return err
+    }
+
+    return r.VaultDeleteSecret(&DecodedEntry{
+        Name: &Name{Encrypted: oldKey},
+    })
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+// Stores a raw, possibly outdated, payload the way an older (or newer)
+// client would have.
+func storeRaw(t *testing.T, r *Client, vault *fakeVault, name string, payload string) string {
+    key, _ := r.EncHex(name)
+    encrypted, err := r.EncBase64(payload)
+
+    if err != nil {
+        t.Fatalf("Encryption error: %s", err)
+    }
+
+    data, _ := json.Marshal(MyRequestEncrypted{Encrypted: encrypted})
+    vault.data[key] = data
+
+    return key
+}
+
+func TestUpgradeOnReadAndInBulk(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    key := storeRaw(t, r, vault, "github",
+        `{"username":"grocid","password":"banana","file":null,"padding":"xyz"}`)
+
+    entry, err := r.VaultReadSecret(&Name{Text: "github", Encrypted: key})
+
+    if err != nil || entry.Version != 0 || !entry.HasPassword("banana") {
+        t.Fatalf("Reading an unversioned entry failed: %v", err)
+    }
+
+    n, err := r.MigrateSchema()
+
+    if err != nil || n != 1 {
+        t.Fatalf("Migration failed, rewrote %d entries: %v", n, err)
+    }
+
+    userData, err := r.readUserData(key)
+
+    if err != nil || userData.Version != SchemaVersion || userData.Name != "github" {
+        t.Errorf("Entry was not upgraded: %v", err)
+    }
+
+    n, _ = r.MigrateSchema()
+
+    if n != 0 {
+        t.Errorf("Migration is not idempotent, rewrote %d entries", n)
+    }
+}
+
+func TestNewerSchemaIsReadOnly(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    key := storeRaw(t, r, vault, "github",
+        `{"version":999,"username":"grocid","password":"banana","future":true}`)
+
+    entry, err := r.VaultReadSecret(&Name{Text: "github", Encrypted: key})
+
+    if err != nil || entry.Username != "grocid" {
+        t.Fatalf("Could not read entry of a newer schema: %v", err)
+    }
+
+    if err = r.VaultWriteSecret(entry); err != ErrNewerSchema {
+        t.Errorf("Entry of a newer schema was written back")
+    }
+}
+
+func TestMigrationKeepsEntries(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    // A legacy entry with a URL which is no longer accepted.
+    key := storeRaw(t, r, vault, "ghcr",
+        `{"username":"grocid","password":"banana","file":null,"urls":["ghcr.io"],"modified":"2018-01-01T00:00:00Z"}`)
+
+    if n, err := r.MigrateSchema(); err != nil || n != 1 {
+        t.Fatalf("Migration failed, rewrote %d entries: %v", n, err)
+    }
+
+    userData, err := r.readUserData(key)
+
+    if err != nil || userData.Version != SchemaVersion || userData.Modified.Year() != 2018 {
+        t.Errorf("Entry marked as modified: %v", err)
+    }
+
+    // An entry which moves to a new key stays where it is if Vault
+    // refuses to store the copy.
+    key = storeRaw(t, r, vault, "google,1",
+        `{"username":"","password":"JBSWY3DPEHPK3PXP","file":null}`)
+    vault.readOnly = true
+
+    if _, err = r.MigrateSchema(); err != ErrMoveVerify {
+        t.Errorf("Refused write not noticed: %v", err)
+    }
+
+    if _, ok := vault.data[key]; !ok {
+        t.Errorf("Original removed after a refused write")
+    }
+}
@@ -0,0 +1,249 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/pem"
+    "errors"
+    "golang.org/x/crypto/ed25519"
+    "golang.org/x/crypto/ssh"
+    "strings"
+)
+
+const (
+    TypeSSH = "ssh"
+
+    // Supported algorithms for generated keys.
+    SSHKeyEd25519 = "ed25519"
+    SSHKeyECDSA   = "ecdsa"
+    SSHKeyRSA     = "rsa"
+
+    // Default sizes, in bits, of generated keys.
+    DefaultECDSABits = 256
+    DefaultRSABits   = 3072
+    MinimumRSABits   = 2048
+)
+
+var (
+    ErrSSHAlgorithm = errors.New("unsupported SSH key algorithm")
+    ErrSSHKeySize   = errors.New("unsupported SSH key size")
+    ErrSSHKey       = errors.New("not a valid OpenSSH private key")
+)
+
+// SSHKey is the payload of SSH key entries. The private key is kept in
+// the OpenSSH format, without a passphrase, since the entry itself is
+// encrypted.
+type SSHKey struct {
+    Private []byte `json:"private"`
+    Comment string `json:"comment,omitempty"`
+
+    // Ask before each use of the key by the agent.
+    Confirm bool `json:"confirm,omitempty"`
+}
+
+// GenerateSSHKey generates a new key with the given algorithm. The
+// size in bits only applies to ECDSA and RSA keys; zero selects the
+// default size.
+func GenerateSSHKey(algorithm string, bits int, comment string) (*SSHKey, error) {
+    var (
+        key crypto.PrivateKey
+        err error
+    )
+
+    switch algorithm {
+    case SSHKeyEd25519:
+        _, key, err = ed25519.GenerateKey(rand.Reader)
+    case SSHKeyECDSA:
+        var curve elliptic.Curve
+
+        switch bits {
+        case 0, 256:
+            curve = elliptic.P256()
+        case 384:
+            curve = elliptic.P384()
+        case 521:
+            curve = elliptic.P521()
+        default:
+            return nil, ErrSSHKeySize
+        }
+
+        key, err = ecdsa.GenerateKey(curve, rand.Reader)
+    case SSHKeyRSA:
+        if bits == 0 {
+            bits = DefaultRSABits
+        }
+
+        if bits < MinimumRSABits {
+            return nil, ErrSSHKeySize
+        }
+
+        key, err = rsa.GenerateKey(rand.Reader, bits)
+    default:
+        return nil, ErrSSHAlgorithm
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    return newSSHKey(key, comment)
+}
+
+// ImportSSHKey reads a private key in the OpenSSH format, or any other
+// PEM format the ssh package understands. If the key is protected, the
+// passphrase is needed; the key is stored without it.
+func ImportSSHKey(data []byte, passphrase []byte, comment string) (*SSHKey, error) {
+    var (
+        key interface{}
+        err error
+    )
+
+    if len(passphrase) > 0 {
+        key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+    } else {
+        key, err = ssh.ParseRawPrivateKey(data)
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    // Ed25519 keys are parsed into a pointer.
+    if k, ok := key.(*ed25519.PrivateKey); ok {
+        key = *k
+    }
+
+    return newSSHKey(key, comment)
+}
+
+func newSSHKey(key crypto.PrivateKey, comment string) (*SSHKey, error) {
+    block, err := ssh.MarshalPrivateKey(key, comment)
+
+    if err != nil {
+        return nil, err
+    }
+
+    return &SSHKey{
+        Private: pem.EncodeToMemory(block),
+        Comment: comment,
+    }, nil
+}
+
+// Key returns the private key, i.e., an *ecdsa.PrivateKey, an
+// *rsa.PrivateKey or an ed25519.PrivateKey.
+func (k *SSHKey) Key() (interface{}, error) {
+    key, err := ssh.ParseRawPrivateKey(k.Private)
+
+    if err != nil {
+        return nil, ErrSSHKey
+    }
+
+    if p, ok := key.(*ed25519.PrivateKey); ok {
+        key = *p
+    }
+
+    return key, nil
+}
+
+// Signer returns a signer for use with the ssh package.
+func (k *SSHKey) Signer() (ssh.Signer, error) {
+    key, err := k.Key()
+
+    if err != nil {
+        return nil, err
+    }
+
+    return ssh.NewSignerFromKey(key)
+}
+
+// PublicKey returns the public key as a line of authorized_keys.
+func (k *SSHKey) PublicKey() (string, error) {
+    signer, err := k.Signer()
+
+    if err != nil {
+        return "", err
+    }
+
+    line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+
+    if k.Comment != "" {
+        line += " " + k.Comment
+    }
+
+    return line, nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of the public key, as
+// shown by ssh-keygen -l.
+func (k *SSHKey) Fingerprint() (string, error) {
+    signer, err := k.Signer()
+
+    if err != nil {
+        return "", err
+    }
+
+    return ssh.FingerprintSHA256(signer.PublicKey()), nil
+}
+
+func validateSSH(d *DecodedEntry) error {
+    // An SSH key entry without a key has not been generated yet.
+    key, ok := d.Payload.(*SSHKey)
+
+    if d.Payload == nil || (ok && len(key.Private) == 0) {
+        return nil
+    }
+
+    if !ok {
+        return ErrSSHKey
+    }
+
+    _, err := key.Key()
+    return err
+}
+
+func init() {
+    RegisterType(&EntryType{
+        ID:     TypeSSH,
+        Label:  SSHLabel,
+        Icon:   "sign",
+        View:   "SSH",
+        Suffix: SSHSuffix,
+        Codec: JSONCodec{
+            New: func() interface{} { return &SSHKey{} },
+        },
+        Validate: validateSSH,
+    })
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+    "crypto/rand"
+    "encoding/pem"
+    "golang.org/x/crypto/ed25519"
+    "golang.org/x/crypto/ssh"
+    "strings"
+    "testing"
+)
+
+func TestGenerateSSHKey(t *testing.T) {
+    for _, algorithm := range []string{SSHKeyEd25519, SSHKeyECDSA, SSHKeyRSA} {
+        key, err := GenerateSSHKey(algorithm, 0, "me@host")
+
+        if err != nil {
+            t.Fatalf("Could not generate %s key: %s", algorithm, err)
+        }
+
+        signer, err := key.Signer()
+
+        if err != nil {
+            t.Fatalf("Could not read %s key: %s", algorithm, err)
+        }
+
+        line, _ := key.PublicKey()
+
+        if !strings.HasPrefix(line, signer.PublicKey().Type()+" ") ||
+            !strings.HasSuffix(line, " me@host") {
+            t.Errorf("Unexpected public key: %s", line)
+        }
+
+        fingerprint, _ := key.Fingerprint()
+
+        if fingerprint != ssh.FingerprintSHA256(signer.PublicKey()) {
+            t.Errorf("Unexpected fingerprint: %s", fingerprint)
+        }
+    }
+
+    if _, err := GenerateSSHKey(SSHKeyRSA, 1024, ""); err != ErrSSHKeySize {
+        t.Errorf("Short RSA key was generated")
+    }
+
+    if _, err := GenerateSSHKey("dsa", 0, ""); err != ErrSSHAlgorithm {
+        t.Errorf("Unsupported algorithm was accepted")
+    }
+}
+
+func TestImportSSHKey(t *testing.T) {
+    _, priv, _ := ed25519.GenerateKey(rand.Reader)
+    block, _ := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+    data := pem.EncodeToMemory(block)
+
+    if _, err := ImportSSHKey(data, nil, ""); err == nil {
+        t.Errorf("Protected key was imported without passphrase")
+    }
+
+    key, err := ImportSSHKey(data, []byte("secret"), "imported")
+
+    if err != nil {
+        t.Fatalf("Could not import key: %s", err)
+    }
+
+    // The key is stored without the passphrase.
+    signer, err := key.Signer()
+
+    if err != nil {
+        t.Fatalf("Imported key cannot be read: %s", err)
+    }
+
+    expected, _ := ssh.NewSignerFromKey(priv)
+
+    if string(signer.PublicKey().Marshal()) != string(expected.PublicKey().Marshal()) {
+        t.Errorf("Imported key differs")
+    }
+}
+
+func TestSSHKeyRoundTrip(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    key, _ := GenerateSSHKey(SSHKeyEd25519, 0, "")
+    key.Confirm = true
+
+    entry := DecodedEntry{Name: &Name{Text: "server,ssh"}, Payload: key}
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    read, err := r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if err != nil || read.Type != TypeSSH || read.Name.Text != "server" {
+        t.Fatalf("Read error: %v", err)
+    }
+
+    k, ok := read.Payload.(*SSHKey)
+
+    if !ok || string(k.Private) != string(key.Private) || !k.Confirm {
+        t.Errorf("Key did not survive a round trip")
+    }
+
+    invalid := DecodedEntry{
+        Name:    &Name{Text: "broken,ssh"},
+        Payload: &SSHKey{Private: []byte("not a key")},
+    }
+
+    if err := r.VaultWriteSecret(&invalid); err != ErrSSHKey {
+        t.Errorf("Invalid key was written")
+    }
+}
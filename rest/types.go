@@ -0,0 +1,252 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "encoding/base32"
+    "encoding/json"
+    "errors"
+    "golang.org/x/crypto/ed25519"
+    "pass/lock"
+    "sort"
+)
+
+// Identifiers of the built-in entry types, as stored in the payload.
+const (
+    TypeAccount = "account"
+    TypeOTP     = "otp"
+    TypeFile    = "file"
+    TypeSign    = "sign"
+
+    // Due to limitations in Vault.
+    MaximumFileSize = 512 * 1024
+)
+
+var (
+    ErrUnknownType = errors.New("unknown entry type")
+    ErrOTPSecret   = errors.New("OTP secret is not valid base32")
+    ErrFileSize    = errors.New("file is too large")
+    ErrKeyPair     = errors.New("not a valid Ed25519 key pair")
+)
+
+// A Codec converts the type specific payload of an entry to and from
+// its stored form. The payload is encrypted along with the rest of the
+// entry.
+type Codec interface {
+    Encode(payload interface{}) (json.RawMessage, error)
+    Decode(data json.RawMessage) (interface{}, error)
+}
+
+// JSONCodec stores a payload as JSON. New returns a pointer to a new,
+// empty payload to decode into.
+type JSONCodec struct {
+    New func() interface{}
+}
+
+func (c JSONCodec) Encode(payload interface{}) (json.RawMessage, error) {
+    if payload == nil {
+        return nil, nil
+    }
+    return json.Marshal(payload)
+}
+
+func (c JSONCodec) Decode(data json.RawMessage) (interface{}, error) {
+    payload := c.New()
+
+    if len(data) == 0 {
+        return payload, nil
+    }
+
+    err := json.Unmarshal(data, payload)
+    return payload, err
+}
+
+// An EntryType describes a kind of entry. Adding a new kind of entry
+// amounts to registering a type and, for the desktop app, a component
+// named after View.
+type EntryType struct {
+    // Identifier stored in the payload.
+    ID string
+
+    // Shown in search results.
+    Label string
+
+    // Name of the icon. If empty, the icon is named after the entry,
+    // as for accounts.
+    Icon string
+
+    // Name of the component displaying the entry.
+    View string
+
+    // Suffix of the names of entries of this type, as stored by
+    // versions of Pass before types were stored in the payload. It
+    // is also part of the blind index, so every type needs one.
+    Suffix string
+
+    // Converts the type specific payload, if any.
+    Codec Codec
+
+    // Checks an entry before it is written, if set.
+    Validate func(d *DecodedEntry) error
+}
+
+var types = map[string]*EntryType{}
+
+// RegisterType adds a type to the registry, replacing any type with
+// the same identifier.
+func RegisterType(t *EntryType) {
+    types[t.ID] = t
+}
+
+// LookupType returns the type with the given identifier. Entries
+// without a type are accounts.
+func LookupType(id string) (*EntryType, bool) {
+    if id == "" {
+        id = TypeAccount
+    }
+
+    t, ok := types[id]
+    return t, ok
+}
+
+// GetType is like LookupType, but falls back to accounts, so that an
+// entry of a type we do not know can at least be shown.
+func GetType(id string) *EntryType {
+    if t, ok := LookupType(id); ok {
+        return t
+    }
+
+    return types[TypeAccount]
+}
+
+// Types returns all registered types, ordered by label.
+func Types() []*EntryType {
+    list := make([]*EntryType, 0, len(types))
+
+    for _, t := range types {
+        list = append(list, t)
+    }
+
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Label < list[j].Label
+    })
+
+    return list
+}
+
+// IconName returns the name of the icon for an entry of the type.
+func (t *EntryType) IconName(title string) string {
+    if t.Icon == "" {
+        _, base := SplitPath(title)
+        return base
+    }
+
+    return t.Icon
+}
+
+// KeyPair is the payload of signing entries.
+type KeyPair struct {
+    Priv []byte `json:"priv"`
+    Pub  []byte `json:"pub"`
+}
+
+func validateOTP(d *DecodedEntry) error {
+    secret := d.Password.Bytes()
+    key := make([]byte, base32.StdEncoding.DecodedLen(len(secret)))
+    defer lock.Zero(key)
+
+    if _, err := base32.StdEncoding.Decode(key, secret); err != nil {
+        return ErrOTPSecret
+    }
+    return nil
+}
+
+func validateFile(d *DecodedEntry) error {
+    if len(d.File) > MaximumFileSize {
+        return ErrFileSize
+    }
+    return nil
+}
+
+func validateSign(d *DecodedEntry) error {
+    // A signing entry without keys has not been generated yet.
+    keyPair, ok := d.Payload.(*KeyPair)
+
+    if d.Payload == nil || (ok && len(keyPair.Priv) == 0) {
+        return nil
+    }
+
+    if !ok || len(keyPair.Priv) != ed25519.PrivateKeySize ||
+        len(keyPair.Pub) != ed25519.PublicKeySize {
+        return ErrKeyPair
+    }
+
+    return nil
+}
+
+func init() {
+    RegisterType(&EntryType{
+        ID:     TypeAccount,
+        Label:  AccountLabel,
+        View:   "Account",
+        Suffix: UserCredentialsSuffix,
+    })
+
+    RegisterType(&EntryType{
+        ID:       TypeOTP,
+        Label:    OTPLabel,
+        Icon:     "otp",
+        View:     "OTP",
+        Suffix:   OTPSuffix,
+        Validate: validateOTP,
+    })
+
+    RegisterType(&EntryType{
+        ID:       TypeFile,
+        Label:    FileLabel,
+        Icon:     "file",
+        View:     "File",
+        Suffix:   FileSuffix,
+        Validate: validateFile,
+    })
+
+    RegisterType(&EntryType{
+        ID:     TypeSign,
+        Label:  SignLabel,
+        Icon:   "sign",
+        View:   "Sign",
+        Suffix: SignSuffix,
+        Codec: JSONCodec{
+            New: func() interface{} { return &KeyPair{} },
+        },
+        Validate: validateSign,
+    })
+}
@@ -0,0 +1,163 @@
+package rest
+
+import (
+    "encoding/json"
+    "golang.org/x/crypto/ed25519"
+    "pass/lock"
+    "testing"
+)
+
+func TestLegacySuffixesAreMigrated(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    pub, priv, _ := ed25519.GenerateKey(nil)
+    keyPair, _ := json.Marshal(&KeyPair{Priv: priv, Pub: pub})
+    file, _ := json.Marshal(keyPair)
+
+    storeRaw(t, r, vault, "github", `{"username":"grocid","password":"banana"}`)
+    storeRaw(t, r, vault, "github,1", `{"password":"JBSWY3DPEHPK3PXP"}`)
+    storeRaw(t, r, vault, "release,3", `{"file":`+string(file)+`}`)
+
+    names, err := r.VaultListSecrets()
+
+    if err != nil || len(*names) != 3 {
+        t.Fatalf("Listing failed: %v", err)
+    }
+
+    found := map[string]string{}
+
+    for _, name := range *names {
+        found[name.Text+"/"+name.Type] = name.Encrypted
+    }
+
+    for _, expected := range []string{"github/account", "github/otp", "release/sign"} {
+        if _, ok := found[expected]; !ok {
+            t.Errorf("Missing %s in %v", expected, found)
+        }
+    }
+
+    if n, err := r.MigrateSchema(); err != nil || n != 3 {
+        t.Fatalf("Migration failed, rewrote %d entries: %v", n, err)
+    }
+
+    // The suffixes are gone from the keys, yet the types are kept.
+    r.names = make(map[string]Name)
+    names, _ = r.VaultListSecrets()
+
+    for _, name := range *names {
+        text, _ := r.decName(name.Encrypted)
+
+        if text != name.Text {
+            t.Errorf("Key still carries a suffix: %s", text)
+        }
+
+        entry, err := r.VaultReadSecret(&name)
+
+        if err != nil || entry.Version != SchemaVersion {
+            t.Fatalf("Could not read migrated entry: %v", err)
+        }
+
+        if entry.Type == TypeSign {
+            k, ok := entry.Payload.(*KeyPair)
+
+            if !ok || len(entry.File) != 0 || string(k.Pub) != string(pub) {
+                t.Errorf("Key pair was not moved to the payload")
+            }
+        }
+    }
+
+    if len(vault.data) != 4 {
+        t.Errorf("Old keys were not removed, %d keys", len(vault.data))
+    }
+}
+
+func TestSameNameDifferentTypes(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    r.BlindIndex = true
+
+    account := DecodedEntry{Name: &Name{Text: "github"}, Password: lock.SecureCopy([]byte("banana"))}
+    otp := DecodedEntry{Name: &Name{Text: "github,1"}, Password: lock.SecureCopy([]byte("JBSWY3DPEHPK3PXP"))}
+
+    if err := r.VaultWriteSecret(&account); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    if err := r.VaultWriteSecret(&otp); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    if otp.Type != TypeOTP || otp.Name.Text != "github" {
+        t.Errorf("Type was not taken from the suffix: %s %s", otp.Type, otp.Name.Text)
+    }
+
+    a, err := r.VaultLookupSecret("github", TypeAccount)
+    o, err2 := r.VaultLookupSecret("github", TypeOTP)
+
+    if err != nil || err2 != nil || !a.HasPassword("banana") || o.Type != TypeOTP {
+        t.Errorf("Entries with the same name overwrote each other")
+    }
+
+    invalid := DecodedEntry{Name: &Name{Text: "bad,1"}, Password: lock.SecureCopy([]byte("not base32!"))}
+
+    if err := r.VaultWriteSecret(&invalid); err != ErrOTPSecret {
+        t.Errorf("Invalid OTP secret was written")
+    }
+}
+
+type note struct {
+    Text string `json:"text"`
+}
+
+func TestRegisteredTypeWithCodec(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    RegisterType(&EntryType{
+        ID:     "test",
+        Label:  "Test",
+        Suffix: ",test",
+        Codec: JSONCodec{
+            New: func() interface{} { return &note{} },
+        },
+    })
+    defer delete(types, "test")
+
+    entry := DecodedEntry{
+        Name:    &Name{Text: "hello"},
+        Type:    "test",
+        Payload: &note{Text: "world"},
+    }
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    read, err := r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if err != nil || read.Type != "test" || read.Payload.(*note).Text != "world" {
+        t.Errorf("Payload did not survive a round trip: %v", err)
+    }
+
+    // A client which does not know the type keeps the payload.
+    delete(types, "test")
+    read, _ = r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+    read.Username = "changed"
+    r.VaultWriteSecret(read)
+
+    RegisterType(&EntryType{
+        ID:    "test",
+        Label: "Test",
+        Codec: JSONCodec{
+            New: func() interface{} { return &note{} },
+        },
+    })
+
+    read, _ = r.VaultReadSecret(&Name{Encrypted: entry.Name.Encrypted})
+
+    if read.Payload.(*note).Text != "world" {
+        t.Errorf("Payload of an unknown type was lost")
+    }
+}
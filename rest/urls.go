@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package rest
+
+import (
+    "pass/urlmatch"
+)
+
+// Target is what urlmatch compares of an entry.
+func (d *DecodedEntry) Target() urlmatch.Target {
+    return urlmatch.Target{URLs: d.URLs, Mode: d.Match}
+}
+
+// RankByURL returns the entries matching the URL of a page, best first.
+func RankByURL(entries []*DecodedEntry, page string, m *urlmatch.Matcher) ([]*DecodedEntry, error) {
+    targets := make([]urlmatch.Target, len(entries))
+
+    for i, d := range entries {
+        targets[i] = d.Target()
+    }
+
+    candidates, err := m.Rank(page, targets)
+
+    if err != nil {
+        return nil, err
+    }
+
+    ranked := make([]*DecodedEntry, len(candidates))
+
+    for i, c := range candidates {
+        ranked[i] = entries[c.Index]
+    }
+
+    return ranked, nil
+}
+
+// SearchURLs matches the URLs of the given entries against the URL of a
+// page and returns the scores of those that match by their keys. Like
+// notes, the URLs are only stored encrypted; they are cached until the
+// entry is changed, so that only the first search reads all entries.
+func (r *Client) SearchURLs(names []Name, page string, m *urlmatch.Matcher) (map[string]int, error) {
+    scores := map[string]int{}
+    u, err := urlmatch.Parse(page)
+
+    if err != nil {
+        return scores, nil
+    }
+
+    if r.targets == nil {
+        r.targets = make(map[string]urlmatch.Target)
+    }
+
+    for i := range names {
+        key := names[i].Encrypted
+        target, ok := r.targets[key]
+
+        if !ok {
+            entry, err := r.VaultReadSecret(&names[i])
+
+            if err != nil {
+                return scores, err
+            }
+
+            target = entry.Target()
+            r.targets[key] = target
+        }
+
+        if score, _ := m.Match(target, u); score != urlmatch.ScoreNone {
+            scores[key] = score
+        }
+    }
+
+    return scores, nil
+}
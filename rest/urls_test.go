@@ -0,0 +1,90 @@
+package rest
+
+import (
+    "pass/urlmatch"
+    "testing"
+)
+
+func TestValidateMatchMode(t *testing.T) {
+    d := &DecodedEntry{Name: &Name{Text: "a"}, URLs: []string{`^https://(www\.)?example\.com/`}}
+
+    if d.Validate() != ErrURL {
+        t.Errorf("Pattern accepted as URL")
+    }
+
+    d.Match = urlmatch.ModeRegex
+
+    if err := d.Validate(); err != nil {
+        t.Errorf("Pattern not accepted: %s", err)
+    }
+
+    d.URLs = []string{"(unclosed"}
+
+    if d.Validate() == nil {
+        t.Errorf("Invalid pattern accepted")
+    }
+
+    d.Match = "fuzzy"
+    d.URLs = nil
+
+    if d.Validate() != urlmatch.ErrMode {
+        t.Errorf("Unknown match mode accepted")
+    }
+}
+
+func TestRankByURL(t *testing.T) {
+    m := urlmatch.NewMatcher(urlmatch.DefaultEquivalents)
+    entries := []*DecodedEntry{
+        {Name: &Name{Text: "youtube"}, URLs: []string{"https://youtube.com"}},
+        {Name: &Name{Text: "google"}, URLs: []string{"https://google.com"}},
+        {Name: &Name{Text: "strict"}, URLs: []string{"https://mail.google.com"}, Match: urlmatch.ModeHost},
+        {Name: &Name{Text: "other"}, URLs: []string{"https://example.com"}},
+    }
+
+    ranked, err := RankByURL(entries, "https://accounts.google.com/signin", m)
+
+    if err != nil || len(ranked) != 2 || ranked[0].Name.Text != "google" || ranked[1].Name.Text != "youtube" {
+        t.Errorf("Unexpected ranking %v: %v", ranked, err)
+    }
+}
+
+func TestSearchURLs(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    m := urlmatch.NewMatcher(nil)
+    entries := []DecodedEntry{
+        {Name: &Name{Text: "github"}, URLs: []string{"https://github.com/login"}},
+        {Name: &Name{Text: "gitlab"}, URLs: []string{"https://gitlab.com"}},
+    }
+
+    for i := range entries {
+        if err := r.VaultWriteSecret(&entries[i]); err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+
+    names, _ := r.VaultListSecrets()
+    scores, err := r.SearchURLs(*names, "github.com/login", m)
+
+    if err != nil || len(scores) != 1 || scores[entries[0].Name.Encrypted] != urlmatch.ScoreExact {
+        t.Fatalf("Unexpected scores %v: %v", scores, err)
+    }
+
+    // The URLs are cached, so searching again reads nothing...
+    reads := vault.reads
+    r.SearchURLs(*names, "https://gitlab.com", m)
+
+    if vault.reads != reads {
+        t.Errorf("URLs were read again")
+    }
+
+    // ...until they change.
+    entries[1].URLs = []string{"https://gitlab.example.com"}
+    r.VaultWriteSecret(&entries[1])
+    scores, _ = r.SearchURLs(*names, "https://gitlab.com", m)
+
+    if len(scores) != 0 {
+        t.Errorf("Changed URLs were not searched: %v", scores)
+    }
+}
@@ -0,0 +1,400 @@
+package rest
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "pass/lock"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// fakeVault is a minimal in-memory stand-in for the key/value backend
+// of Vault, supporting GET, PUT, DELETE and LIST on a flat mount.
+type fakeVault struct {
+    mutex sync.Mutex
+    data  map[string]json.RawMessage
+
+    // Number of entries read, not counting the tag.
+    reads int
+
+    // The token of the last request.
+    token string
+
+    // Refuse writes, like a Vault whose policy forbids them.
+    readOnly bool
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+
+    key := strings.TrimPrefix(req.URL.Path, "/")
+    v.token = req.Header.Get(VaultTokenHeader)
+
+    switch req.Method {
+    case MethodList:
+        keys := []string{}
+
+        for k := range v.data {
+            keys = append(keys, k)
+        }
+
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "data": map[string]interface{}{"keys": keys},
+        })
+
+    case http.MethodGet:
+        data, ok := v.data[key]
+
+        if key != "updated" {
+            v.reads++
+        }
+
+        if !ok {
+            w.WriteHeader(http.StatusNotFound)
+            w.Write([]byte(`{"errors":[]}`))
+            return
+        }
+
+        w.Write([]byte(`{"data":` + string(data) + `}`))
+
+    case http.MethodPut:
+        if v.readOnly {
+            w.WriteHeader(http.StatusForbidden)
+            w.Write([]byte(`{"errors":["permission denied"]}`))
+            return
+        }
+
+        body, _ := ioutil.ReadAll(req.Body)
+        v.data[key] = body
+        w.WriteHeader(http.StatusNoContent)
+
+    case http.MethodDelete:
+        delete(v.data, key)
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+func newFakeClient(t *testing.T) (*Client, *fakeVault, func()) {
+    vault := &fakeVault{data: make(map[string]json.RawMessage)}
+    server := httptest.NewServer(vault)
+
+    mylock := lock.Lock{Key: lock.SecureCopy(lock.Entropy(32))}
+    r := New(&mylock)
+    r.EntryPoint = server.URL
+    r.Client = server.Client()
+
+    return &r, vault, server.Close
+}
+
+func TestBlindIndexMigrationAndLookup(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    // Store a legacy entry under an encrypted name.
+    err := r.VaultWriteSecret(&DecodedEntry{
+        Name:     &Name{Text: "github"},
+        Username: "grocid",
+        Password: lock.SecureCopy([]byte("banana")),
+    })
+
+    if err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    if _, err := r.VaultLookupSecret("github", TypeAccount); err == nil {
+        t.Errorf("Lookup of a legacy entry should fail")
+    }
+
+    r.BlindIndex = true
+    n, err := r.MigrateBlindIndex()
+
+    if err != nil || n != 1 {
+        t.Fatalf("Migration failed, moved %d entries: %v", n, err)
+    }
+
+    if _, ok := vault.data[r.Lock.BlindIndex("github")]; !ok {
+        t.Errorf("Entry was not stored under its blind index")
+    }
+
+    entry, err := r.VaultLookupSecret("github", TypeAccount)
+
+    if err != nil || !entry.HasPassword("banana") || entry.Name.Text != "github" {
+        t.Errorf("Lookup failed: %v", err)
+    }
+
+    // The name is recovered from the payload when listing.
+    r.names = make(map[string]Name)
+    names, err := r.VaultListSecrets()
+
+    if err != nil || len(*names) != 1 || (*names)[0].Text != "github" {
+        t.Errorf("Listing blind entries failed: %v", err)
+    }
+}
+
+func TestBlindIndexMigrationRefused(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    entry := DecodedEntry{Name: &Name{Text: "github"}, Password: lock.SecureCopy([]byte("banana"))}
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    // Vault answers, but does not store the entry under its index.
+    vault.readOnly = true
+    r.BlindIndex = true
+
+    if n, err := r.MigrateBlindIndex(); err != ErrMoveVerify || n != 0 {
+        t.Errorf("Refused write not noticed, moved %d entries: %v", n, err)
+    }
+
+    if _, ok := vault.data[entry.Name.Encrypted]; !ok || len(vault.data) != 2 {
+        t.Errorf("Original removed after a refused write")
+    }
+}
+
+func TestFindSecret(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    entry := DecodedEntry{Name: &Name{Text: "Work/github"}, Password: lock.SecureCopy([]byte("banana"))}
+
+    if err := r.VaultWriteSecret(&entry); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    // Without a blind index, the entry is found by listing.
+    for _, blind := range []bool{false, true} {
+        r.BlindIndex = blind
+        found, err := r.VaultFindSecret("Work/github", "")
+
+        if err != nil || !found.HasPassword("banana") {
+            t.Errorf("Entry not found: %v", err)
+        }
+
+        if _, err = r.VaultFindSecret("Work/github", TypeOTP); err != ErrNotFound {
+            t.Errorf("Entry of another type was found: %v", err)
+        }
+    }
+}
+
+func TestWipe(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    note := DecodedEntry{Name: &Name{Text: "shopping"}, Type: TypeNote, Payload: &Note{Text: "milk"}}
+
+    if err := r.VaultWriteSecret(&note); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    names, err := r.VaultListSecrets()
+
+    if err != nil {
+        t.Fatalf("List error: %s", err)
+    }
+
+    if _, err = r.SearchNotes(*names, "milk"); err != nil || len(r.notes) != 1 {
+        t.Fatalf("Note not cached: %v", err)
+    }
+
+    name := (*names)[0]
+    key := r.Lock.Key.Bytes()
+    r.DecryptedToken = lock.SecureCopy([]byte("s.token"))
+    token := r.DecryptedToken.Bytes()
+    r.Wipe()
+
+    for _, b := range append(key, token...) {
+        if b != 0 {
+            t.Fatalf("Key or token not overwritten")
+        }
+    }
+
+    if !r.Lock.Key.Wiped() || r.DecryptedToken != nil || r.SearchResult != nil ||
+        len(r.notes) != 0 || len(r.names) != 0 || len(r.targets) != 0 {
+        t.Errorf("Client not wiped")
+    }
+
+    // Nothing is read from the cache, or from Vault, afterwards.
+    if _, err = r.VaultReadSecret(&name); err == nil {
+        t.Errorf("Read after wiping")
+    }
+}
+
+func TestTokenHeader(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    for _, token := range []string{"s.token", "s.token", "s.other"} {
+        if token != vault.token {
+            r.DecryptedToken = lock.SecureCopy([]byte(token))
+        }
+
+        if _, err := r.VaultListSecrets(); err != nil || vault.token != token {
+            t.Errorf("Sent token %q instead of %q: %v", vault.token, token, err)
+        }
+    }
+
+    r.Wipe()
+
+    if r.tokenHeader != "" || r.tokenFor != nil {
+        t.Errorf("Token header kept after wiping")
+    }
+}
+
+func TestClone(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    r.DecryptedToken = lock.SecureCopy([]byte("s.token"))
+    note := DecodedEntry{Name: &Name{Text: "shopping"}, Type: TypeNote, Payload: &Note{Text: "milk"}}
+
+    if err := r.VaultWriteSecret(&note); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    c := r.Clone()
+    names, err := c.VaultListSecrets()
+
+    if err != nil || len(*names) != 1 {
+        t.Fatalf("Clone cannot list: %v", err)
+    }
+
+    if _, err = c.SearchNotes(*names, "milk"); err != nil || len(c.notes) != 1 || len(r.notes) != 0 {
+        t.Errorf("Caches are shared: %v", err)
+    }
+
+    // Wiping one leaves the other usable.
+    r.Wipe()
+
+    if c.Lock.Key.Wiped() || !c.DecryptedToken.Equal([]byte("s.token")) {
+        t.Errorf("Clone wiped with the original")
+    }
+
+    if _, err = c.VaultReadSecret(&(*names)[0]); err != nil {
+        t.Errorf("Clone cannot read: %v", err)
+    }
+
+    c.Invalidate()
+
+    if len(c.notes) != 0 || c.CachedTag != "-" {
+        t.Errorf("Caches not forgotten")
+    }
+}
+
+func TestCancel(t *testing.T) {
+    r, _, done := newFakeClient(t)
+    defer done()
+
+    c := r.Clone()
+    c.Cancel()
+
+    if _, err := c.VaultListSecrets(); err != ErrCanceled {
+        t.Errorf("Canceled client still works: %v", err)
+    }
+
+    if _, err := r.VaultListSecrets(); err != nil {
+        t.Errorf("Original canceled with the clone: %v", err)
+    }
+}
+
+func TestBlindIndexMigrationResume(t *testing.T) {
+    r, vault, done := newFakeClient(t)
+    defer done()
+
+    for _, password := range []string{"banana", "apple", "cherry"} {
+        name := "github"
+
+        if password == "cherry" {
+            name = "gitlab"
+        }
+
+        err := r.VaultWriteSecret(&DecodedEntry{Name: &Name{Text: name}, Password: lock.SecureCopy([]byte(password))})
+
+        if err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+
+    // Interrupted after writing gitlab to its index.
+    r.BlindIndex = true
+    gitlab, err := r.VaultFindSecret("gitlab", "")
+
+    if err != nil {
+        t.Fatalf("Find error: %s", err)
+    }
+
+    gitlab.Name = &Name{Text: "gitlab", Encrypted: r.Lock.BlindIndex(IndexName("gitlab", TypeAccount))}
+
+    if err = r.VaultWriteSecret(gitlab); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    n, err := r.MigrateBlindIndex()
+
+    if err != nil || n != 2 {
+        t.Fatalf("Migration moved %d entries: %v", n, err)
+    }
+
+    // The two github entries are real duplicates; one stays behind.
+    if len(vault.data) != 4 {
+        t.Errorf("Expected three entries and the tag, got %d", len(vault.data))
+    }
+
+    r.names = make(map[string]Name)
+    names, err := r.VaultListSecrets()
+
+    if err != nil || len(*names) != 3 {
+        t.Fatalf("Listing failed: %v", err)
+    }
+
+    if n, err = r.MigrateBlindIndex(); err != nil || n != 0 {
+        t.Errorf("Second migration moved %d entries: %v", n, err)
+    }
+
+    if len(vault.data) != 4 {
+        t.Errorf("Duplicate removed: %d", len(vault.data))
+    }
+}
+
+func TestSameEntry(t *testing.T) {
+    entry := func() *DecodedEntry {
+        return &DecodedEntry{
+            Name:     &Name{Text: "github"},
+            Type:     TypeSSH,
+            Username: "carl",
+            Password: lock.SecureCopy([]byte("banana")),
+            URLs:     []string{"https://github.com"},
+            Fields:   []Field{{Name: "pin", Value: "1234"}},
+            Payload:  &SSHKey{Comment: "carl@laptop"},
+        }
+    }
+
+    // Each copy holds its password in a buffer of its own.
+    a, b := entry(), entry()
+    b.Name = &Name{Text: "github", Encrypted: "index"}
+    b.Modified = time.Now()
+
+    if !sameEntry(a, b) {
+        t.Errorf("Copies of an entry differ")
+    }
+
+    b.SetPassword("apple")
+
+    if sameEntry(a, b) {
+        t.Errorf("Entries with other passwords are the same")
+    }
+
+    b = entry()
+    b.Payload = &SSHKey{Comment: "carl@desktop"}
+
+    if sameEntry(a, b) {
+        t.Errorf("Entries with other payloads are the same")
+    }
+}
@@ -32,15 +32,48 @@ package main
 
 import (
     "fmt"
-    "log"
     "github.com/murlokswarm/app"
+    "html"
+    "net/url"
+    "pass/logger"
     "pass/rest"
+    "pass/urlmatch"
+    "sort"
     "strings"
 )
 
 type Search struct {
     Query  string
+    Folder string
     Result []rest.Name
+
+    // Keys of the notes whose text matches the query.
+    Notes map[string]bool
+
+    // Scores of the entries whose URLs match the query, if it is a URL.
+    URLs map[string]int
+}
+
+// The folder last browsed to, so that we return to it from the other
+// views.
+var currentFolder string
+
+const (
+    FolderLabel = "Folder"
+    FolderImage = "folder"
+)
+
+func folderItem(folder string, caption string) string {
+    return fmt.Sprintf(`
+                    <a href="Search?Folder=%s">
+                        <li>
+                            <img src="iconpack/%s.png"/>
+                            <div class="SearchListItemCaption">%s</div>
+                            <div class="SearchListItemLabel">%s</div>
+                        </li>
+                    </a>`,
+        url.QueryEscape(folder), GetImageName(FolderImage),
+        html.EscapeString(caption), FolderLabel)
 }
 
 func (h *Search) Render() string {
@@ -50,7 +83,7 @@ func (h *Search) Render() string {
     <div class="SearchLayout">
         <input type="text"
                value="{{html .Query}}"
-               placeholder="Account"
+               placeholder="{{if .Folder}}{{html .Folder}}{{else}}Account{{end}}"
                onchange="DoSearchQuery"
                autofocus="true"
                autocomplete="off"
@@ -64,22 +97,50 @@ func (h *Search) Render() string {
             <div class="animated">
                 <ul>`
 
+    // Without a query, we browse the folder tree: first a way up,
+    // then the subfolders and last the entries of the folder. A query
+    // searches all folders.
+    if h.Query == "" {
+        if h.Folder != "" {
+            filteredNameList = filteredNameList + folderItem(
+                rest.ParentFolder(h.Folder), "..")
+        }
+
+        for _, folder := range rest.Folders(h.Result, h.Folder) {
+            _, caption := rest.SplitPath(folder)
+            filteredNameList = filteredNameList + folderItem(folder, caption)
+        }
+    }
+
     // Since we need to concatenate the results to a string, it is
     // cheapest (both in terms of memory and computations) to perform
     // filtering at this stage, rather than earlier filtering of the list.
     for _, name := range h.Result {
-        // Due to optimization, we have encoded some data in the name.
-        // We extract this data.
-        title, label, image := rest.DecodeName(name.Text)
+        // The type of the entry decides its label, icon and the
+        // component showing it.
+        title := name.Text
+        entryType := rest.GetType(name.Type)
+        _, caption := rest.SplitPath(title)
 
         // Get the icon if it exists, otherwise, substitue.
-        filename := GetImageName(image)
+        filename := GetImageName(entryType.IconName(title))
 
         // Match the query against the current item to decide if we
-        // should display it or not.
-        if h.Query == "" || strings.Contains(strings.ToLower(title), strings.ToLower(h.Query)) {
-            // Append to output.
-            filteredNameList = filteredNameList + fmt.Sprintf(`
+        // should display it or not. When searching, show the full path.
+        if h.Query == "" && !rest.InFolder(title, h.Folder) {
+            continue
+        }
+
+        if h.Query != "" {
+            if !strings.Contains(strings.ToLower(title), strings.ToLower(h.Query)) &&
+                !h.Notes[name.Encrypted] && h.URLs[name.Encrypted] == 0 {
+                continue
+            }
+            caption = title
+        }
+
+        // Append to output.
+        filteredNameList = filteredNameList + fmt.Sprintf(`
                     <a href="%s?Name=%s;Encrypted=%s">
                         <li>
                             <img src="iconpack/%s.png"/>
@@ -87,8 +148,8 @@ func (h *Search) Render() string {
                             <div class="SearchListItemLabel">%s</div>
                         </li>
                     </a>`,
-                label, title, name.Encrypted, filename, title, label)
-        }
+            entryType.View, title, name.Encrypted, filename, caption,
+            entryType.Label)
     }
 
     // Just to make the code look a bit cleaner.
@@ -102,6 +163,15 @@ func (h *Search) Render() string {
     return filteredNameList
 }
 
+func (h *Search) OnHref(URL *url.URL) {
+    // Browse to the folder given in the link.
+    h.Folder = URL.Query().Get("Folder")
+    currentFolder = h.Folder
+
+    h.Prefetch("")
+    app.Render(h)
+}
+
 func (h *Search) Prefetch(query string) {
     // Fetch from Vault.
     r, err := restClient.VaultListSecrets()
@@ -110,11 +180,35 @@ func (h *Search) Prefetch(query string) {
     h.Query = query
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
     h.Result = *r
+
+    // Notes are searched by their text too. They are decrypted and
+    // searched locally.
+    h.Notes, err = restClient.SearchNotes(h.Result, query)
+
+    if err != nil {
+        logger.Error(err)
+    }
+
+    // A query which looks like a URL also finds the entries for it,
+    // best match first.
+    h.URLs = nil
+
+    if urlmatch.LooksLikeURL(query) {
+        h.URLs, err = restClient.SearchURLs(h.Result, query, matcher)
+
+        if err != nil {
+            logger.Error(err)
+        }
+
+        sort.SliceStable(h.Result, func(i, j int) bool {
+            return h.URLs[h.Result[i].Encrypted] > h.URLs[h.Result[j].Encrypted]
+        })
+    }
 }
 
 func (h *Search) DoSearchQuery(arg app.ChangeArg) {
@@ -125,7 +219,7 @@ func (h *Search) DoSearchQuery(arg app.ChangeArg) {
 
 func NavigateBack(query string) {
     // This is used by other windows, to get less code repetition.
-    s := Search{}
+    s := Search{Folder: currentFolder}
     s.Prefetch(query)
     win.Mount(&s)
 }
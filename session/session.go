@@ -0,0 +1,247 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package session locks Pass when it has not been used for a while,
+// and at the latest a fixed time after it was unlocked, however busy
+// the user is.
+package session
+
+import (
+    "sync"
+    "time"
+)
+
+const (
+    DefaultIdle     = 10 * time.Minute
+    DefaultLifetime = 12 * time.Hour
+)
+
+// A Clock tells the time and calls functions later. Sessions use the
+// system clock unless given another, e.g., in tests.
+type Clock interface {
+    Now() time.Time
+    AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A Timer is a call scheduled by a Clock.
+type Timer interface {
+    Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+    return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+    return time.AfterFunc(d, f)
+}
+
+// SystemClock is the clock of the operating system.
+var SystemClock Clock = systemClock{}
+
+// A Manager keeps track of a session and calls OnLock when it expires:
+// Idle after it was last touched, or Lifetime after it started. A zero
+// duration disables the limit.
+type Manager struct {
+    Idle     time.Duration
+    Lifetime time.Duration
+
+    // Called when the session expires, by the timer or by Touch, but
+    // not when it is stopped. It may run on any goroutine.
+    OnLock func()
+
+    clock   Clock
+    mu      sync.Mutex
+    active  bool
+    started time.Time
+    last    time.Time
+    timer   Timer
+}
+
+// New returns a manager using the given clock, or the system clock if
+// nil.
+func New(c Clock, idle time.Duration, lifetime time.Duration, onLock func()) *Manager {
+    if c == nil {
+        c = SystemClock
+    }
+
+    return &Manager{
+        Idle:     idle,
+        Lifetime: lifetime,
+        OnLock:   onLock,
+        clock:    c,
+    }
+}
+
+// ParseDuration parses a configured limit, e.g., "10m". If empty, it
+// is the default; "0" disables the limit.
+func ParseDuration(s string, def time.Duration) (time.Duration, error) {
+    if s == "" {
+        return def, nil
+    }
+
+    return time.ParseDuration(s)
+}
+
+// Start starts a session, e.g., when Pass has been unlocked. A running
+// session is restarted.
+func (m *Manager) Start() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.active = true
+    m.started = m.clock.Now()
+    m.last = m.started
+    m.schedule()
+}
+
+// Touch tells that the user has done something, which postpones the
+// idle timeout. A session which should already have expired, e.g.,
+// because the computer slept, expires instead.
+func (m *Manager) Touch() {
+    m.mu.Lock()
+
+    if !m.active {
+        m.mu.Unlock()
+        return
+    }
+
+    now := m.clock.Now()
+
+    if m.expired(now) {
+        m.stop()
+        m.mu.Unlock()
+        m.lock()
+        return
+    }
+
+    m.last = now
+    m.schedule()
+    m.mu.Unlock()
+}
+
+// Stop ends the session without calling OnLock, e.g., when the user
+// has locked Pass.
+func (m *Manager) Stop() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.stop()
+}
+
+// Active reports whether a session is running.
+func (m *Manager) Active() bool {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.active
+}
+
+// Expires returns when the session expires unless touched. It is zero
+// if no session runs, or if it has no limits.
+func (m *Manager) Expires() time.Time {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if !m.active {
+        return time.Time{}
+    }
+
+    return m.deadline()
+}
+
+// The earliest of the limits, zero if there are none. The mutex must
+// be held.
+func (m *Manager) deadline() time.Time {
+    var d time.Time
+
+    if m.Idle > 0 {
+        d = m.last.Add(m.Idle)
+    }
+
+    if m.Lifetime > 0 {
+        if end := m.started.Add(m.Lifetime); d.IsZero() || end.Before(d) {
+            d = end
+        }
+    }
+
+    return d
+}
+
+// The mutex must be held.
+func (m *Manager) expired(now time.Time) bool {
+    d := m.deadline()
+    return !d.IsZero() && !now.Before(d)
+}
+
+// Sets the timer to the deadline. The mutex must be held.
+func (m *Manager) schedule() {
+    if m.timer != nil {
+        m.timer.Stop()
+        m.timer = nil
+    }
+
+    if d := m.deadline(); !d.IsZero() {
+        m.timer = m.clock.AfterFunc(d.Sub(m.clock.Now()), m.expire)
+    }
+}
+
+// The mutex must be held.
+func (m *Manager) stop() {
+    m.active = false
+
+    if m.timer != nil {
+        m.timer.Stop()
+        m.timer = nil
+    }
+}
+
+func (m *Manager) lock() {
+    if m.OnLock != nil {
+        m.OnLock()
+    }
+}
+
+// Called by the timer. A timer which could not be stopped in time may
+// still fire after the session was touched or stopped.
+func (m *Manager) expire() {
+    m.mu.Lock()
+
+    if !m.active || !m.expired(m.clock.Now()) {
+        m.mu.Unlock()
+        return
+    }
+
+    m.stop()
+    m.mu.Unlock()
+    m.lock()
+}
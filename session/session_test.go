@@ -0,0 +1,226 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package session
+
+import (
+    "sync"
+    "testing"
+    "time"
+)
+
+// A clock which only moves when told to.
+type fakeClock struct {
+    mu     sync.Mutex
+    now    time.Time
+    timers []*fakeTimer
+}
+
+type fakeTimer struct {
+    clock   *fakeClock
+    at      time.Time
+    f       func()
+    stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+    t.clock.mu.Lock()
+    defer t.clock.mu.Unlock()
+
+    stopped := t.stopped
+    t.stopped = true
+    return !stopped
+}
+
+func (c *fakeClock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+    c.timers = append(c.timers, t)
+    return t
+}
+
+// Moves the clock and fires the timers which are due.
+func (c *fakeClock) Advance(d time.Duration) {
+    c.mu.Lock()
+    c.now = c.now.Add(d)
+
+    var due []*fakeTimer
+
+    for _, t := range c.timers {
+        if !t.stopped && !t.at.After(c.now) {
+            t.stopped = true
+            due = append(due, t)
+        }
+    }
+
+    c.mu.Unlock()
+
+    for _, t := range due {
+        t.f()
+    }
+}
+
+func newManager(idle time.Duration, lifetime time.Duration) (*Manager, *fakeClock, *int) {
+    c := &fakeClock{now: time.Unix(1500000000, 0)}
+    locks := 0
+    return New(c, idle, lifetime, func() { locks++ }), c, &locks
+}
+
+func TestIdleTimeout(t *testing.T) {
+    m, c, locks := newManager(10*time.Minute, 0)
+    m.Start()
+
+    if !m.Active() || !m.Expires().Equal(c.Now().Add(10*time.Minute)) {
+        t.Fatalf("Session did not start: %v", m.Expires())
+    }
+
+    // Activity postpones the timeout.
+    c.Advance(9 * time.Minute)
+    m.Touch()
+    c.Advance(9 * time.Minute)
+
+    if *locks != 0 || !m.Active() {
+        t.Fatalf("Locked despite activity")
+    }
+
+    c.Advance(time.Minute)
+
+    if *locks != 1 || m.Active() || !m.Expires().IsZero() {
+        t.Errorf("Not locked when idle: %d", *locks)
+    }
+
+    // Touching a locked session does nothing.
+    m.Touch()
+    c.Advance(time.Hour)
+
+    if *locks != 1 || m.Active() {
+        t.Errorf("Locked session touched")
+    }
+}
+
+func TestLifetime(t *testing.T) {
+    m, c, locks := newManager(10*time.Minute, time.Hour)
+    m.Start()
+
+    for i := 0; i < 11; i++ {
+        c.Advance(5 * time.Minute)
+        m.Touch()
+    }
+
+    if *locks != 0 || !m.Expires().Equal(c.Now().Add(5*time.Minute)) {
+        t.Fatalf("Unexpected expiry %v", m.Expires())
+    }
+
+    c.Advance(5 * time.Minute)
+
+    if *locks != 1 || m.Active() {
+        t.Errorf("Not locked after its lifetime: %d", *locks)
+    }
+}
+
+func TestTouchAfterDeadline(t *testing.T) {
+    m, c, locks := newManager(10*time.Minute, 0)
+    m.Start()
+
+    // As if the computer slept and the timer has not fired yet.
+    c.mu.Lock()
+    c.now = c.now.Add(time.Hour)
+    c.mu.Unlock()
+
+    m.Touch()
+
+    if *locks != 1 || m.Active() {
+        t.Errorf("Expired session touched")
+    }
+
+    // The late timer does not lock again.
+    c.Advance(0)
+
+    if *locks != 1 {
+        t.Errorf("Locked twice: %d", *locks)
+    }
+}
+
+func TestStop(t *testing.T) {
+    m, c, locks := newManager(10*time.Minute, time.Hour)
+    m.Start()
+    m.Stop()
+    c.Advance(2 * time.Hour)
+
+    if *locks != 0 || m.Active() {
+        t.Errorf("Stopped session locked")
+    }
+
+    // Without limits, the session never expires.
+    m, c, locks = newManager(0, 0)
+    m.Start()
+    c.Advance(1000 * time.Hour)
+
+    if *locks != 0 || !m.Active() || !m.Expires().IsZero() {
+        t.Errorf("Session without limits expired")
+    }
+}
+
+func TestParseDuration(t *testing.T) {
+    for s, expected := range map[string]time.Duration{
+        "":    DefaultIdle,
+        "0":   0,
+        "30m": 30 * time.Minute,
+    } {
+        if d, err := ParseDuration(s, DefaultIdle); err != nil || d != expected {
+            t.Errorf("%q: got %v, %v", s, d, err)
+        }
+    }
+
+    if _, err := ParseDuration("soon", DefaultIdle); err == nil {
+        t.Errorf("Parsed an invalid duration")
+    }
+}
+
+func TestSystemClock(t *testing.T) {
+    done := make(chan bool, 1)
+    m := New(nil, 10*time.Millisecond, 0, func() { done <- true })
+    m.Start()
+
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Errorf("Did not lock")
+    }
+}
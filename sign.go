@@ -32,12 +32,11 @@ package main
 
 import (
     "encoding/base64"
-    "encoding/json"
     "github.com/murlokswarm/app"
     "golang.org/x/crypto/ed25519"
     "io/ioutil"
-    "log"
     "net/url"
+    "pass/logger"
     "pass/rest"
 )
 
@@ -48,10 +47,8 @@ type Sign struct {
     Data         rest.DecodedEntry
 }
 
-type KeyPair struct {
-    Priv []byte `json:"priv"`
-    Pub  []byte `json:"pub"`
-}
+// The key pair is the payload of signing entries.
+type KeyPair = rest.KeyPair
 
 var keyPair KeyPair
 
@@ -62,7 +59,7 @@ const (
 func (h *Sign) Render() string {
     var gen string
 
-    if len(h.Data.File) == 0 {
+    if len(keyPair.Priv) == 0 {
         gen = `<button class="button add" onclick="Generate"/>`
     } else {
         gen = ""
@@ -123,12 +120,16 @@ func (h *Sign) OnHref(URL *url.URL) {
         })
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
     h.Data = *restResponse
-    json.Unmarshal(h.Data.File, &keyPair)
+
+    if k, ok := h.Data.Payload.(*KeyPair); ok {
+        keyPair = *k
+    }
+
     h.PublicBase64 = base64.StdEncoding.EncodeToString(keyPair.Pub)
 
     // Tells the app to update the rendering of the component.
@@ -147,25 +148,28 @@ func (h *Sign) Generate() {
     pub, priv, err := ed25519.GenerateKey(nil)
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
-    keyPair := KeyPair{
+    keyPair = KeyPair{
         Priv: priv,
         Pub:  pub,
     }
 
-    // We will store the key pair as JSON in the
-    // the space where we would store file data.
-    jsonKeyPair, err := json.Marshal(&keyPair)
-    h.Data.File = jsonKeyPair
+    // The key pair is stored as the payload of the entry.
+    h.Data.Type = rest.TypeSign
+    h.Data.Payload = &KeyPair{
+        Priv: priv,
+        Pub:  pub,
+    }
+    h.PublicBase64 = base64.StdEncoding.EncodeToString(pub)
 
     // Write it to remote.
     err = restClient.VaultWriteSecret(&h.Data)
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
@@ -191,7 +195,7 @@ func (h *Sign) Sign() {
             // to permissions, dump error message
             // to log.
             if err != nil {
-                log.Println(err)
+                logger.Error(err)
                 return
             }
 
@@ -208,7 +212,7 @@ func (h *Sign) Sign() {
                 signature, 0644)
 
             if err != nil {
-                log.Println(err)
+                logger.Error(err)
                 return
             }
 
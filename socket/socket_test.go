@@ -0,0 +1,98 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package socket
+
+import (
+    "io/ioutil"
+    "net"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+)
+
+func TestListenPrivate(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("no permission bits on Windows")
+    }
+
+    path := filepath.Join(t.TempDir(), "test.sock")
+    listener, err := ListenPrivate(path)
+
+    if err != nil {
+        t.Fatalf("Could not listen: %s", err)
+    }
+
+    defer listener.Close()
+
+    if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+        t.Errorf("Others may connect: %v %v", info, err)
+    }
+}
+
+func TestRemoveStale(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "test.sock")
+
+    if err := RemoveStale(path); err != nil {
+        t.Errorf("Missing socket not ignored: %s", err)
+    }
+
+    listener, err := ListenPrivate(path)
+
+    if err != nil {
+        t.Fatalf("Could not listen: %s", err)
+    }
+
+    // Keep the file, as closing the listener would remove it.
+    listener.(*net.UnixListener).SetUnlinkOnClose(false)
+    listener.Close()
+
+    if err = RemoveStale(path); err != nil {
+        t.Errorf("Stale socket not removed: %s", err)
+    }
+
+    if _, err = os.Lstat(path); !os.IsNotExist(err) {
+        t.Errorf("Stale socket still there: %v", err)
+    }
+
+    // Anything else is left alone.
+    file := filepath.Join(dir, "metrics")
+    ioutil.WriteFile(file, []byte("keep"), 0600)
+
+    if err = RemoveStale(file); err != ErrNotSocket {
+        t.Errorf("Removed a file which is no socket: %v", err)
+    }
+
+    if data, _ := ioutil.ReadFile(file); string(data) != "keep" {
+        t.Errorf("File was changed")
+    }
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "io/ioutil"
+    "net/url"
+    "pass/logger"
+    "pass/rest"
+)
+
+type SSH struct {
+    Title       string
+    PublicKey   string
+    Fingerprint string
+    Algorithm   string
+    Algorithms  []string
+    Passphrase  string
+    Error       string
+    Data        rest.DecodedEntry
+}
+
+func (h *SSH) Render() string {
+    return `
+<div class="WindowLayout">
+    <div class="SearchLayout">
+        <input type="text"
+               value="{{html .Title}}"
+               placeholder="Account"
+               onchange="DoSearchQuery"
+               autocomplete="off"
+               autocorrect="off"
+               autocapitalize="off"
+               spellcheck="false"
+               selectable="on"
+               class="editable searchfield"/>
+        <div class="animated">
+            <div style="text-align: center;
+                        margin-left: auto;
+                        margin-right: auto;
+                        margin-top: -webkit-calc(20vh - 20px);">
+            ` + GetFingerprint([]byte(h.PublicKey), 90, 160, 255) + `
+                <h1>{{.Title}}</h1>
+            </div>
+            <div class="details">
+            {{if .PublicKey}}
+                <h2>Public key</h2>
+                <textarea readonly="readonly"
+                          selectable="on"
+                          class="editable url">{{html .PublicKey}}</textarea>
+                <p class="fingerprint" selectable="on">{{.Fingerprint}}</p>
+                <a class="clickable addfield" onclick="ToggleConfirm">
+                    {{if .Data.Payload.Confirm}}Ask before each use{{else}}Use without asking{{end}}
+                </a>
+            {{else}}
+                <select onchange="Algorithm" class="editable fieldtype">
+                    {{range .Algorithms}}
+                    <option value="{{.}}" {{if eq . $.Algorithm}}selected{{end}}>{{.}}</option>
+                    {{end}}
+                </select>
+                <input type="password"
+                       value="{{html .Passphrase}}"
+                       placeholder="Passphrase of imported key"
+                       onchange="Passphrase"
+                       autocomplete="off"
+                       selectable="on"
+                       class="editable password"/>
+            {{end}}
+            {{if .Error}}<p class="timestamps">{{.Error}}</p>{{end}}
+            </div>
+          </div>
+
+          <div class="bottom-toolbar">
+              <div>
+                  <button class="button ok" onclick="OK"/>
+                  {{if not .PublicKey}}
+                  <button class="button rerand" onclick="Generate"/>
+                  <button class="button add" onclick="Import"/>
+                  {{end}}
+                  <button class="button delete" onclick="Delete"/>
+              </div>
+          </div>
+     </div>
+</div>`
+}
+
+func (h *SSH) OnHref(URL *url.URL) {
+    h.Algorithms = []string{rest.SSHKeyEd25519, rest.SSHKeyECDSA, rest.SSHKeyRSA}
+    h.Algorithm = rest.SSHKeyEd25519
+
+    u := URL.Query()
+    h.Title = u.Get("Name")
+    restResponse, err := restClient.VaultReadSecret(
+        &rest.Name{
+            Text:      h.Title,
+            Encrypted: u.Get("Encrypted"),
+        })
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
+
+    h.Data = *restResponse
+
+    if _, ok := h.Data.Payload.(*rest.SSHKey); !ok {
+        h.Data.Payload = &rest.SSHKey{}
+    }
+
+    h.update()
+    app.Render(h)
+}
+
+// Sets the public key and fingerprint from the payload.
+func (h *SSH) update() {
+    key := h.Data.Payload.(*rest.SSHKey)
+    h.PublicKey, h.Fingerprint = "", ""
+
+    if len(key.Private) == 0 {
+        return
+    }
+
+    var err error
+
+    if h.PublicKey, err = key.PublicKey(); err != nil {
+        h.Error = err.Error()
+        return
+    }
+
+    h.Fingerprint, _ = key.Fingerprint()
+}
+
+// Writes the key to remote and offers it through the agent.
+func (h *SSH) save(key *rest.SSHKey) {
+    h.Data.Type = rest.TypeSSH
+    h.Data.Payload = key
+    h.Error = ""
+
+    if err := restClient.VaultWriteSecret(&h.Data); err != nil {
+        logger.Error(err)
+        h.Error = err.Error()
+        return
+    }
+
+    h.update()
+    AddSSHKey(h.Data.Name.Text, key)
+}
+
+func (h *SSH) OK() {
+    // Everything is saved as it is changed.
+    h.Cancel()
+}
+
+func (h *SSH) Generate() {
+    key, err := rest.GenerateSSHKey(h.Algorithm, 0, h.Title)
+
+    if err != nil {
+        logger.Error(err)
+        h.Error = err.Error()
+        app.Render(h)
+        return
+    }
+
+    h.save(key)
+    app.Render(h)
+}
+
+func (h *SSH) Import() {
+    // Open filepicker window to get the private key.
+    app.NewFilePicker(app.FilePicker{
+        MultipleSelection: false,
+        NoDir:             true,
+        NoFile:            false,
+        OnPick: func(filenames []string) {
+            data, err := ioutil.ReadFile(filenames[0])
+
+            if err != nil {
+                logger.Error(err)
+                return
+            }
+
+            key, err := rest.ImportSSHKey(data, []byte(h.Passphrase), h.Title)
+            h.Passphrase = ""
+
+            if err != nil {
+                logger.Error(err)
+                h.Error = err.Error()
+                app.Render(h)
+                return
+            }
+
+            h.save(key)
+            app.Render(h)
+        },
+    })
+}
+
+func (h *SSH) ToggleConfirm() {
+    key := h.Data.Payload.(*rest.SSHKey)
+    key.Confirm = !key.Confirm
+    h.save(key)
+    app.Render(h)
+}
+
+func (h *SSH) Cancel() {
+    NavigateBack("")
+}
+
+func (h *SSH) DoSearchQuery(arg app.ChangeArg) {
+    NavigateBack(arg.Value)
+}
+
+func (h *SSH) Delete() {
+    if h.Data.Name != nil {
+        RemoveSSHKey(h.Data.Payload.(*rest.SSHKey))
+        restClient.VaultDeleteSecret(&h.Data)
+    }
+
+    h.Cancel()
+}
+
+func init() {
+    app.RegisterComponent(&SSH{})
+}
@@ -0,0 +1,204 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "github.com/murlokswarm/app"
+    "golang.org/x/crypto/ssh"
+    "pass/logger"
+    "pass/rest"
+    "pass/sshagent"
+    "time"
+)
+
+const (
+    // Uses which are not confirmed in time are denied.
+    SSHConfirmTimeout = 30 * time.Second
+)
+
+// Serves the SSH keys while Pass is unlocked, if configured.
+var sshAgent *sshagent.Agent
+
+func startSSHAgent() {
+    if config.SSHAgent.Listen == "" || sshAgent != nil {
+        return
+    }
+
+    a := sshagent.New(confirmSSHKey)
+    a.ConfirmAll = config.SSHAgent.Confirm
+
+    if err := a.Listen(config.SSHAgent.Listen); err != nil {
+        logger.Error("Could not start ssh-agent:", err)
+        return
+    }
+
+    logger.Info("ssh-agent listening on", config.SSHAgent.Listen)
+    sshAgent = a
+}
+
+// Adds all SSH keys in the vault to the agent. It reads every entry of
+// the SSH key type, so it runs in the background.
+func loadSSHKeys(names []rest.Name) {
+    if sshAgent == nil {
+        return
+    }
+
+    runInBackground(func(client *rest.Client) {
+        for i := range names {
+            if names[i].Type != rest.TypeSSH {
+                continue
+            }
+
+            entry, err := client.VaultReadSecret(&names[i])
+
+            if err == rest.ErrCanceled {
+                return
+            }
+
+            if err != nil {
+                logger.Error(err)
+                continue
+            }
+
+            if key, ok := entry.Payload.(*rest.SSHKey); ok {
+                AddSSHKey(entry.Name.Text, key)
+            }
+        }
+    }, nil)
+}
+
+// AddSSHKey offers a key through the agent, if it is running.
+func AddSSHKey(name string, key *rest.SSHKey) {
+    if sshAgent == nil || len(key.Private) == 0 {
+        return
+    }
+
+    signer, err := key.Signer()
+
+    if err != nil {
+        logger.Error(err)
+        return
+    }
+
+    sshAgent.AddKey(sshagent.Key{
+        Name:    name,
+        Comment: key.Comment,
+        Signer:  signer,
+        Confirm: key.Confirm,
+    })
+}
+
+// RemoveSSHKey stops offering a key through the agent.
+func RemoveSSHKey(key *rest.SSHKey) {
+    if sshAgent == nil || len(key.Private) == 0 {
+        return
+    }
+
+    if signer, err := key.Signer(); err == nil {
+        sshAgent.Remove(signer.PublicKey())
+    }
+}
+
+// Asks the user whether a key may be used. It is called by the agent,
+// outside of the UI goroutine, and blocks until the user decides.
+func confirmSSHKey(key sshagent.Key) bool {
+    answer := make(chan bool, 1)
+
+    app.CallOnUIGoroutine(func() {
+        if win == nil || pass.Locked {
+            answer <- false
+            return
+        }
+
+        win.Mount(&SSHConfirm{
+            Name:        key.Name,
+            Fingerprint: ssh.FingerprintSHA256(key.Signer.PublicKey()),
+            answer:      answer,
+        })
+    })
+
+    select {
+    case ok := <-answer:
+        return ok
+    case <-time.After(SSHConfirmTimeout):
+        logger.Info("Use of SSH key was not confirmed in time.")
+        return false
+    }
+}
+
+type SSHConfirm struct {
+    Name        string
+    Fingerprint string
+    answer      chan bool
+}
+
+func (h *SSHConfirm) Render() string {
+    return `
+<div class="WindowLayout">
+    <div class="animated">
+        <div style="text-align: center;
+                    margin-left: auto;
+                    margin-right: auto;
+                    margin-top: -webkit-calc(20vh - 20px);">
+            <img src="iconpack/sign.png" style="max-width: 128px;"/>
+            <h1>{{.Name}}</h1>
+            <h2>Allow use of SSH key?</h2>
+            <p class="fingerprint">{{.Fingerprint}}</p>
+        </div>
+        <div class="bottom-toolbar">
+            <button class="button ok" onclick="Allow"/>
+            <button class="button cancel" onclick="Deny"/>
+        </div>
+    </div>
+</div>`
+}
+
+func (h *SSHConfirm) reply(ok bool) {
+    // The agent may have given up waiting.
+    select {
+    case h.answer <- ok:
+    default:
+    }
+
+    NavigateBack("")
+}
+
+func (h *SSHConfirm) Allow() {
+    h.reply(true)
+}
+
+func (h *SSHConfirm) Deny() {
+    h.reply(false)
+}
+
+func init() {
+    app.RegisterComponent(&SSHConfirm{})
+}
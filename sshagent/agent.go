@@ -0,0 +1,339 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package sshagent serves SSH keys stored in Pass to ssh and other
+// clients over the ssh-agent protocol. Keys are only held while Pass is
+// unlocked and are never handed out, only used for signing.
+package sshagent
+
+import (
+    "bytes"
+    "crypto/subtle"
+    "errors"
+    "golang.org/x/crypto/ssh"
+    "golang.org/x/crypto/ssh/agent"
+    "net"
+    "os"
+    "pass/socket"
+    "sync"
+)
+
+var (
+    ErrDenied   = errors.New("use of key was denied")
+    ErrNotFound = errors.New("key not found")
+    ErrLocked   = errors.New("agent is locked")
+    ErrReadOnly = errors.New("keys are managed by Pass")
+)
+
+// A Key is a key served by the agent.
+type Key struct {
+    // Name of the entry holding the key.
+    Name    string
+    Comment string
+    Signer  ssh.Signer
+
+    // Ask before each use of the key.
+    Confirm bool
+}
+
+// Agent implements agent.ExtendedAgent. Keys are added by Pass, but
+// clients may remove them from the agent, as with ssh-add -d, and lock
+// it with a passphrase, as with ssh-add -x.
+type Agent struct {
+    // Called before a key with Confirm set is used, and for every key
+    // if ConfirmAll is set. The key is only used if it returns true.
+    // It may block while the user decides. If nil, such uses are
+    // denied.
+    Confirm    func(key Key) bool
+    ConfirmAll bool
+
+    mu         sync.Mutex
+    keys       []Key
+    passphrase []byte
+    listener   net.Listener
+    path       string
+}
+
+func New(confirm func(key Key) bool) *Agent {
+    return &Agent{Confirm: confirm}
+}
+
+// AddKey adds a key to the agent, replacing any key with the same
+// public key.
+func (a *Agent) AddKey(key Key) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    a.remove(key.Signer.PublicKey())
+    a.keys = append(a.keys, key)
+}
+
+// Clear removes all keys. It is called when Pass is locked.
+func (a *Agent) Clear() {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    a.keys = nil
+    a.passphrase = nil
+}
+
+func (a *Agent) remove(pub ssh.PublicKey) bool {
+    blob := pub.Marshal()
+
+    for i, k := range a.keys {
+        if bytes.Equal(k.Signer.PublicKey().Marshal(), blob) {
+            a.keys = append(a.keys[:i], a.keys[i+1:]...)
+            return true
+        }
+    }
+
+    return false
+}
+
+func (a *Agent) find(pub ssh.PublicKey) (Key, error) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase != nil {
+        return Key{}, ErrLocked
+    }
+
+    blob := pub.Marshal()
+
+    for _, k := range a.keys {
+        if bytes.Equal(k.Signer.PublicKey().Marshal(), blob) {
+            return k, nil
+        }
+    }
+
+    return Key{}, ErrNotFound
+}
+
+// List returns the public keys. A locked agent has no keys.
+func (a *Agent) List() ([]*agent.Key, error) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase != nil {
+        return nil, nil
+    }
+
+    keys := make([]*agent.Key, 0, len(a.keys))
+
+    for _, k := range a.keys {
+        pub := k.Signer.PublicKey()
+        comment := k.Comment
+
+        if comment == "" {
+            comment = k.Name
+        }
+
+        keys = append(keys, &agent.Key{
+            Format:  pub.Type(),
+            Blob:    pub.Marshal(),
+            Comment: comment,
+        })
+    }
+
+    return keys, nil
+}
+
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+    return a.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags signs data with the given key, after asking for
+// confirmation if needed. The flags select SHA-2 signatures for RSA
+// keys.
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+    k, err := a.find(key)
+
+    if err != nil {
+        return nil, err
+    }
+
+    // The lock is not held while the user decides.
+    if k.Confirm || a.ConfirmAll {
+        if a.Confirm == nil || !a.Confirm(k) {
+            return nil, ErrDenied
+        }
+    }
+
+    algorithm := ""
+
+    switch {
+    case flags&agent.SignatureFlagRsaSha256 != 0:
+        algorithm = ssh.KeyAlgoRSASHA256
+    case flags&agent.SignatureFlagRsaSha512 != 0:
+        algorithm = ssh.KeyAlgoRSASHA512
+    }
+
+    if algorithm == "" || key.Type() != ssh.KeyAlgoRSA {
+        return k.Signer.Sign(nil, data)
+    }
+
+    signer, ok := k.Signer.(ssh.AlgorithmSigner)
+
+    if !ok {
+        return nil, errors.New("signer does not support " + algorithm)
+    }
+
+    return signer.SignWithAlgorithm(nil, data, algorithm)
+}
+
+// Add is part of the agent protocol. Keys can only be added through
+// Pass.
+func (a *Agent) Add(key agent.AddedKey) error {
+    return ErrReadOnly
+}
+
+// Remove removes a key from the agent, not from Pass.
+func (a *Agent) Remove(key ssh.PublicKey) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase != nil {
+        return ErrLocked
+    }
+
+    if !a.remove(key) {
+        return ErrNotFound
+    }
+
+    return nil
+}
+
+func (a *Agent) RemoveAll() error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase != nil {
+        return ErrLocked
+    }
+
+    a.keys = nil
+    return nil
+}
+
+// Lock locks the agent with a passphrase. While locked, no keys are
+// listed or used.
+func (a *Agent) Lock(passphrase []byte) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase != nil {
+        return ErrLocked
+    }
+
+    a.passphrase = append([]byte{}, passphrase...)
+    return nil
+}
+
+func (a *Agent) Unlock(passphrase []byte) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.passphrase == nil {
+        return errors.New("agent is not locked")
+    }
+
+    if subtle.ConstantTimeCompare(a.passphrase, passphrase) != 1 {
+        return errors.New("incorrect passphrase")
+    }
+
+    a.passphrase = nil
+    return nil
+}
+
+// Signers is not used by the agent protocol. Handing out signers would
+// bypass confirmation, so there are none.
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+    return nil, nil
+}
+
+func (a *Agent) Extension(extensionType string, contents []byte) ([]byte, error) {
+    return nil, agent.ErrExtensionUnsupported
+}
+
+// Listen serves the agent on a unix socket, which only the current
+// user may connect to. Point SSH_AUTH_SOCK to it to use the agent.
+func (a *Agent) Listen(path string) error {
+    // Remove a stale socket from an earlier run.
+    if err := socket.RemoveStale(path); err != nil {
+        return err
+    }
+
+    listener, err := socket.ListenPrivate(path)
+
+    if err != nil {
+        return err
+    }
+
+    a.mu.Lock()
+    a.listener = listener
+    a.path = path
+    a.mu.Unlock()
+
+    go a.serve(listener)
+    return nil
+}
+
+func (a *Agent) serve(listener net.Listener) {
+    for {
+        conn, err := listener.Accept()
+
+        if err != nil {
+            return
+        }
+
+        go func() {
+            defer conn.Close()
+            agent.ServeAgent(a, conn)
+        }()
+    }
+}
+
+// Close stops serving the agent and removes all keys.
+func (a *Agent) Close() error {
+    a.Clear()
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.listener == nil {
+        return nil
+    }
+
+    err := a.listener.Close()
+    os.Remove(a.path)
+    a.listener = nil
+
+    return err
+}
@@ -0,0 +1,207 @@
+package sshagent
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "golang.org/x/crypto/ed25519"
+    "golang.org/x/crypto/ssh"
+    "golang.org/x/crypto/ssh/agent"
+    "net"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+)
+
+func newKey(t *testing.T, name string, confirm bool) Key {
+    _, priv, err := ed25519.GenerateKey(rand.Reader)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    signer, _ := ssh.NewSignerFromKey(priv)
+    return Key{Name: name, Signer: signer, Confirm: confirm}
+}
+
+func newClient(t *testing.T, a *Agent) (agent.ExtendedAgent, func()) {
+    path := filepath.Join(t.TempDir(), "agent.sock")
+
+    if err := a.Listen(path); err != nil {
+        t.Fatalf("Could not listen: %s", err)
+    }
+
+    conn, err := net.Dial("unix", path)
+
+    if err != nil {
+        t.Fatalf("Could not connect: %s", err)
+    }
+
+    return agent.NewClient(conn), func() {
+        conn.Close()
+        a.Close()
+    }
+}
+
+func TestSignAndClear(t *testing.T) {
+    a := New(nil)
+    client, done := newClient(t, a)
+    defer done()
+
+    key := newKey(t, "server", false)
+    a.AddKey(key)
+
+    keys, err := client.List()
+
+    if err != nil || len(keys) != 1 || keys[0].Comment != "server" {
+        t.Fatalf("Unexpected keys: %v %v", keys, err)
+    }
+
+    signature, err := client.Sign(key.Signer.PublicKey(), []byte("data"))
+
+    if err != nil {
+        t.Fatalf("Could not sign: %s", err)
+    }
+
+    if err = key.Signer.PublicKey().Verify([]byte("data"), signature); err != nil {
+        t.Errorf("Invalid signature: %s", err)
+    }
+
+    if err = client.Add(agent.AddedKey{PrivateKey: rsaKey(t)}); err == nil {
+        t.Errorf("Client added a key")
+    }
+
+    // Locking Pass removes all keys.
+    a.Clear()
+    keys, _ = client.List()
+
+    if len(keys) != 0 {
+        t.Errorf("Keys were not removed")
+    }
+
+    if _, err = client.Sign(key.Signer.PublicKey(), []byte("data")); err == nil {
+        t.Errorf("Removed key was used")
+    }
+}
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+    k, err := rsa.GenerateKey(rand.Reader, 2048)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return k
+}
+
+func TestRSASHA2(t *testing.T) {
+    a := New(nil)
+    client, done := newClient(t, a)
+    defer done()
+
+    signer, _ := ssh.NewSignerFromKey(rsaKey(t))
+    a.AddKey(Key{Name: "rsa", Signer: signer})
+
+    signature, err := client.SignWithFlags(signer.PublicKey(), []byte("data"),
+        agent.SignatureFlagRsaSha256)
+
+    if err != nil || signature.Format != ssh.KeyAlgoRSASHA256 {
+        t.Fatalf("Unexpected signature: %v", err)
+    }
+
+    if err = signer.PublicKey().Verify([]byte("data"), signature); err != nil {
+        t.Errorf("Invalid signature: %s", err)
+    }
+}
+
+func TestConfirm(t *testing.T) {
+    allow := false
+    asked := 0
+
+    a := New(func(key Key) bool {
+        asked++
+        return allow
+    })
+
+    client, done := newClient(t, a)
+    defer done()
+
+    confirmed := newKey(t, "confirmed", true)
+    free := newKey(t, "free", false)
+    a.AddKey(confirmed)
+    a.AddKey(free)
+
+    if _, err := client.Sign(confirmed.Signer.PublicKey(), []byte("data")); err == nil {
+        t.Errorf("Denied key was used")
+    }
+
+    allow = true
+
+    if _, err := client.Sign(confirmed.Signer.PublicKey(), []byte("data")); err != nil {
+        t.Errorf("Confirmed key was not used: %s", err)
+    }
+
+    if _, err := client.Sign(free.Signer.PublicKey(), []byte("data")); err != nil || asked != 2 {
+        t.Errorf("Unexpected confirmation, asked %d times", asked)
+    }
+
+    a.ConfirmAll = true
+    client.Sign(free.Signer.PublicKey(), []byte("data"))
+
+    if asked != 3 {
+        t.Errorf("Confirmation was not asked for every key")
+    }
+}
+
+func TestAgentLock(t *testing.T) {
+    a := New(nil)
+    client, done := newClient(t, a)
+    defer done()
+
+    a.AddKey(newKey(t, "server", false))
+
+    if err := client.Lock([]byte("secret")); err != nil {
+        t.Fatalf("Could not lock: %s", err)
+    }
+
+    if keys, _ := client.List(); len(keys) != 0 {
+        t.Errorf("Locked agent listed keys")
+    }
+
+    if err := client.Unlock([]byte("wrong")); err == nil {
+        t.Errorf("Unlocked with wrong passphrase")
+    }
+
+    if err := client.Unlock([]byte("secret")); err != nil {
+        t.Errorf("Could not unlock: %s", err)
+    }
+
+    if keys, _ := client.List(); len(keys) != 1 {
+        t.Errorf("Keys were lost while locked")
+    }
+}
+
+func TestSocketPermissions(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("no permission bits on Windows")
+    }
+
+    a := New(nil)
+    path := filepath.Join(t.TempDir(), "agent.sock")
+
+    if err := a.Listen(path); err != nil {
+        t.Fatalf("Could not listen: %s", err)
+    }
+
+    defer a.Close()
+
+    info, err := os.Stat(path)
+
+    if err != nil {
+        t.Fatalf("No socket: %s", err)
+    }
+
+    if info.Mode().Perm()&0077 != 0 {
+        t.Errorf("Others may connect: %v", info.Mode())
+    }
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package tui
+
+import (
+    "unicode/utf8"
+)
+
+// A Code tells which key was pressed. Printable characters are KeyRune.
+type Code int
+
+const (
+    KeyRune Code = iota
+    KeyEnter
+    KeyBackspace
+    KeyEscape
+    KeyTab
+    KeyUp
+    KeyDown
+    KeyPageUp
+    KeyPageDown
+
+    // Control keys with a meaning of their own.
+    KeyCtrlC
+    KeyCtrlL
+    KeyCtrlU
+)
+
+// A Key is a key pressed on the terminal.
+type Key struct {
+    Code Code
+    Rune rune
+}
+
+// Control sequences of the keys we use, after the escape and the '['
+// or 'O'.
+var sequences = map[string]Code{
+    "A":  KeyUp,
+    "B":  KeyDown,
+    "5~": KeyPageUp,
+    "6~": KeyPageDown,
+}
+
+// ParseKeys decodes what a terminal in raw mode sends, which may be
+// several keys at once. Keys we do not use are dropped.
+func ParseKeys(b []byte) []Key {
+    var keys []Key
+
+    for len(b) > 0 {
+        switch c := b[0]; {
+        case c == 0x1b:
+            // An escape followed by a '[' or 'O' starts a control
+            // sequence, which ends with a byte from '@' to '~'. Other
+            // escapes are the escape key.
+            if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+                keys = append(keys, Key{Code: KeyEscape})
+                b = b[1:]
+                continue
+            }
+
+            end := 2
+
+            for end < len(b) && (b[end] < '@' || b[end] > '~') {
+                end++
+            }
+
+            if end == len(b) {
+                return keys
+            }
+
+            if code, ok := sequences[string(b[2:end+1])]; ok {
+                keys = append(keys, Key{Code: code})
+            }
+
+            b = b[end+1:]
+            continue
+        case c == '\r' || c == '\n':
+            keys = append(keys, Key{Code: KeyEnter})
+        case c == 0x7f || c == 0x08:
+            keys = append(keys, Key{Code: KeyBackspace})
+        case c == '\t':
+            keys = append(keys, Key{Code: KeyTab})
+        case c == 0x03:
+            keys = append(keys, Key{Code: KeyCtrlC})
+        case c == 0x0c:
+            keys = append(keys, Key{Code: KeyCtrlL})
+        case c == 0x15:
+            keys = append(keys, Key{Code: KeyCtrlU})
+        case c < 0x20:
+        default:
+            r, size := utf8.DecodeRune(b)
+
+            if r != utf8.RuneError || size > 1 {
+                keys = append(keys, Key{Code: KeyRune, Rune: r})
+            }
+
+            b = b[size:]
+            continue
+        }
+
+        b = b[1:]
+    }
+
+    return keys
+}
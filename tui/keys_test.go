@@ -0,0 +1,57 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package tui
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseKeys(t *testing.T) {
+    for input, expected := range map[string][]Key{
+        "aö":             {{Code: KeyRune, Rune: 'a'}, {Code: KeyRune, Rune: 'ö'}},
+        "\r\x7f\t":       {{Code: KeyEnter}, {Code: KeyBackspace}, {Code: KeyTab}},
+        "\x1b":           {{Code: KeyEscape}},
+        "\x1bx":          {{Code: KeyEscape}, {Code: KeyRune, Rune: 'x'}},
+        "\x1b[A\x1bOB":   {{Code: KeyUp}, {Code: KeyDown}},
+        "\x1b[5~\x1b[6~": {{Code: KeyPageUp}, {Code: KeyPageDown}},
+        "\x03\x0c\x15":   {{Code: KeyCtrlC}, {Code: KeyCtrlL}, {Code: KeyCtrlU}},
+
+        // Keys we do not use, and broken input, are dropped.
+        "\x1b[1;5Cz": {{Code: KeyRune, Rune: 'z'}},
+        "\x01\xffb":  {{Code: KeyRune, Rune: 'b'}},
+        "\x1b[1;":    nil,
+    } {
+        if keys := ParseKeys([]byte(input)); !reflect.DeepEqual(keys, expected) {
+            t.Errorf("%q: got %v, expected %v", input, keys, expected)
+        }
+    }
+}
@@ -0,0 +1,401 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package tui
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "golang.org/x/crypto/ed25519"
+    "io/ioutil"
+    "os"
+    "pass/otp"
+    "pass/rest"
+    "path/filepath"
+    "strings"
+)
+
+// A line typed at the bottom of a pane, e.g., a path.
+type input struct {
+    label string
+    text  []rune
+    done  func(text string)
+}
+
+// The common part of the panes: the entry, the way back to the search
+// list and the input, if one is being typed.
+type pane struct {
+    ui    *UI
+    back  view
+    entry *rest.DecodedEntry
+    input *input
+}
+
+// Asks for a line of text, starting with the given text.
+func (p *pane) ask(label string, text string, done func(text string)) {
+    p.input = &input{label: label, text: []rune(text), done: done}
+}
+
+// Draws the title and the input, if any.
+func (p *pane) draw(c *canvas) {
+    c.title = p.entry.Name.Text
+
+    if p.input != nil {
+        c.prompt = p.input.label + string(p.input.text) + "_"
+    }
+}
+
+// Handles the keys common to all panes. It returns false if the pane
+// should handle the key itself.
+func (p *pane) handle(k Key) bool {
+    if p.input != nil {
+        switch k.Code {
+        case KeyRune:
+            p.input.text = append(p.input.text, k.Rune)
+        case KeyBackspace:
+            if len(p.input.text) > 0 {
+                p.input.text = p.input.text[:len(p.input.text)-1]
+            }
+        case KeyCtrlU:
+            p.input.text = nil
+        case KeyEscape:
+            p.input = nil
+        case KeyEnter:
+            in := p.input
+            p.input = nil
+            in.done(string(in.text))
+        }
+        return true
+    }
+
+    switch {
+    case k.Code == KeyEscape, k.Code == KeyBackspace, k.Code == KeyRune && k.Rune == 'q':
+        p.ui.status = ""
+        p.ui.view = p.back
+        return true
+    }
+
+    return false
+}
+
+// Expands a leading ~ to the home directory.
+func expand(path string) (string, error) {
+    path = strings.TrimSpace(path)
+
+    if path == "" {
+        return "", ErrNoPath
+    }
+
+    if path == "~" || strings.HasPrefix(path, "~/") {
+        home, err := os.UserHomeDir()
+
+        if err != nil {
+            return "", err
+        }
+
+        path = filepath.Join(home, path[1:])
+    }
+
+    return path, nil
+}
+
+// Writes a new file, which only the user may read, and never replaces
+// an existing one.
+func writeNew(path string, data []byte) error {
+    f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+    if err != nil {
+        return err
+    }
+
+    if _, err = f.Write(data); err != nil {
+        f.Close()
+        return err
+    }
+
+    return f.Close()
+}
+
+const hidden = "••••••••"
+
+// The pane of accounts, and of the types without a pane of their own.
+// Passwords and hidden fields are only shown when revealed.
+type accountView struct {
+    pane
+    reveal bool
+}
+
+func (v *accountView) secret(s string) string {
+    if v.reveal || s == "" {
+        return s
+    }
+    return hidden
+}
+
+func (v *accountView) draw(c *canvas) {
+    v.pane.draw(c)
+    d := v.entry
+
+    c.field("Type", rest.GetType(d.Type).Label)
+    c.field("Username", d.Username)
+
+    // The password is only turned into a string when it is shown.
+    password := hidden
+
+    if v.reveal || d.Password.Len() == 0 {
+        password = d.PasswordText()
+    }
+
+    c.field("Password", password)
+
+    for i, u := range d.URLs {
+        label := ""
+
+        if i == 0 {
+            label = "URLs"
+        }
+
+        c.field(label, u)
+    }
+
+    if d.Match != "" {
+        c.field("Match", d.Match)
+    }
+
+    for _, f := range d.Fields {
+        value := f.Value
+
+        if f.Type == rest.FieldHidden {
+            value = v.secret(value)
+        }
+
+        c.field(f.Name, value)
+    }
+
+    if len(d.Tags) > 0 {
+        c.field("Tags", strings.Join(d.Tags, ", "))
+    }
+
+    if !d.Modified.IsZero() {
+        c.field("Modified", d.Modified.Format("2006-01-02 15:04"))
+    }
+
+    // Notes are shown as they are, other payloads, e.g., cards, only
+    // when revealed.
+    var text string
+
+    switch payload := d.Payload.(type) {
+    case nil:
+    case *rest.Note:
+        text = payload.Text
+    default:
+        text = hidden
+
+        if v.reveal {
+            data, _ := json.MarshalIndent(payload, "", "  ")
+            text = string(data)
+        }
+    }
+
+    for _, lines := range []string{text, d.Notes} {
+        if lines == "" {
+            continue
+        }
+
+        c.line("")
+
+        for _, line := range strings.Split(lines, "\n") {
+            c.line("%s", line)
+        }
+    }
+
+    if v.reveal {
+        c.help = "r hide · Esc back · Ctrl-L lock"
+    } else {
+        c.help = "r reveal · Esc back · Ctrl-L lock"
+    }
+}
+
+func (v *accountView) handle(k Key) {
+    if v.pane.handle(k) {
+        return
+    }
+
+    if k.Code == KeyRune && k.Rune == 'r' {
+        v.reveal = !v.reveal
+    }
+}
+
+// The pane of OTP entries, with the current code and the seconds
+// until it changes.
+type otpView struct {
+    pane
+}
+
+func (v *otpView) draw(c *canvas) {
+    v.pane.draw(c)
+    remaining := otpPeriod - int(v.ui.now().Unix()%otpPeriod)
+
+    c.field("Code", otp.ComputeOTPCode(v.entry.Password.Bytes()))
+    c.field("Expires", strings.Repeat("█", remaining)+strings.Repeat("·", otpPeriod-remaining))
+    c.line("%-10s %ds", "", remaining)
+    c.help = "Esc back · Ctrl-L lock"
+}
+
+func (v *otpView) handle(k Key) {
+    v.pane.handle(k)
+}
+
+// The pane of file entries, which saves the file to a path.
+type fileView struct {
+    pane
+}
+
+func (v *fileView) draw(c *canvas) {
+    v.pane.draw(c)
+    c.field("Size", fmt.Sprintf("%d kB", (len(v.entry.File)+1023)/1024))
+    c.help = "s save to a file · Esc back · Ctrl-L lock"
+}
+
+func (v *fileView) handle(k Key) {
+    if v.pane.handle(k) {
+        return
+    }
+
+    if k.Code == KeyRune && k.Rune == 's' {
+        _, filename := rest.SplitPath(v.entry.Name.Text)
+
+        v.ask("Save to: ", filename, func(text string) {
+            path, err := expand(text)
+
+            if err == nil {
+                err = writeNew(path, v.entry.File)
+            }
+
+            if err != nil {
+                v.ui.fail(err)
+                return
+            }
+
+            v.ui.status = "Wrote " + path
+        })
+    }
+}
+
+// The pane of signing entries, which signs a file with the key, or
+// generates the key.
+type signView struct {
+    pane
+}
+
+func (v *signView) keyPair() (*rest.KeyPair, bool) {
+    k, ok := v.entry.Payload.(*rest.KeyPair)
+    return k, ok && len(k.Priv) == ed25519.PrivateKeySize
+}
+
+func (v *signView) draw(c *canvas) {
+    v.pane.draw(c)
+
+    if k, ok := v.keyPair(); ok {
+        c.field("Public key", base64.StdEncoding.EncodeToString(k.Pub))
+        c.help = "s sign a file · Esc back · Ctrl-L lock"
+    } else {
+        c.line("No key has been generated.")
+        c.help = "g generate a key · Esc back · Ctrl-L lock"
+    }
+}
+
+func (v *signView) handle(k Key) {
+    if v.pane.handle(k) || k.Code != KeyRune {
+        return
+    }
+
+    switch k.Rune {
+    case 's':
+        v.ask("File to sign: ", "", v.sign)
+    case 'g':
+        if _, ok := v.keyPair(); !ok {
+            v.generate()
+        }
+    }
+}
+
+// Signs a file, writing the signature next to it, as the desktop app
+// does.
+func (v *signView) sign(text string) {
+    k, ok := v.keyPair()
+
+    if !ok {
+        v.ui.fail(ErrNoKey)
+        return
+    }
+
+    path, err := expand(text)
+
+    if err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    data, err := ioutil.ReadFile(path)
+
+    if err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    signature := ed25519.Sign(ed25519.PrivateKey(k.Priv), data)
+
+    if err = writeNew(path+SignatureFileExtension, signature); err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    v.ui.status = "Wrote " + path + SignatureFileExtension
+}
+
+func (v *signView) generate() {
+    // By passing nil as argument, we default to crypto/rand.
+    pub, priv, err := ed25519.GenerateKey(nil)
+
+    if err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    v.entry.Type = rest.TypeSign
+    v.entry.Payload = &rest.KeyPair{Priv: priv, Pub: pub}
+
+    if err = v.ui.vault.VaultWriteSecret(v.entry); err != nil {
+        v.entry.Payload = nil
+        v.ui.fail(err)
+    }
+}
@@ -0,0 +1,297 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package tui
+
+import (
+    "pass/rest"
+    "pass/urlmatch"
+    "sort"
+    "strings"
+)
+
+// The unlock prompt. The password is never drawn, not even as stars.
+type unlockView struct {
+    ui       *UI
+    password []rune
+}
+
+func (v *unlockView) draw(c *canvas) {
+    c.title = "Pass"
+    c.line("Pass is locked.")
+    c.line("")
+    c.line("Password:")
+    c.help = "Enter unlock · Ctrl-U clear · Ctrl-C quit"
+}
+
+// Overwrites the typed password.
+func (v *unlockView) clear() {
+    for i := range v.password {
+        v.password[i] = 0
+    }
+    v.password = v.password[:0]
+}
+
+func (v *unlockView) handle(k Key) {
+    switch k.Code {
+    case KeyRune:
+        v.password = append(v.password, k.Rune)
+    case KeyBackspace:
+        if len(v.password) > 0 {
+            v.password[len(v.password)-1] = 0
+            v.password = v.password[:len(v.password)-1]
+        }
+    case KeyCtrlU, KeyEscape:
+        v.clear()
+    case KeyEnter:
+        password := string(v.password)
+        v.clear()
+
+        vault, err := v.ui.Connect(password)
+
+        if err != nil {
+            v.ui.fail(err)
+            return
+        }
+
+        v.ui.Unlock(vault)
+    }
+}
+
+// An item of the search list: a folder or an entry.
+type item struct {
+    folder  string
+    name    *rest.Name
+    caption string
+    label   string
+}
+
+// The search list. Like the desktop app, it browses the folder tree
+// while the query is empty, and otherwise searches all folders, by
+// name, by the text of notes and, if the query is a URL, by URL.
+type searchView struct {
+    ui       *UI
+    names    []rest.Name
+    folder   string
+    query    []rune
+    items    []item
+    selected int
+    offset   int
+}
+
+// Reads the names of the entries.
+func (v *searchView) load() {
+    names, err := v.ui.vault.VaultListSecrets()
+
+    if err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    v.names = *names
+    v.filter()
+}
+
+// Builds the list for the folder and query.
+func (v *searchView) filter() {
+    query := string(v.query)
+    names := v.names
+    v.items = nil
+    v.selected = 0
+    v.offset = 0
+
+    if query == "" {
+        if v.folder != "" {
+            v.items = append(v.items, item{folder: rest.ParentFolder(v.folder), caption: "..", label: "Folder"})
+        }
+
+        for _, folder := range rest.Folders(names, v.folder) {
+            _, caption := rest.SplitPath(folder)
+            v.items = append(v.items, item{folder: folder, caption: caption, label: "Folder"})
+        }
+    }
+
+    notes, err := v.ui.vault.SearchNotes(names, query)
+
+    if err != nil {
+        v.ui.fail(err)
+    }
+
+    var urls map[string]int
+
+    if urlmatch.LooksLikeURL(query) {
+        if urls, err = v.ui.vault.SearchURLs(names, query, v.ui.Matcher); err != nil {
+            v.ui.fail(err)
+        }
+
+        // Best match first, leaving the list as it is.
+        names = append([]rest.Name(nil), names...)
+
+        sort.SliceStable(names, func(i, j int) bool {
+            return urls[names[i].Encrypted] > urls[names[j].Encrypted]
+        })
+    }
+
+    for i := range names {
+        name := &names[i]
+        _, caption := rest.SplitPath(name.Text)
+
+        if query == "" && !rest.InFolder(name.Text, v.folder) {
+            continue
+        }
+
+        if query != "" {
+            if !strings.Contains(strings.ToLower(name.Text), strings.ToLower(query)) &&
+                !notes[name.Encrypted] && urls[name.Encrypted] == 0 {
+                continue
+            }
+            caption = name.Text
+        }
+
+        v.items = append(v.items, item{name: name, caption: caption, label: rest.GetType(name.Type).Label})
+    }
+}
+
+func (v *searchView) draw(c *canvas) {
+    c.title = "Pass"
+
+    if v.folder != "" {
+        c.title = "Pass · " + v.folder
+    }
+
+    c.line("Search: %s_", string(v.query))
+
+    rows := c.rows() - 1
+
+    // Keep the selection on the screen.
+    if v.selected < v.offset {
+        v.offset = v.selected
+    }
+
+    if v.selected >= v.offset+rows {
+        v.offset = v.selected - rows + 1
+    }
+
+    if len(v.items) == 0 {
+        c.line("  No entries")
+    }
+
+    for i := v.offset; i < len(v.items) && i < v.offset+rows; i++ {
+        it := v.items[i]
+        width := c.width - len(it.label) - 3
+        text := "  " + fit(clean(it.caption), width) + " " + it.label
+
+        if i == v.selected {
+            c.styled(reverse, text)
+        } else {
+            c.line("%s", text)
+        }
+    }
+
+    c.help = "Type to search · ↑↓ select · Enter open · Esc clear · Ctrl-L lock · Ctrl-C quit"
+}
+
+func (v *searchView) move(delta int) {
+    v.selected += delta
+
+    if v.selected >= len(v.items) {
+        v.selected = len(v.items) - 1
+    }
+
+    if v.selected < 0 {
+        v.selected = 0
+    }
+}
+
+func (v *searchView) handle(k Key) {
+    switch k.Code {
+    case KeyRune:
+        v.query = append(v.query, k.Rune)
+        v.filter()
+    case KeyBackspace:
+        if len(v.query) > 0 {
+            v.query = v.query[:len(v.query)-1]
+            v.filter()
+        } else if v.folder != "" {
+            v.folder = rest.ParentFolder(v.folder)
+            v.filter()
+        }
+    case KeyEscape, KeyCtrlU:
+        v.query = nil
+        v.filter()
+    case KeyUp:
+        v.move(-1)
+    case KeyDown, KeyTab:
+        v.move(1)
+    case KeyPageUp:
+        v.move(-10)
+    case KeyPageDown:
+        v.move(10)
+    case KeyEnter:
+        if v.selected >= len(v.items) {
+            return
+        }
+
+        it := v.items[v.selected]
+
+        if it.name == nil {
+            v.folder = it.folder
+            v.query = nil
+            v.filter()
+            return
+        }
+
+        v.open(it.name)
+    }
+}
+
+// Shows the pane of an entry.
+func (v *searchView) open(name *rest.Name) {
+    d, err := v.ui.vault.VaultReadSecret(name)
+
+    if err != nil {
+        v.ui.fail(err)
+        return
+    }
+
+    v.ui.status = ""
+    p := pane{ui: v.ui, back: v, entry: d}
+
+    switch rest.GetType(d.Type).ID {
+    case rest.TypeOTP:
+        v.ui.view = &otpView{pane: p}
+    case rest.TypeFile:
+        v.ui.view = &fileView{pane: p}
+    case rest.TypeSign:
+        v.ui.view = &signView{pane: p}
+    default:
+        v.ui.view = &accountView{pane: p}
+    }
+}
@@ -0,0 +1,336 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package tui is a terminal front end to Pass, for SSH sessions and
+// other places without the desktop app. It draws with plain escape
+// sequences on a terminal in raw mode, and, like the desktop app, has
+// an unlock prompt, a search list which is filtered as one types, and
+// a pane for each kind of entry.
+//
+// Nothing an entry holds is written to the terminal as is: control
+// characters in names and notes are replaced, so that an entry cannot
+// send escape sequences to the terminal.
+package tui
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "pass/rest"
+    "pass/urlmatch"
+    "strings"
+    "time"
+    "unicode"
+)
+
+const (
+    // As written by the desktop app.
+    SignatureFileExtension = ".signature"
+
+    // OTP codes change every period.
+    otpPeriod = 30
+
+    // Switch to and from the alternate screen, hiding the cursor while
+    // we draw.
+    enterScreen = "\x1b[?1049h\x1b[?25l"
+    leaveScreen = "\x1b[?25h\x1b[?1049l"
+
+    reverse = "\x1b[7m"
+    bold    = "\x1b[1m"
+    normal  = "\x1b[0m"
+)
+
+var (
+    ErrNoKey  = errors.New("no key has been generated")
+    ErrNoPath = errors.New("no path given")
+)
+
+// A Vault holds the entries; it is implemented by rest.Client.
+type Vault interface {
+    VaultListSecrets() (*[]rest.Name, error)
+    VaultReadSecret(data *rest.Name) (*rest.DecodedEntry, error)
+    VaultWriteSecret(data *rest.DecodedEntry) error
+    SearchNotes(names []rest.Name, query string) (map[string]bool, error)
+    SearchURLs(names []rest.Name, page string, m *urlmatch.Matcher) (map[string]int, error)
+    Wipe()
+}
+
+// A view is what fills the screen: the unlock prompt, the search list
+// or the pane of an entry.
+type view interface {
+    draw(c *canvas)
+    handle(k Key)
+}
+
+// UI is the terminal front end. It is driven by Run, or by Handle and
+// Draw.
+type UI struct {
+    // Unlocks the vault with the master password.
+    Connect func(password string) (Vault, error)
+
+    // Ranks entries when searching for a URL.
+    Matcher *urlmatch.Matcher
+
+    // Called when the user locks, if set.
+    OnLock func()
+
+    // The clock, for the OTP countdown.
+    now func() time.Time
+
+    vault  Vault
+    view   view
+    status string
+    quit   bool
+}
+
+// New returns a locked UI.
+func New(connect func(password string) (Vault, error), m *urlmatch.Matcher) *UI {
+    u := &UI{
+        Connect: connect,
+        Matcher: m,
+        now:     time.Now,
+    }
+
+    u.view = &unlockView{ui: u}
+    return u
+}
+
+// Unlock shows the search list of an unlocked vault.
+func (u *UI) Unlock(v Vault) {
+    u.vault = v
+    u.status = ""
+
+    s := &searchView{ui: u}
+    s.load()
+    u.view = s
+}
+
+// Lock wipes the key and the token of the vault, forgets it and returns
+// to the unlock prompt.
+func (u *UI) Lock() {
+    if u.vault != nil {
+        u.vault.Wipe()
+    }
+
+    u.vault = nil
+    u.view = &unlockView{ui: u}
+    u.status = "Locked"
+
+    if u.OnLock != nil {
+        u.OnLock()
+    }
+}
+
+// Done tells if the user has quit.
+func (u *UI) Done() bool {
+    return u.quit
+}
+
+// Shows an error, or a message, in the status line.
+func (u *UI) fail(err error) {
+    u.status = err.Error()
+}
+
+// Handle reacts to a key.
+func (u *UI) Handle(k Key) {
+    switch {
+    case k.Code == KeyCtrlC:
+        u.quit = true
+    case k.Code == KeyCtrlL && u.vault != nil:
+        u.Lock()
+    default:
+        u.view.handle(k)
+    }
+}
+
+// Draw writes the whole screen, of the given size, at once.
+func (u *UI) Draw(w io.Writer, width int, height int) error {
+    c := &canvas{width: width, height: height}
+    u.view.draw(c)
+
+    var buf bytes.Buffer
+    buf.WriteString("\x1b[H")
+
+    for i, line := range c.frame(u.status) {
+        if i > 0 {
+            buf.WriteString("\r\n")
+        }
+        buf.WriteString(line)
+        buf.WriteString("\x1b[K")
+    }
+
+    buf.WriteString("\x1b[J")
+    _, err := w.Write(buf.Bytes())
+    return err
+}
+
+// Run reads keys from in, a terminal in raw mode, and draws on out
+// until the user quits. The screen is redrawn every second, for the
+// OTP countdown and in case the terminal has been resized; size
+// returns its current size.
+func (u *UI) Run(in io.Reader, out io.Writer, size func() (int, int)) error {
+    keys := make(chan []Key)
+    errs := make(chan error, 1)
+
+    go func() {
+        buf := make([]byte, 256)
+
+        for {
+            n, err := in.Read(buf)
+
+            if n > 0 {
+                keys <- ParseKeys(buf[:n])
+            }
+
+            if err != nil {
+                errs <- err
+                return
+            }
+        }
+    }()
+
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+
+    io.WriteString(out, enterScreen)
+    defer io.WriteString(out, leaveScreen)
+
+    for !u.quit {
+        width, height := size()
+
+        if err := u.Draw(out, width, height); err != nil {
+            return err
+        }
+
+        select {
+        case pressed := <-keys:
+            for _, k := range pressed {
+                u.Handle(k)
+            }
+        case err := <-errs:
+            if err == io.EOF {
+                return nil
+            }
+            return err
+        case <-ticker.C:
+        }
+    }
+
+    return nil
+}
+
+// A canvas collects the lines of a view: a title, the body and a line
+// of help. The status line goes below.
+type canvas struct {
+    width  int
+    height int
+    title  string
+    body   []string
+    help   string
+
+    // Replaces the help line while something is typed into it.
+    prompt string
+}
+
+// Replaces control characters, so that text from an entry cannot
+// control the terminal.
+func clean(s string) string {
+    return strings.Map(func(r rune) rune {
+        if unicode.IsControl(r) {
+            return '?'
+        }
+        return r
+    }, s)
+}
+
+// Cuts or pads a line to the given width.
+func fit(s string, width int) string {
+    runes := []rune(s)
+
+    if len(runes) > width {
+        return string(runes[:width])
+    }
+
+    return s + strings.Repeat(" ", width-len(runes))
+}
+
+// Adds a line of text to the body.
+func (c *canvas) line(format string, args ...interface{}) {
+    c.body = append(c.body, clean(fmt.Sprintf(format, args...)))
+}
+
+// Adds a line in the given style, filling the width.
+func (c *canvas) styled(style string, s string) {
+    c.body = append(c.body, style+fit(clean(s), c.width)+normal)
+}
+
+// Adds a field of an entry: a label and its value.
+func (c *canvas) field(label string, value string) {
+    c.line("%-10s %s", label, value)
+}
+
+// Number of body lines which fit on the screen.
+func (c *canvas) rows() int {
+    if rows := c.height - 4; rows > 0 {
+        return rows
+    }
+    return 1
+}
+
+// Returns the lines of the screen.
+func (c *canvas) frame(status string) []string {
+    lines := []string{reverse + fit(" "+clean(c.title), c.width) + normal, ""}
+
+    for i, line := range c.body {
+        if i == c.rows() {
+            break
+        }
+
+        // Styled lines are already cut to the width.
+        if !strings.HasPrefix(line, "\x1b") {
+            line = fit(line, c.width)
+        }
+
+        lines = append(lines, line)
+    }
+
+    for len(lines) < c.height-2 {
+        lines = append(lines, "")
+    }
+
+    bottom := c.help
+
+    if c.prompt != "" {
+        bottom = c.prompt
+    }
+
+    return append(lines, bold+fit(clean(bottom), c.width)+normal, fit(clean(status), c.width))
+}
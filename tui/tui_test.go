@@ -0,0 +1,355 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package tui
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "golang.org/x/crypto/ed25519"
+    "io/ioutil"
+    "os"
+    "pass/lock"
+    "pass/rest"
+    "pass/urlmatch"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+type fakeVault struct {
+    entries []*rest.DecodedEntry
+    writes  int
+    wiped   bool
+}
+
+func (f *fakeVault) add(d *rest.DecodedEntry) {
+    d.Name.Encrypted = fmt.Sprintf("key%d", len(f.entries))
+    d.Name.Type = d.Type
+    f.entries = append(f.entries, d)
+}
+
+func (f *fakeVault) VaultListSecrets() (*[]rest.Name, error) {
+    names := []rest.Name{}
+
+    for _, d := range f.entries {
+        names = append(names, *d.Name)
+    }
+
+    return &names, nil
+}
+
+func (f *fakeVault) VaultReadSecret(n *rest.Name) (*rest.DecodedEntry, error) {
+    for _, d := range f.entries {
+        if d.Name.Encrypted == n.Encrypted {
+            return d, nil
+        }
+    }
+
+    return nil, rest.ErrNotFound
+}
+
+func (f *fakeVault) VaultWriteSecret(d *rest.DecodedEntry) error {
+    f.writes++
+    return d.Validate()
+}
+
+func (f *fakeVault) SearchNotes(names []rest.Name, query string) (map[string]bool, error) {
+    matches := map[string]bool{}
+
+    for _, d := range f.entries {
+        if note, ok := d.Payload.(*rest.Note); ok && query != "" && rest.MatchText(note.Text, query) {
+            matches[d.Name.Encrypted] = true
+        }
+    }
+
+    return matches, nil
+}
+
+func (f *fakeVault) SearchURLs(names []rest.Name, page string, m *urlmatch.Matcher) (map[string]int, error) {
+    scores := map[string]int{}
+    u, _ := urlmatch.Parse(page)
+
+    for _, d := range f.entries {
+        if score, _ := m.Match(d.Target(), u); score > 0 {
+            scores[d.Name.Encrypted] = score
+        }
+    }
+
+    return scores, nil
+}
+
+func (f *fakeVault) Wipe() {
+    f.wiped = true
+}
+
+func newVault() *fakeVault {
+    v := &fakeVault{}
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "Work/github"}, Username: "carl",
+        Password: lock.SecureCopy([]byte("hunter2")), URLs: []string{"https://github.com"}})
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "bank"}, Username: "carl", Password: lock.SecureCopy([]byte("swordfish")),
+        Notes: "PIN\x1b]0;pwned\x07"})
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "shopping"}, Type: rest.TypeNote,
+        Payload: &rest.Note{Text: "milk and eggs"}})
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "google"}, Type: rest.TypeOTP, Password: lock.SecureCopy([]byte("JBSWY3DPEHPK3PXP"))})
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "Work/report.pdf"}, Type: rest.TypeFile, File: []byte("report")})
+    v.add(&rest.DecodedEntry{Name: &rest.Name{Text: "release"}, Type: rest.TypeSign})
+    return v
+}
+
+var errPassword = errors.New("wrong password")
+
+// Returns a UI unlocked with the fake vault.
+func unlocked(t *testing.T, v *fakeVault) *UI {
+    u := New(func(password string) (Vault, error) {
+        if password != "banana" {
+            return nil, errPassword
+        }
+        return v, nil
+    }, urlmatch.NewMatcher(nil))
+
+    typeText(u, "banana\r")
+    return u
+}
+
+func typeText(u *UI, text string) {
+    for _, k := range ParseKeys([]byte(text)) {
+        u.Handle(k)
+    }
+}
+
+func screen(t *testing.T, u *UI) string {
+    var buf bytes.Buffer
+
+    if err := u.Draw(&buf, 80, 24); err != nil {
+        t.Fatal(err)
+    }
+
+    return buf.String()
+}
+
+func TestUnlockAndLock(t *testing.T) {
+    v := newVault()
+    locked := false
+    u := New(func(password string) (Vault, error) {
+        if password != "banana" {
+            return nil, errPassword
+        }
+        return v, nil
+    }, urlmatch.NewMatcher(nil))
+    u.OnLock = func() { locked = true }
+
+    typeText(u, "apple\r")
+
+    if s := screen(t, u); !strings.Contains(s, errPassword.Error()) || strings.Contains(s, "apple") {
+        t.Errorf("Wrong password not reported, or shown: %q", s)
+    }
+
+    typeText(u, "bananax\x7f\r")
+
+    if s := screen(t, u); !strings.Contains(s, "bank") || strings.Contains(s, "banana") {
+        t.Errorf("Not unlocked: %q", s)
+    }
+
+    typeText(u, "\x0c")
+
+    if s := screen(t, u); !locked || !strings.Contains(s, "Password:") || strings.Contains(s, "bank") {
+        t.Errorf("Not locked: %q", s)
+    }
+
+    if !v.wiped {
+        t.Errorf("Vault not wiped when locking")
+    }
+
+    // Locking from the unlock prompt does nothing.
+    locked = false
+    typeText(u, "\x0c")
+
+    if locked {
+        t.Errorf("Locked twice")
+    }
+
+    if typeText(u, "\x03"); !u.Done() {
+        t.Errorf("Did not quit")
+    }
+}
+
+func TestSearch(t *testing.T) {
+    u := unlocked(t, newVault())
+    s := screen(t, u)
+
+    // The root folder shows the folder Work, but not what is in it.
+    if !strings.Contains(s, "Work") || strings.Contains(s, "github") || !strings.Contains(s, "shopping") {
+        t.Errorf("Unexpected root folder: %q", s)
+    }
+
+    // Into the folder, and back out.
+    typeText(u, "\r")
+
+    if s = screen(t, u); !strings.Contains(s, "Pass · Work") || !strings.Contains(s, "github") {
+        t.Errorf("Did not enter the folder: %q", s)
+    }
+
+    typeText(u, "\x7f")
+
+    if s = screen(t, u); strings.Contains(s, "github") {
+        t.Errorf("Did not leave the folder: %q", s)
+    }
+
+    // A query searches all folders, and the text of notes.
+    for query, expected := range map[string]string{
+        "GIT":        "Work/github",
+        "eggs":       "shopping",
+        "github.com": "Work/github",
+    } {
+        typeText(u, "\x1b"+query)
+        s = screen(t, u)
+
+        if !strings.Contains(s, expected) || strings.Contains(s, "bank") {
+            t.Errorf("%s: unexpected result %q", query, s)
+        }
+    }
+
+    typeText(u, "\x1bnothing")
+
+    if s = screen(t, u); !strings.Contains(s, "No entries") {
+        t.Errorf("Found something: %q", s)
+    }
+}
+
+func TestAccount(t *testing.T) {
+    u := unlocked(t, newVault())
+    typeText(u, "bank\r")
+    s := screen(t, u)
+
+    if !strings.Contains(s, "carl") || strings.Contains(s, "swordfish") {
+        t.Errorf("Password shown before revealing: %q", s)
+    }
+
+    // Control characters in the notes do not reach the terminal.
+    if strings.Contains(s, "\x1b]") || strings.Contains(s, "\x07") || !strings.Contains(s, "PIN?]0;pwned?") {
+        t.Errorf("Control characters not replaced: %q", s)
+    }
+
+    typeText(u, "r")
+
+    if s = screen(t, u); !strings.Contains(s, "swordfish") {
+        t.Errorf("Password not revealed: %q", s)
+    }
+
+    typeText(u, "q")
+
+    if s = screen(t, u); !strings.Contains(s, "Search: bank") {
+        t.Errorf("Did not go back: %q", s)
+    }
+}
+
+func TestOTPCountdown(t *testing.T) {
+    u := unlocked(t, newVault())
+    u.now = func() time.Time { return time.Unix(1000000040, 0) }
+    typeText(u, "google\r")
+
+    if s := screen(t, u); !strings.Contains(s, " 10s") || !strings.Contains(s, strings.Repeat("█", 10)+"·") {
+        t.Errorf("Unexpected countdown: %q", s)
+    }
+}
+
+func TestSaveFile(t *testing.T) {
+    dir, err := ioutil.TempDir("", "tui")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "report.pdf")
+    u := unlocked(t, newVault())
+    typeText(u, "report\rs\x15"+path+"\r")
+
+    data, err := ioutil.ReadFile(path)
+
+    if err != nil || string(data) != "report" {
+        t.Fatalf("File not saved: %v", err)
+    }
+
+    if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+        t.Errorf("File may be read by others: %v", info.Mode())
+    }
+
+    // An existing file is not replaced.
+    typeText(u, "s\x15"+path+"\r")
+
+    if s := screen(t, u); !strings.Contains(s, "exists") {
+        t.Errorf("Replaced the file: %q", s)
+    }
+}
+
+func TestSign(t *testing.T) {
+    dir, err := ioutil.TempDir("", "tui")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "release.tar.gz")
+    ioutil.WriteFile(path, []byte("data"), 0600)
+
+    v := newVault()
+    u := unlocked(t, v)
+    typeText(u, "release\rs"+path+"\r")
+
+    if s := screen(t, u); !strings.Contains(s, ErrNoKey.Error()) {
+        t.Errorf("Signed without a key: %q", s)
+    }
+
+    typeText(u, "g")
+    k, ok := v.entries[5].Payload.(*rest.KeyPair)
+
+    if !ok || v.writes != 1 {
+        t.Fatalf("No key was generated")
+    }
+
+    typeText(u, "s"+path+"\r")
+    signature, err := ioutil.ReadFile(path + SignatureFileExtension)
+
+    if err != nil || !ed25519.Verify(ed25519.PublicKey(k.Pub), []byte("data"), signature) {
+        t.Errorf("Invalid signature: %v", err)
+    }
+
+    // A key is only generated once.
+    if typeText(u, "g"); v.writes != 1 {
+        t.Errorf("Key replaced")
+    }
+}
@@ -31,12 +31,11 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package main
 
 import (
-    "encoding/hex"
     "github.com/murlokswarm/app"
-    "log"
-    "pass/lock"
+    "pass/logger"
+    "pass/observe"
     "pass/rest"
-    "pass/util"
+    "pass/vault"
 )
 
 type UnlockScreen struct{}
@@ -44,7 +43,7 @@ type UnlockScreen struct{}
 var restClient rest.Client
 
 func (h *UnlockScreen) OnDismount() {
-    log.Println("UnlockScreen dismounted")
+    logger.Debug("UnlockScreen dismounted")
 }
 
 func (h *UnlockScreen) Render() string {
@@ -69,47 +68,90 @@ func (h *UnlockScreen) Render() string {
 }
 
 func (h *UnlockScreen) Unlock(arg app.ChangeArg) {
-    // Get the password from user input.
-    password := arg.Value
-    salt, _ := hex.DecodeString(config.Encrypted.Salt)
-
-    // Verify password against encrypted token + mac.
-    lock := lock.New(password, salt)
-    _, err := lock.UnlockToken(config.Encrypted.Token)
+    // Verify the password from user input against the encrypted
+    // token and setup the client for communication.
+    client, err := vault.Connect(config, arg.Value)
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
-    log.Println("Unlocked.")
+    // Let the command line tools use the session, if an agent runs.
+    if err = vault.Share(config, client); err != nil {
+        logger.Warn("Could not unlock the agent:", err)
+    }
+
+    unlocked(client)
+}
+
+// unlocked sets up the client and shows the entries.
+func unlocked(client *rest.Client) {
+    logger.Info("Unlocked.")
 
     // Signal to UI that the token was unlocked.
     pass.Locked = false
 
-    // Setup the client for communication.
-    restClient = rest.New(&lock)
-    restClient.Init(config.Host, config.Port, config.CA)
-    restClient.Unlock(config.Encrypted.Token)
+    restClient = *client
+
+    // Requests to Vault are what keeps the session from timing out.
+    restClient.Observer = observe.Multi{observer, activity{}}
+    autoLock.Start()
 
     // Fetch the data from server.
-    log.Println("Fetching data.")
+    logger.Debug("Fetching data.")
     r, err := restClient.VaultListSecrets()
 
     if err != nil {
-        log.Println(err)
+        logger.Error(err)
         return
     }
 
-    // Clear config to free up memory.
-    config = util.Configuration{}
+    // Offer the SSH keys through the agent, if configured. The
+    // configuration is kept, so that Pass can be unlocked again
+    // after being locked; its token is encrypted.
+    startSSHAgent()
+    loadSSHKeys(*r)
 
     // Mount search window.
-    log.Println("OK", r)
+    logger.Debug("Fetched", len(*r), "entries")
     ps := &Search{Result: *r}
     win.Mount(ps)
+}
+
+// Lock stops the jobs in the background, wipes the key, forgets the
+// token and what has been decrypted, and removes the SSH keys from the
+// agent. The session is shared, so
+// the unlock agent is locked as well. The user has to enter the
+// password again.
+func Lock() {
+    pass.Locked = true
+    autoLock.Stop()
+
+    stopBackground()
+    restClient.Wipe()
+    restClient = rest.Client{}
+
+    // The private key of the signing entry last shown.
+    for i := range keyPair.Priv {
+        keyPair.Priv[i] = 0
+    }
+
+    keyPair = KeyPair{}
+
+    if sshAgent != nil {
+        sshAgent.Clear()
+    }
 
-    //app.Render(h)
+    if err := vault.LockAgent(config); err != nil {
+        logger.Warn("Could not lock the agent:", err)
+    }
+
+    logger.Info("Locked.")
+
+    if win != nil {
+        win.Mount(&UnlockScreen{})
+    }
 }
 
 func init() {
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+    "pass/logger"
+    "pass/rest"
+)
+
+// UpgradeVault brings all stored entries up to date with the current
+// settings, i.e., upgrades them to the current schema, moves them to
+// their blind index if enabled and re-pads them with the configured
+// padding policy. It runs in the background, since it touches every
+// entry, with a client of its own, as the views keep using theirs.
+func UpgradeVault() {
+    if pass.Locked {
+        return
+    }
+
+    // Entries may have moved, so the names, notes and URLs cached by
+    // the views are stale afterwards.
+    runInBackground(upgrade, func() {
+        if !pass.Locked {
+            restClient.Invalidate()
+        }
+    })
+}
+
+func upgrade(client *rest.Client) {
+    logger.Info("Upgrading vault.")
+
+    n, err := client.MigrateSchema()
+
+    if err != nil {
+        logger.Error("Schema migration failed:", err)
+        return
+    }
+
+    logger.Info("Upgraded", n, "entries to schema version", rest.SchemaVersion)
+
+    if client.BlindIndex {
+        n, err = client.MigrateBlindIndex()
+
+        if err != nil {
+            logger.Error("Blind index migration failed:", err)
+            return
+        }
+
+        logger.Info("Moved", n, "entries to their blind index.")
+    }
+
+    n, err = client.Repad()
+
+    if err != nil {
+        logger.Error("Re-padding failed:", err)
+        return
+    }
+
+    logger.Info("Re-padded", n, "entries.")
+}
@@ -0,0 +1,436 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package urlmatch finds entries by URL, for filling in logins and for
+// the credential helpers. URLs are normalized and compared by their
+// registrable domain (eTLD+1, from the public suffix list), unless an
+// entry asks for a stricter match. Groups of equivalent domains, such
+// as google.com and youtube.com, share their logins.
+package urlmatch
+
+import (
+    "errors"
+    "golang.org/x/net/idna"
+    "golang.org/x/net/publicsuffix"
+    "net"
+    "net/url"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Match modes of an entry. The mode decides how the URLs of the entry
+// are compared to the URL of a page.
+const (
+    // Same registrable domain, e.g., login.example.com for
+    // www.example.com. The default.
+    ModeDomain = "domain"
+
+    // Same host and port.
+    ModeHost = "host"
+
+    // The page URL starts with the URL of the entry.
+    ModeStartsWith = "startswith"
+
+    // The URL of the entry is a regular expression which the whole page
+    // URL must match. Such a match ranks with ScoreDomain, as a pattern
+    // may cover more than one host.
+    ModeRegex = "regex"
+)
+
+// Scores of a match, from worst to best. Zero means no match.
+const (
+    ScoreNone = iota
+    ScoreEquivalent
+    ScoreDomain
+    ScoreHost
+    ScoreExact
+)
+
+var (
+    ErrMode = errors.New("unknown match mode")
+    ErrURL  = errors.New("invalid URL")
+)
+
+// DefaultEquivalents are groups of domains belonging to the same
+// accounts.
+var DefaultEquivalents = [][]string{
+    {"google.com", "youtube.com", "gmail.com"},
+    {"apple.com", "icloud.com"},
+    {"microsoft.com", "live.com", "outlook.com", "office.com", "microsoftonline.com"},
+    {"amazon.com", "amazon.co.uk", "amazon.de", "amazon.fr", "amazon.se", "amazon.ca"},
+    {"atlassian.com", "atlassian.net", "bitbucket.org"},
+}
+
+// A URL is a normalized URL: lowercase scheme and host, without default
+// port, trailing dot and fragment, and with an internationalized host
+// in its ASCII form.
+type URL struct {
+    Scheme string
+    Host   string
+    Port   string
+    Path   string
+    Query  string
+
+    // The URL was given without a scheme, as often stored in entries.
+    // It then stands for both http and https.
+    AnyScheme bool
+}
+
+// Parse normalizes a URL. A URL without a scheme is taken to be https.
+func Parse(s string) (*URL, error) {
+    s = strings.TrimSpace(s)
+    anyScheme := !strings.Contains(s, "://")
+
+    if anyScheme {
+        s = "https://" + s
+    }
+
+    u, err := url.Parse(s)
+
+    if err != nil || u.Hostname() == "" {
+        return nil, ErrURL
+    }
+
+    host, err := idna.Lookup.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+
+    if err != nil {
+        return nil, ErrURL
+    }
+
+    n := &URL{
+        Scheme:    strings.ToLower(u.Scheme),
+        Host:      strings.ToLower(host),
+        Port:      u.Port(),
+        Path:      u.EscapedPath(),
+        Query:     u.RawQuery,
+        AnyScheme: anyScheme,
+    }
+
+    if (n.Scheme == "https" && n.Port == "443") || (n.Scheme == "http" && n.Port == "80") {
+        n.Port = ""
+    }
+
+    if n.Path == "" {
+        n.Path = "/"
+    }
+
+    return n, nil
+}
+
+// HostPort is the host with the port, if not the default one.
+func (u *URL) HostPort() string {
+    if u.Port == "" {
+        return u.Host
+    }
+
+    return net.JoinHostPort(u.Host, u.Port)
+}
+
+// Origin is scheme://host[:port].
+func (u *URL) Origin() string {
+    return u.Scheme + "://" + u.HostPort()
+}
+
+func (u *URL) String() string {
+    s := u.Origin() + u.Path
+
+    if u.Query != "" {
+        s += "?" + u.Query
+    }
+
+    return s
+}
+
+// Domain is the registrable domain of the host, e.g., example.co.uk for
+// www.example.co.uk. IP addresses, single labels such as localhost and
+// hosts which are a public suffix themselves, e.g., github.io, are
+// their own domain.
+func (u *URL) Domain() string {
+    return Domain(u.Host)
+}
+
+// Domain returns the registrable domain of a host.
+func Domain(host string) string {
+    if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+        return host
+    }
+
+    domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+
+    if err != nil {
+        return host
+    }
+
+    return domain
+}
+
+// LooksLikeURL tells whether a search query is probably a URL or a
+// host, rather than a name.
+func LooksLikeURL(s string) bool {
+    if s == "" || strings.ContainsAny(s, " \t\n") {
+        return false
+    }
+
+    if strings.Contains(s, "://") {
+        return true
+    }
+
+    u, err := Parse(s)
+
+    if err != nil || !strings.Contains(u.Host, ".") {
+        return false
+    }
+
+    _, icann := publicsuffix.PublicSuffix(u.Host)
+    return icann
+}
+
+// ValidateMode checks that the mode is known and that the URLs fit it:
+// patterns compile for ModeRegex, and are URLs for the other modes.
+func ValidateMode(mode string, urls []string) error {
+    switch mode {
+    case "", ModeDomain, ModeHost, ModeStartsWith:
+        for _, s := range urls {
+            if _, err := Parse(s); err != nil {
+                return err
+            }
+        }
+    case ModeRegex:
+        for _, s := range urls {
+            if _, err := regexp.Compile(s); err != nil {
+                return err
+            }
+        }
+    default:
+        return ErrMode
+    }
+
+    return nil
+}
+
+// A Matcher compares URLs. It is safe for concurrent use.
+type Matcher struct {
+    // Domain to the index of its group of equivalent domains.
+    groups map[string]int
+
+    mu       sync.Mutex
+    patterns map[string]*regexp.Regexp
+}
+
+// NewMatcher returns a matcher with the groups of equivalent domains.
+func NewMatcher(equivalents [][]string) *Matcher {
+    m := &Matcher{
+        groups:   make(map[string]int),
+        patterns: make(map[string]*regexp.Regexp),
+    }
+
+    for i, group := range equivalents {
+        for _, domain := range group {
+            m.groups[Domain(strings.ToLower(domain))] = i + 1
+        }
+    }
+
+    return m
+}
+
+// Equivalent reports whether two registrable domains belong to the
+// same group.
+func (m *Matcher) Equivalent(a string, b string) bool {
+    i, ok := m.groups[a]
+    return ok && i == m.groups[b]
+}
+
+func (m *Matcher) pattern(s string) *regexp.Regexp {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    re, ok := m.patterns[s]
+
+    if !ok {
+        // An invalid pattern is cached as nil and never matches. The
+        // pattern is anchored, so that github\.com does not match
+        // https://evil.com/?github.com.
+        re, _ = regexp.Compile("^(?:" + s + ")$")
+        m.patterns[s] = re
+    }
+
+    return re
+}
+
+// A page served over http must not get logins meant for https; the
+// other way round is fine.
+func schemeMatches(entry *URL, page *URL) bool {
+    if entry.AnyScheme {
+        return page.Scheme == "http" || page.Scheme == "https"
+    }
+
+    return entry.Scheme == page.Scheme || (entry.Scheme == "http" && page.Scheme == "https")
+}
+
+// Whether the path of the page is the path of the entry or below it.
+func pathMatches(entry *URL, page *URL) bool {
+    if entry.Path == "/" || entry.Path == page.Path {
+        return true
+    }
+
+    return strings.HasPrefix(page.Path, strings.TrimSuffix(entry.Path, "/")+"/")
+}
+
+// Score compares a URL of an entry to the URL of a page.
+func (m *Matcher) Score(mode string, entryURL string, page *URL) int {
+    if mode == ModeRegex {
+        if re := m.pattern(entryURL); re != nil && re.MatchString(page.String()) {
+            return ScoreDomain
+        }
+        return ScoreNone
+    }
+
+    entry, err := Parse(entryURL)
+
+    if err != nil || !schemeMatches(entry, page) {
+        return ScoreNone
+    }
+
+    sameHost := entry.HostPort() == page.HostPort()
+    exact := sameHost && entry.Path == page.Path && (entry.Query == "" || entry.Query == page.Query)
+
+    switch mode {
+    case ModeStartsWith:
+        if !sameHost || !strings.HasPrefix(page.Path+"?"+page.Query, entry.Path+queryPart(entry)) {
+            return ScoreNone
+        }
+    case ModeHost:
+        if !sameHost {
+            return ScoreNone
+        }
+    case "", ModeDomain:
+        switch {
+        case sameHost || entry.Domain() == page.Domain():
+        case m.Equivalent(entry.Domain(), page.Domain()):
+            return ScoreEquivalent
+        default:
+            return ScoreNone
+        }
+    default:
+        return ScoreNone
+    }
+
+    switch {
+    case exact:
+        return ScoreExact
+    case sameHost:
+        return ScoreHost
+    }
+
+    return ScoreDomain
+}
+
+// The query of a prefix, if it has one; a prefix without a query
+// matches any query.
+func queryPart(u *URL) string {
+    if u.Query == "" {
+        return ""
+    }
+
+    return "?" + u.Query
+}
+
+// A Target is what is matched of an entry: its URLs and match mode.
+type Target struct {
+    URLs []string
+    Mode string
+}
+
+// A Candidate is a target matching a page.
+type Candidate struct {
+    // Index of the target.
+    Index int
+    Score int
+
+    // Length of the path of the matching URL, if the page is below it,
+    // so that of several logins for a site the one for the page comes
+    // first.
+    Specificity int
+}
+
+// Match returns the best score of the URLs of a target for the page and
+// its specificity.
+func (m *Matcher) Match(t Target, page *URL) (score int, specificity int) {
+    for _, s := range t.URLs {
+        sc := m.Score(t.Mode, s, page)
+
+        if sc == ScoreNone {
+            continue
+        }
+
+        sp := 0
+
+        if entry, err := Parse(s); t.Mode != ModeRegex && err == nil && pathMatches(entry, page) {
+            sp = len(strings.TrimSuffix(entry.Path, "/"))
+        }
+
+        if sc > score || (sc == score && sp > specificity) {
+            score, specificity = sc, sp
+        }
+    }
+
+    return score, specificity
+}
+
+// Rank returns the targets matching the page, best first.
+func (m *Matcher) Rank(page string, targets []Target) ([]Candidate, error) {
+    u, err := Parse(page)
+
+    if err != nil {
+        return nil, err
+    }
+
+    var candidates []Candidate
+
+    for i, t := range targets {
+        if score, specificity := m.Match(t, u); score != ScoreNone {
+            candidates = append(candidates, Candidate{i, score, specificity})
+        }
+    }
+
+    sort.SliceStable(candidates, func(i, j int) bool {
+        a, b := candidates[i], candidates[j]
+
+        if a.Score != b.Score {
+            return a.Score > b.Score
+        }
+
+        return a.Specificity > b.Specificity
+    })
+
+    return candidates, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package urlmatch
+
+import (
+    "testing"
+)
+
+func TestParse(t *testing.T) {
+    for s, expected := range map[string]string{
+        "https://GitHub.com/login?x=1#top": "https://github.com/login?x=1",
+        "github.com":                       "https://github.com/",
+        "https://example.com:443":          "https://example.com/",
+        "http://example.com:8080/a":        "http://example.com:8080/a",
+        "https://bücher.de./shop":          "https://xn--bcher-kva.de/shop",
+    } {
+        if u, err := Parse(s); err != nil || u.String() != expected {
+            t.Errorf("%s is normalized to %v, not %s: %v", s, u, expected, err)
+        }
+    }
+
+    if _, err := Parse("https://"); err != ErrURL {
+        t.Errorf("URL without host accepted")
+    }
+}
+
+func TestDomain(t *testing.T) {
+    for host, expected := range map[string]string{
+        "www.example.co.uk": "example.co.uk",
+        "login.example.com": "example.com",
+        "carl.github.io":    "carl.github.io",
+        "github.io":         "github.io",
+        "localhost":         "localhost",
+        "192.168.1.1":       "192.168.1.1",
+    } {
+        if d := Domain(host); d != expected {
+            t.Errorf("Domain of %s is %s, not %s", host, d, expected)
+        }
+    }
+}
+
+func TestLooksLikeURL(t *testing.T) {
+    for s, expected := range map[string]bool{
+        "github.com":         true,
+        "https://intranet":   true,
+        "login.example.co.uk": true,
+        "github":             false,
+        "notes.txt":          false,
+        "work email":         false,
+        "":                   false,
+    } {
+        if LooksLikeURL(s) != expected {
+            t.Errorf("LooksLikeURL(%q) is not %v", s, expected)
+        }
+    }
+}
+
+func TestScore(t *testing.T) {
+    m := NewMatcher(DefaultEquivalents)
+    page, _ := Parse("https://accounts.google.com/signin?continue=mail")
+
+    for _, c := range []struct {
+        mode     string
+        entry    string
+        expected int
+    }{
+        {"", "https://accounts.google.com/signin", ScoreExact},
+        {ModeDomain, "accounts.google.com", ScoreHost},
+        {ModeDomain, "https://google.com", ScoreDomain},
+        {ModeDomain, "http://google.com", ScoreDomain},
+        {ModeDomain, "https://youtube.com", ScoreEquivalent},
+        {ModeDomain, "https://google.com.evil.com", ScoreNone},
+        {ModeDomain, "ftp://google.com", ScoreNone},
+        {ModeHost, "https://google.com", ScoreNone},
+        {ModeHost, "https://accounts.google.com:8443", ScoreNone},
+        {ModeHost, "https://accounts.google.com/other", ScoreHost},
+        {ModeStartsWith, "https://accounts.google.com/sign", ScoreHost},
+        {ModeStartsWith, "https://accounts.google.com/signin?continue=mail", ScoreExact},
+        {ModeStartsWith, "https://accounts.google.com/signup", ScoreNone},
+        {ModeRegex, `https://[a-z]+\.google\.com/.*`, ScoreDomain},
+        {ModeRegex, `^https://[a-z]+\.google\.com/.*$`, ScoreDomain},
+        {ModeRegex, `https://[a-z]+\.google\.com/`, ScoreNone},
+        {ModeRegex, `accounts\.google\.com`, ScoreNone},
+        {ModeRegex, `^https://google\.com/`, ScoreNone},
+        {ModeRegex, `(`, ScoreNone},
+        {"fuzzy", "https://accounts.google.com", ScoreNone},
+    } {
+        if score := m.Score(c.mode, c.entry, page); score != c.expected {
+            t.Errorf("%s %s scores %d, not %d", c.mode, c.entry, score, c.expected)
+        }
+    }
+
+    // Logins for https are not handed to pages served over http.
+    insecure, _ := Parse("http://accounts.google.com")
+
+    if m.Score(ModeDomain, "https://accounts.google.com", insecure) != ScoreNone {
+        t.Errorf("Login for https matches http")
+    }
+
+    // Patterns match the whole URL, not a part of it.
+    evil, _ := Parse("https://evil.com/?github.com")
+
+    if m.Score(ModeRegex, `github\.com`, evil) != ScoreNone {
+        t.Errorf("Pattern matches part of a URL")
+    }
+}
+
+func TestRank(t *testing.T) {
+    m := NewMatcher([][]string{{"example.com", "example.net"}})
+    targets := []Target{
+        {URLs: []string{"https://example.net"}},
+        {URLs: []string{"https://example.com"}},
+        {URLs: []string{"https://other.com"}},
+        {URLs: []string{"https://www.example.com/admin"}},
+        {URLs: []string{"https://www.example.com", "https://www.example.com/admin/users"}},
+    }
+
+    candidates, err := m.Rank("https://www.example.com/admin/users/1", targets)
+
+    if err != nil || len(candidates) != 4 {
+        t.Fatalf("Unexpected candidates %v: %v", candidates, err)
+    }
+
+    // Same host, the deeper path first, then the domain and last the
+    // equivalent domain.
+    for i, index := range []int{4, 3, 1, 0} {
+        if candidates[i].Index != index {
+            t.Errorf("Candidate %d is %v, not target %d", i, candidates[i], index)
+        }
+    }
+
+    if _, err = m.Rank("https://", targets); err != ErrURL {
+        t.Errorf("Ranked for an invalid URL")
+    }
+}
+
+func TestValidateMode(t *testing.T) {
+    if ValidateMode(ModeRegex, []string{`^https://.*\.example\.com/`}) != nil {
+        t.Errorf("Pattern not accepted")
+    }
+
+    if ValidateMode(ModeRegex, []string{`(`}) == nil {
+        t.Errorf("Invalid pattern accepted")
+    }
+
+    if ValidateMode(ModeHost, []string{"https://"}) != ErrURL {
+        t.Errorf("Invalid URL accepted")
+    }
+
+    if ValidateMode("fuzzy", nil) != ErrMode {
+        t.Errorf("Unknown mode accepted")
+    }
+}
@@ -32,63 +32,165 @@ package util
 
 import (
     "encoding/json"
+    "fmt"
     "io/ioutil"
-    "log"
     "os"
+    "pass/urlmatch"
     "path/filepath"
     "strings"
 )
 
+// Ways to unlock the vault.
+const (
+    KeyMethodPassword = "password"
+)
+
+// WrappedKey is the data key, wrapped with the key of one way to
+// unlock the vault. The password uses the salt of the configuration;
+// other ways may have a salt of their own.
+type WrappedKey struct {
+    Method string `json:"method"`
+    Salt   string `json:"salt,omitempty"`
+    Key    string `json:"key"`
+}
+
 type Configuration struct {
+    // The token is encrypted with the data key, and the data key is
+    // wrapped with the key derived from the password and the salt. In
+    // configurations without keys, entries and token are encrypted
+    // with the derived key itself.
     Encrypted struct {
-        Token string `json:"token"`
-        Salt  string `json:"salt"`
+        Token string       `json:"token"`
+        Salt  string       `json:"salt"`
+        Keys  []WrappedKey `json:"keys,omitempty"`
     } `json:"encrypted"`
 
     Host string `json:"host"`
     Port int    `json:"port"`
     CA   string `json:"ca"`
+
+    // Optional observability. Listen is a local address for the
+    // Prometheus exporter, e.g., "unix:/tmp/pass.sock" or
+    // "127.0.0.1:9123", and Trace a file to which spans are appended.
+    Metrics struct {
+        Listen string `json:"listen"`
+        Trace  string `json:"trace"`
+    } `json:"metrics"`
+
+    // Padding policy for stored entries: minimum, pow2 or padme.
+    // Defaults to padme.
+    Padding string `json:"padding"`
+
+    // Store new entries under a blind index of their name, rather
+    // than under an encrypted name.
+    BlindIndex bool `json:"blindindex"`
+
+    // One of debug, info, warn or error. Defaults to info.
+    LogLevel string `json:"loglevel"`
+
+    // Optional ssh-agent serving the SSH keys in Pass while it is
+    // unlocked. Listen is the path of the socket; with Confirm, every
+    // use of a key must be confirmed, not only of keys marked so.
+    SSHAgent struct {
+        Listen  string `json:"listen"`
+        Confirm bool   `json:"confirm"`
+    } `json:"sshagent"`
+
+    // Optional agent keeping Pass unlocked between runs of the command
+    // line tools and the desktop app. Socket defaults to agent.sock
+    // next to the configuration and Timeout, e.g., "30m", to 15
+    // minutes.
+    Agent struct {
+        Socket  string `json:"socket"`
+        Timeout string `json:"timeout"`
+    } `json:"agent"`
+
+    // The desktop app locks itself when it has not been used for Idle,
+    // e.g., "10m", and at the latest Lifetime, e.g., "12h", after it was
+    // unlocked. "0" disables either; see session for the defaults.
+    AutoLock struct {
+        Idle     string `json:"idle"`
+        Lifetime string `json:"lifetime"`
+    } `json:"autolock"`
+
+    // Browser extensions allowed to use the native messaging host, as
+    // chrome-extension://id/ origins or Firefox extension IDs. If
+    // empty, the manifest installed with the browser decides.
+    Browser struct {
+        Extensions []string `json:"extensions"`
+    } `json:"browser"`
+
+    // Groups of domains whose logins are shared, in addition to
+    // urlmatch.DefaultEquivalents, e.g., [["example.com", "example.net"]].
+    EquivalentDomains [][]string `json:"equivalentdomains"`
 }
 
 const filename = "/config/config.json"
 const iconpath = "/iconpack/"
 
+// String only reveals where the configuration points to. The token,
+// salt and CA are left out, so that a configuration can be logged.
+func (c Configuration) String() string {
+    return fmt.Sprintf("{host: %s, port: %d}", c.Host, c.Port)
+}
+
+func (c Configuration) GoString() string {
+    return c.String()
+}
+
+// Matcher finds entries by URL, with the configured equivalent domains.
+func (c Configuration) Matcher() *urlmatch.Matcher {
+    return urlmatch.NewMatcher(append(urlmatch.DefaultEquivalents, c.EquivalentDomains...))
+}
+
+// WrappedKey returns the data key wrapped for a way to unlock the
+// vault, if there is one.
+func (c Configuration) WrappedKey(method string) (WrappedKey, bool) {
+    for _, key := range c.Encrypted.Keys {
+        if key.Method == method {
+            return key, true
+        }
+    }
+
+    return WrappedKey{}, false
+}
+
 func GetConfig(path string) (Configuration, error) {
     cfg := path + filename
 
     if _, err := os.Stat(cfg); os.IsNotExist(err) {
-        log.Println("No config file present.")
         return Configuration{}, err
     } else {
         // Load config.
-        return LoadConfiguration(cfg), nil
+        return LoadConfiguration(cfg)
     }
 }
 
-func LoadConfiguration(file string) Configuration {
+func LoadConfiguration(file string) (Configuration, error) {
     var config Configuration
 
     // Try to open configuration file.
     configFile, err := os.Open(file)
-    defer configFile.Close()
 
     // Bail out if there was an error reading it.
     if err != nil {
-        log.Fatal(err.Error())
+        return config, err
     }
 
+    defer configFile.Close()
+
     // Decode the config.
     jsonParser := json.NewDecoder(configFile)
-    jsonParser.Decode(&config)
+    err = jsonParser.Decode(&config)
 
-    return config
+    return config, err
 }
 
-func ListAvailableIcons(path string) map[string]bool {
+func ListAvailableIcons(path string) (map[string]bool, error) {
     files, err := ioutil.ReadDir(path + iconpath)
 
     if err != nil {
-        log.Fatal(err)
+        return nil, err
     }
 
     icons := map[string]bool{}
@@ -98,5 +200,38 @@ func ListAvailableIcons(path string) map[string]bool {
         icons[strings.TrimSuffix(icon, filepath.Ext(icon))] = true
     }
 
-    return icons
+    return icons, nil
+}
+
+// WriteFileAtomic writes data to a file with the given permissions.
+// The data is written to a temporary file next to it, which is then
+// renamed, so that readers never see a partial file. The permissions
+// are set before anything is written, so that secrets are never
+// readable by others, not even for a moment.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+    tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+
+    if err != nil {
+        return err
+    }
+
+    defer os.Remove(tmp.Name())
+
+    if err = tmp.Chmod(perm); err == nil {
+        _, err = tmp.Write(data)
+    }
+
+    if err == nil {
+        err = tmp.Sync()
+    }
+
+    if closeErr := tmp.Close(); err == nil {
+        err = closeErr
+    }
+
+    if err != nil {
+        return err
+    }
+
+    return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,392 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package vault
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "os"
+    "pass/lock"
+    "pass/logger"
+    "pass/rest"
+    "pass/util"
+)
+
+// Changing the master password of a vault with a data key only wraps
+// the data key anew. Otherwise, and if asked to, every entry is
+// re-encrypted with a new data key: the entries are copied under the
+// new key, the copies are verified, the configuration is switched to
+// the new key and the originals are deleted. The journal, next to the
+// configuration, records the new salt, data key and token and which
+// copies were made, so that an interrupted change can be resumed, or
+// aborted, by running it again. Until the configuration is switched,
+// the old password unlocks the vault as before; afterwards the new one
+// does.
+
+const (
+    // Suffix of the journal of a password change.
+    JournalSuffix = ".rekey"
+)
+
+var (
+    ErrNewPassword = errors.New("new password differs from the one of the interrupted change")
+    ErrNoChange    = errors.New("no password change to abort")
+    ErrCommitted   = errors.New("password change already committed, run it again to finish it")
+)
+
+type journal struct {
+    // The new salt, the new data key wrapped with the new password and
+    // the token encrypted with the new data key. Journals without a
+    // data key encrypt the token with the new password itself.
+    Salt  string `json:"salt"`
+    Key   string `json:"key,omitempty"`
+    Token string `json:"token"`
+
+    // From the keys of the originals to the keys of their copies.
+    Keys map[string]string `json:"keys"`
+}
+
+func loadJournal(path string) (*journal, error) {
+    data, err := ioutil.ReadFile(path)
+
+    if err != nil {
+        return nil, err
+    }
+
+    var j journal
+
+    if err = json.Unmarshal(data, &j); err != nil {
+        return nil, err
+    }
+
+    if j.Keys == nil {
+        j.Keys = make(map[string]string)
+    }
+
+    return &j, nil
+}
+
+// The keys of the configuration after the change.
+func (j *journal) wrappedKeys() []util.WrappedKey {
+    if j.Key == "" {
+        return nil
+    }
+
+    return []util.WrappedKey{{Method: util.KeyMethodPassword, Key: j.Key}}
+}
+
+func (j *journal) save(path string) error {
+    data, err := json.Marshal(j)
+
+    if err != nil {
+        return err
+    }
+
+    return util.WriteFileAtomic(path, data, 0600)
+}
+
+// Replaces the encrypted token, the salt and the wrapped keys in the
+// configuration, leaving everything else as it is.
+func writeKeys(path string, token string, salt string, keys []util.WrappedKey) error {
+    data, err := ioutil.ReadFile(path)
+
+    if err != nil {
+        return err
+    }
+
+    var config map[string]json.RawMessage
+
+    if err = json.Unmarshal(data, &config); err != nil {
+        return err
+    }
+
+    var encrypted map[string]json.RawMessage
+
+    if config["encrypted"] != nil {
+        if err = json.Unmarshal(config["encrypted"], &encrypted); err != nil {
+            return err
+        }
+    }
+
+    if encrypted == nil {
+        encrypted = make(map[string]json.RawMessage)
+    }
+
+    encrypted["token"], _ = json.Marshal(token)
+    encrypted["salt"], _ = json.Marshal(salt)
+    delete(encrypted, "keys")
+
+    if len(keys) > 0 {
+        encrypted["keys"], _ = json.Marshal(keys)
+    }
+
+    config["encrypted"], _ = json.Marshal(encrypted)
+
+    if data, err = json.MarshalIndent(config, "", "    "); err != nil {
+        return err
+    }
+
+    info, err := os.Stat(path)
+
+    if err != nil {
+        return err
+    }
+
+    return util.WriteFileAtomic(path, append(data, '\n'), info.Mode().Perm())
+}
+
+// ChangePassword changes the master password of the vault configured
+// in path and returns the number of entries re-encrypted. Entries are
+// re-encrypted with a new data key if reencrypt is set or the vault
+// has no data key yet. If a change was interrupted, it is resumed,
+// which needs the same new password. Once the configuration has been
+// switched, only the new password is needed to finish the change.
+//
+// Running agents are locked, as they hold the old key or salt.
+func ChangePassword(path string, oldPassword string, newPassword string, reencrypt bool) (int, error) {
+    path = ConfigPath(path)
+    journalPath := path + JournalSuffix
+
+    c, err := util.LoadConfiguration(path)
+
+    if err != nil {
+        return 0, err
+    }
+
+    j, err := loadJournal(journalPath)
+
+    if err != nil && !os.IsNotExist(err) {
+        return 0, err
+    }
+
+    // Interrupted after switching the configuration.
+    if j != nil && j.Salt == c.Encrypted.Salt {
+        return 0, finishPasswordChange(c, j, journalPath, newPassword)
+    }
+
+    from, err := Connect(c, oldPassword)
+
+    if err != nil {
+        return 0, err
+    }
+
+    defer from.Wipe()
+
+    if _, ok := c.WrappedKey(util.KeyMethodPassword); ok && j == nil && !reencrypt {
+        return 0, rewrap(path, c, from, newPassword)
+    }
+
+    if j == nil {
+        if j, err = newJournal(from, newPassword); err != nil {
+            return 0, err
+        }
+
+        // Before anything is written, so that it can be undone.
+        if err = j.save(journalPath); err != nil {
+            return 0, err
+        }
+    }
+
+    newConfig := c
+    newConfig.Encrypted.Token = j.Token
+    newConfig.Encrypted.Salt = j.Salt
+    newConfig.Encrypted.Keys = j.wrappedKeys()
+    to, err := Connect(newConfig, newPassword)
+
+    if err == ErrPassword {
+        return 0, ErrNewPassword
+    }
+
+    if err != nil {
+        return 0, err
+    }
+
+    defer to.Wipe()
+
+    n, err := from.Rekey(to, j.Keys, func() error {
+        return j.save(journalPath)
+    })
+
+    if err != nil {
+        return n, err
+    }
+
+    if err = writeKeys(path, j.Token, j.Salt, j.wrappedKeys()); err != nil {
+        return n, err
+    }
+
+    if err = LockAgent(c); err != nil {
+        logger.Warn("Could not lock the agent:", err)
+    }
+
+    return n, deleteOriginals(to, j, journalPath)
+}
+
+// Sets up the journal of a change to a new data key, wrapped with the
+// new password.
+func newJournal(from *rest.Client, password string) (*journal, error) {
+    key, err := lock.NewDataKey()
+
+    if err != nil {
+        return nil, err
+    }
+
+    dataLock := lock.Lock{Key: key}
+    defer dataLock.Wipe()
+
+    salt := lock.Entropy(lock.SaltLength)
+    j := &journal{Salt: hex.EncodeToString(salt), Keys: make(map[string]string)}
+
+    if j.Token, err = dataLock.EncryptHex(from.DecryptedToken.Bytes()); err != nil {
+        return nil, err
+    }
+
+    l := lock.New(password, salt)
+    defer l.Wipe()
+
+    if j.Key, err = l.WrapKey(key); err != nil {
+        return nil, err
+    }
+
+    return j, nil
+}
+
+// Wraps the data key with the new password, leaving the other ways to
+// unlock the vault as they are.
+func rewrap(path string, c util.Configuration, client *rest.Client, password string) error {
+    salt := lock.Entropy(lock.SaltLength)
+    l := lock.New(password, salt)
+    wrapped, err := l.WrapKey(client.Lock.Key)
+    l.Wipe()
+
+    if err != nil {
+        return err
+    }
+
+    keys := []util.WrappedKey{{Method: util.KeyMethodPassword, Key: wrapped}}
+
+    for _, key := range c.Encrypted.Keys {
+        if key.Method != util.KeyMethodPassword {
+            keys = append(keys, key)
+        }
+    }
+
+    if err = writeKeys(path, c.Encrypted.Token, hex.EncodeToString(salt), keys); err != nil {
+        return err
+    }
+
+    if err = LockAgent(c); err != nil {
+        logger.Warn("Could not lock the agent:", err)
+    }
+
+    return nil
+}
+
+// Finishes a change after the configuration has been switched.
+func finishPasswordChange(c util.Configuration, j *journal, journalPath string, password string) error {
+    client, err := Connect(c, password)
+
+    if err != nil {
+        return err
+    }
+
+    defer client.Wipe()
+
+    if err = LockAgent(c); err != nil {
+        logger.Warn("Could not lock the agent:", err)
+    }
+
+    return deleteOriginals(client, j, journalPath)
+}
+
+func deleteOriginals(client *rest.Client, j *journal, journalPath string) error {
+    keys := make([]string, 0, len(j.Keys))
+
+    for key := range j.Keys {
+        keys = append(keys, key)
+    }
+
+    if err := client.DeleteKeys(keys); err != nil {
+        return err
+    }
+
+    return os.Remove(journalPath)
+}
+
+// AbortPasswordChange deletes the copies made by an interrupted change,
+// which leaves the vault as it was with the old password. A change
+// cannot be aborted once the configuration has been switched.
+func AbortPasswordChange(path string, oldPassword string) error {
+    path = ConfigPath(path)
+    journalPath := path + JournalSuffix
+
+    c, err := util.LoadConfiguration(path)
+
+    if err != nil {
+        return err
+    }
+
+    j, err := loadJournal(journalPath)
+
+    if os.IsNotExist(err) {
+        return ErrNoChange
+    }
+
+    if err != nil {
+        return err
+    }
+
+    if j.Salt == c.Encrypted.Salt {
+        return ErrCommitted
+    }
+
+    client, err := Connect(c, oldPassword)
+
+    if err != nil {
+        return err
+    }
+
+    defer client.Wipe()
+
+    copies := make([]string, 0, len(j.Keys))
+
+    for _, copyKey := range j.Keys {
+        copies = append(copies, copyKey)
+    }
+
+    if err = client.DeleteKeys(copies); err != nil {
+        return err
+    }
+
+    return os.Remove(journalPath)
+}
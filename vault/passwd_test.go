@@ -0,0 +1,334 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package vault
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "encoding/pem"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "pass/lock"
+    "pass/rest"
+    "pass/util"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// A minimal in-memory key/value backend, which can be made to fail
+// writes to simulate an interruption.
+type fakeVault struct {
+    mutex sync.Mutex
+    data  map[string]json.RawMessage
+
+    // Number of writes to accept before failing, if not negative.
+    writes int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    v.mutex.Lock()
+    defer v.mutex.Unlock()
+
+    key := strings.TrimPrefix(req.URL.Path, "/v1/secret/")
+
+    switch req.Method {
+    case rest.MethodList:
+        keys := []string{}
+
+        for k := range v.data {
+            keys = append(keys, k)
+        }
+
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "data": map[string]interface{}{"keys": keys},
+        })
+
+    case http.MethodGet:
+        data, ok := v.data[key]
+
+        if !ok {
+            w.WriteHeader(http.StatusNotFound)
+            w.Write([]byte(`{"errors":[]}`))
+            return
+        }
+
+        w.Write([]byte(`{"data":` + string(data) + `}`))
+
+    case http.MethodPut:
+        if v.writes == 0 {
+            w.WriteHeader(http.StatusInternalServerError)
+            w.Write([]byte(`{"errors":["unavailable"]}`))
+            return
+        }
+
+        v.writes--
+        body, _ := ioutil.ReadAll(req.Body)
+        v.data[key] = body
+        w.WriteHeader(http.StatusNoContent)
+
+    case http.MethodDelete:
+        delete(v.data, key)
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+// Sets up a vault with two entries, and a configuration for it with
+// the password "old".
+func newPasswdVault(t *testing.T) (string, *fakeVault, func()) {
+    vault := &fakeVault{data: make(map[string]json.RawMessage), writes: -1}
+    server := httptest.NewTLSServer(vault)
+
+    u, _ := url.Parse(server.URL)
+    port, _ := strconv.Atoi(u.Port())
+    ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+    salt := lock.Entropy(lock.SaltLength)
+    l := lock.New("old", salt)
+    token, err := l.EncryptAndEncodeHex("s.token")
+
+    if err != nil {
+        t.Fatalf("Lock error: %s", err)
+    }
+
+    dir, err := ioutil.TempDir("", "passwd")
+
+    if err != nil {
+        t.Fatalf("Temp error: %s", err)
+    }
+
+    config, _ := json.Marshal(map[string]interface{}{
+        "encrypted": map[string]string{"token": token, "salt": hex.EncodeToString(salt)},
+        "host":      u.Hostname(),
+        "port":      port,
+        "ca":        string(ca),
+        "padding":   "padme",
+        "agent":     map[string]string{"socket": filepath.Join(dir, "agent.sock")},
+    })
+
+    path := filepath.Join(dir, "config.json")
+
+    if err = ioutil.WriteFile(path, config, 0600); err != nil {
+        t.Fatalf("Write error: %s", err)
+    }
+
+    client := connectPasswd(t, path, "old")
+
+    for _, name := range []string{"github", "gitlab"} {
+        if err = client.VaultWriteSecret(&rest.DecodedEntry{Name: &rest.Name{Text: name}, Password: lock.SecureCopy([]byte("banana"))}); err != nil {
+            t.Fatalf("Write error: %s", err)
+        }
+    }
+
+    return path, vault, func() {
+        server.Close()
+        os.RemoveAll(dir)
+    }
+}
+
+func connectPasswd(t *testing.T, path string, password string) *rest.Client {
+    c, err := util.LoadConfiguration(path)
+
+    if err != nil {
+        t.Fatalf("Config error: %s", err)
+    }
+
+    client, err := Connect(c, password)
+
+    if err != nil {
+        t.Fatalf("Connect error: %s", err)
+    }
+
+    return client
+}
+
+func countPasswdEntries(t *testing.T, path string, password string) int {
+    names, err := connectPasswd(t, path, password).VaultListSecrets()
+
+    if err != nil {
+        t.Fatalf("List error: %s", err)
+    }
+
+    return len(*names)
+}
+
+func TestChangePassword(t *testing.T) {
+    path, vault, done := newPasswdVault(t)
+    defer done()
+
+    // Interrupted after copying an entry.
+    vault.writes = 1
+
+    if _, err := ChangePassword(path, "old", "new", false); err == nil {
+        t.Fatalf("Change not interrupted")
+    }
+
+    if _, err := os.Stat(path + JournalSuffix); err != nil {
+        t.Fatalf("No journal: %s", err)
+    }
+
+    // Nothing changes for the old password meanwhile.
+    if n := countPasswdEntries(t, path, "old"); n != 2 {
+        t.Errorf("Entries missing: %d", n)
+    }
+
+    vault.writes = -1
+
+    if _, err := ChangePassword(path, "old", "other", false); err != ErrNewPassword {
+        t.Errorf("Resumed with another password: %v", err)
+    }
+
+    if _, err := ChangePassword(path, "wrong", "new", false); err != ErrPassword {
+        t.Errorf("Resumed with a wrong password: %v", err)
+    }
+
+    if _, err := ChangePassword(path, "old", "new", false); err != nil {
+        t.Fatalf("Resume error: %s", err)
+    }
+
+    if _, err := os.Stat(path + JournalSuffix); !os.IsNotExist(err) {
+        t.Errorf("Journal left: %v", err)
+    }
+
+    c, _ := util.LoadConfiguration(path)
+
+    if _, err := Connect(c, "old"); err != ErrPassword {
+        t.Errorf("Old password still works: %v", err)
+    }
+
+    if c.Padding != "padme" || c.Host == "" {
+        t.Errorf("Configuration not kept: %#v", c)
+    }
+
+    if _, ok := c.WrappedKey(util.KeyMethodPassword); !ok {
+        t.Errorf("No data key")
+    }
+
+    if n := countPasswdEntries(t, path, "new"); n != 2 {
+        t.Errorf("Entries missing: %d", n)
+    }
+
+    // The copies and the tag.
+    if len(vault.data) != 3 {
+        t.Errorf("Originals left: %d", len(vault.data))
+    }
+}
+
+func TestAbortPasswordChange(t *testing.T) {
+    path, vault, done := newPasswdVault(t)
+    defer done()
+
+    if err := AbortPasswordChange(path, "old"); err != ErrNoChange {
+        t.Errorf("Aborted nothing: %v", err)
+    }
+
+    vault.writes = 1
+
+    if _, err := ChangePassword(path, "old", "new", false); err == nil {
+        t.Fatalf("Change not interrupted")
+    }
+
+    vault.writes = -1
+
+    if err := AbortPasswordChange(path, "old"); err != nil {
+        t.Fatalf("Abort error: %s", err)
+    }
+
+    if _, err := os.Stat(path + JournalSuffix); !os.IsNotExist(err) {
+        t.Errorf("Journal left: %v", err)
+    }
+
+    if len(vault.data) != 3 {
+        t.Errorf("Copies left: %d", len(vault.data))
+    }
+
+    if n := countPasswdEntries(t, path, "old"); n != 2 {
+        t.Errorf("Entries missing: %d", n)
+    }
+}
+
+func TestRewrapPassword(t *testing.T) {
+    path, vault, done := newPasswdVault(t)
+    defer done()
+
+    if _, err := ChangePassword(path, "old", "new", false); err != nil {
+        t.Fatalf("Change error: %s", err)
+    }
+
+    // Only the data key is wrapped anew.
+    stored := make(map[string]bool)
+
+    for key := range vault.data {
+        stored[key] = true
+    }
+
+    n, err := ChangePassword(path, "new", "newer", false)
+
+    if err != nil || n != 0 {
+        t.Fatalf("Rewrap re-encrypted %d entries: %v", n, err)
+    }
+
+    for key := range vault.data {
+        if !stored[key] {
+            t.Errorf("Entry rewritten: %s", key)
+        }
+    }
+
+    c, _ := util.LoadConfiguration(path)
+
+    if _, err = Connect(c, "new"); err != ErrPassword {
+        t.Errorf("Old password still works: %v", err)
+    }
+
+    if n := countPasswdEntries(t, path, "newer"); n != 2 {
+        t.Errorf("Entries missing: %d", n)
+    }
+
+    // A new data key, if asked for.
+    if n, err = ChangePassword(path, "newer", "newest", true); err != nil || n != 2 {
+        t.Fatalf("Re-encrypted %d entries: %v", n, err)
+    }
+
+    for key := range vault.data {
+        if stored[key] && key != "updated" {
+            t.Errorf("Entry not re-encrypted: %s", key)
+        }
+    }
+
+    if n := countPasswdEntries(t, path, "newest"); n != 2 {
+        t.Errorf("Entries missing: %d", n)
+    }
+}
@@ -0,0 +1,256 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package vault sets up a rest.Client from the configuration and the
+// master password, for the command line tools which, unlike the
+// desktop app, unlock Pass on every run unless an agent keeps it
+// unlocked.
+package vault
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "golang.org/x/term"
+    "os"
+    "path/filepath"
+    "pass/agent"
+    "pass/lock"
+    "pass/logger"
+    "pass/rest"
+    "pass/util"
+)
+
+const (
+    // Environment variable pointing to the configuration.
+    ConfigEnv = "PASS_CONFIG"
+)
+
+var (
+    ErrNoTerminal = errors.New("no terminal to read the password from")
+    ErrPassword   = errors.New("wrong password")
+)
+
+// ConfigPath returns the path of the configuration: the given path if
+// set, $PASS_CONFIG if set, and otherwise pass/config.json in the user
+// configuration directory.
+func ConfigPath(path string) string {
+    if path != "" {
+        return path
+    }
+
+    if path = os.Getenv(ConfigEnv); path != "" {
+        return path
+    }
+
+    dir, err := os.UserConfigDir()
+
+    if err != nil {
+        return "config.json"
+    }
+
+    return filepath.Join(dir, "pass", "config.json")
+}
+
+// Connect unlocks the token with the password and sets up a client as
+// configured.
+func Connect(c util.Configuration, password string) (*rest.Client, error) {
+    salt, err := hex.DecodeString(c.Encrypted.Salt)
+
+    if err != nil {
+        return nil, err
+    }
+
+    l := lock.New(password, salt)
+
+    // The derived key only unlocks the data key, if there is one.
+    if wrapped, ok := c.WrappedKey(util.KeyMethodPassword); ok {
+        key, err := l.UnwrapKey(wrapped.Key)
+        l.Wipe()
+
+        if err != nil {
+            return nil, ErrPassword
+        }
+
+        l = lock.Lock{Key: key, Salt: salt}
+    }
+
+    // Verify password against encrypted token + mac.
+    token, err := l.UnlockToken(c.Encrypted.Token)
+
+    if err != nil {
+        l.Wipe()
+        return nil, ErrPassword
+    }
+
+    client := newClient(c, &l)
+    client.DecryptedToken = token
+
+    return client, nil
+}
+
+func newClient(c util.Configuration, l *lock.Lock) *rest.Client {
+    var err error
+
+    client := rest.New(l)
+    client.BlindIndex = c.BlindIndex
+
+    if client.Padding, err = rest.GetPadding(c.Padding); err != nil {
+        logger.Warn(err, c.Padding)
+        client.Padding = rest.PadmePadding
+    }
+
+    client.Init(c.Host, c.Port, c.CA)
+
+    return &client
+}
+
+// Resume sets up a client with the key and token held by a running,
+// unlocked agent, without asking for the password. It returns
+// agent.ErrNoAgent or agent.ErrLocked if there is no such agent.
+func Resume(c util.Configuration) (*rest.Client, error) {
+    salt, err := hex.DecodeString(c.Encrypted.Salt)
+
+    if err != nil {
+        return nil, err
+    }
+
+    a, err := agent.Dial(agent.SocketPath(c.Agent.Socket))
+
+    if err != nil {
+        return nil, err
+    }
+
+    key, agentSalt, token, err := a.Key()
+
+    // Only the copies in secure buffers are kept.
+    defer lock.Zero(key)
+    defer lock.Zero(token)
+
+    if err != nil {
+        return nil, err
+    }
+
+    // The agent may have been started for another vault.
+    if !bytes.Equal(salt, agentSalt) {
+        return nil, agent.ErrKey
+    }
+
+    client := newClient(c, &lock.Lock{Key: lock.SecureCopy(key), Salt: salt})
+    client.DecryptedToken = lock.SecureCopy(token)
+
+    return client, nil
+}
+
+// Share hands the key of an unlocked client to the agent, if one is
+// running, so that the password is not asked for again.
+func Share(c util.Configuration, client *rest.Client) error {
+    a, err := agent.Dial(agent.SocketPath(c.Agent.Socket))
+
+    if err == agent.ErrNoAgent {
+        return nil
+    }
+
+    if err != nil {
+        return err
+    }
+
+    return a.Unlock(client.Lock.Key.Bytes())
+}
+
+// LockAgent asks the agent, if one is running, to lock.
+func LockAgent(c util.Configuration) error {
+    a, err := agent.Dial(agent.SocketPath(c.Agent.Socket))
+
+    if err == agent.ErrNoAgent {
+        return nil
+    }
+
+    if err != nil {
+        return err
+    }
+
+    return a.Lock()
+}
+
+// ReadPassword asks for the master password on the terminal, also if
+// standard input and output are redirected.
+func ReadPassword(prompt string) (string, error) {
+    tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+
+    if err != nil {
+        return "", ErrNoTerminal
+    }
+
+    defer tty.Close()
+
+    fmt.Fprint(tty, prompt)
+    password, err := term.ReadPassword(int(tty.Fd()))
+    fmt.Fprintln(tty)
+
+    return string(password), err
+}
+
+// Open loads the configuration and connects, with the key held by the
+// agent if it is unlocked and otherwise asking for the password.
+func Open(path string) (*rest.Client, error) {
+    c, err := util.LoadConfiguration(ConfigPath(path))
+
+    if err != nil {
+        return nil, err
+    }
+
+    client, err := Resume(c)
+
+    if err == nil {
+        return client, nil
+    }
+
+    if err != agent.ErrNoAgent && err != agent.ErrLocked {
+        logger.Warn("Could not use the agent:", err)
+    }
+
+    password, err := ReadPassword("Password: ")
+
+    if err != nil {
+        return nil, err
+    }
+
+    if client, err = Connect(c, password); err != nil {
+        return nil, err
+    }
+
+    if err = Share(c, client); err != nil {
+        logger.Warn("Could not unlock the agent:", err)
+    }
+
+    return client, nil
+}
@@ -0,0 +1,576 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package webui
+
+import (
+    "bytes"
+    "encoding/base64"
+    "golang.org/x/crypto/ed25519"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "pass/lock"
+    "pass/otp"
+    "pass/rest"
+    "pass/urlmatch"
+    "path"
+    "sort"
+    "strings"
+    "time"
+)
+
+// OTP codes change every period.
+const otpPeriod = 30
+
+// Entry types which can be created here.
+var newTypes = []string{rest.TypeAccount, rest.TypeOTP, rest.TypeFile, rest.TypeSign}
+
+func (s *Server) routes() {
+    s.mux.HandleFunc("/", s.index)
+    s.mux.HandleFunc("/style.css", s.style)
+    s.mux.HandleFunc("/unlock", s.post(s.unlock))
+    s.mux.HandleFunc("/lock", s.post(s.lock))
+    s.mux.HandleFunc("/entry", s.needVault(s.entry))
+    s.mux.HandleFunc("/new", s.needVault(s.newEntry))
+    s.mux.HandleFunc("/save", s.post(s.needVault(s.save)))
+    s.mux.HandleFunc("/delete", s.post(s.needVault(s.remove)))
+    s.mux.HandleFunc("/file", s.needVault(s.file))
+    s.mux.HandleFunc("/upload", s.post(s.needVault(s.upload)))
+    s.mux.HandleFunc("/generate", s.post(s.needVault(s.generateKey)))
+    s.mux.HandleFunc("/sign", s.post(s.needVault(s.sign)))
+}
+
+// The data every page gets.
+type page struct {
+    CSRF  string
+    Title string
+    Error string
+}
+
+func (s *Server) page(title string) page {
+    return page{CSRF: s.csrfToken(), Title: title}
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, name string, data interface{}) {
+    var buf bytes.Buffer
+
+    // Render fully first, so that an error does not leave half a page.
+    if err := s.templates.ExecuteTemplate(&buf, name, data); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(status)
+    buf.WriteTo(w)
+}
+
+func (s *Server) fail(w http.ResponseWriter, status int, err error) {
+    p := s.page("Error")
+    p.Error = err.Error()
+    s.render(w, status, "error", p)
+}
+
+// Only accepts POST, so that links cannot change anything.
+func (s *Server) post(handler http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        handler(w, r)
+    }
+}
+
+// Sends to the unlock screen while locked.
+func (s *Server) needVault(handler http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if _, ok := s.unlocked(); !ok {
+            http.Redirect(w, r, "/", http.StatusSeeOther)
+            return
+        }
+
+        handler(w, r)
+    }
+}
+
+func (s *Server) style(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/css; charset=utf-8")
+    w.Write([]byte(style))
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+        http.NotFound(w, r)
+        return
+    }
+
+    if _, ok := s.unlocked(); !ok {
+        s.render(w, http.StatusOK, "unlock", s.page("Unlock"))
+        return
+    }
+
+    s.search(w, r)
+}
+
+func (s *Server) unlock(w http.ResponseWriter, r *http.Request) {
+    v, err := s.Connect(r.FormValue("password"))
+
+    if err != nil {
+        p := s.page("Unlock")
+        p.Error = err.Error()
+        s.render(w, http.StatusUnauthorized, "unlock", p)
+        return
+    }
+
+    s.Unlock(v)
+    http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) lock(w http.ResponseWriter, r *http.Request) {
+    s.Lock()
+    http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// An item of the search list.
+type item struct {
+    Key    string
+    Name   string
+    Label  string
+    Folder bool
+}
+
+type searchPage struct {
+    page
+    Query  string
+    Folder string
+    Parent string
+    Items  []item
+    Types  []string
+}
+
+// Lists the folders and entries of a folder, or, with a query, the
+// matching entries of all folders, like the search of the desktop app.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+    v, _ := s.unlocked()
+    query := r.URL.Query().Get("q")
+    folder := r.URL.Query().Get("folder")
+
+    list, err := v.VaultListSecrets()
+
+    if err != nil {
+        s.fail(w, http.StatusBadGateway, err)
+        return
+    }
+
+    names := *list
+    notes, err := v.SearchNotes(names, query)
+
+    if err != nil {
+        s.fail(w, http.StatusBadGateway, err)
+        return
+    }
+
+    var urls map[string]int
+
+    if s.Matcher != nil && urlmatch.LooksLikeURL(query) {
+        if urls, err = v.SearchURLs(names, query, s.Matcher); err != nil {
+            s.fail(w, http.StatusBadGateway, err)
+            return
+        }
+    }
+
+    p := searchPage{page: s.page("Pass"), Query: query, Folder: folder, Types: newTypes}
+
+    if query == "" {
+        p.Parent = rest.ParentFolder(folder)
+
+        for _, f := range rest.Folders(names, folder) {
+            _, caption := rest.SplitPath(f)
+            p.Items = append(p.Items, item{Key: f, Name: caption, Label: "Folder", Folder: true})
+        }
+    }
+
+    var entries []item
+
+    for _, n := range names {
+        if query == "" && !rest.InFolder(n.Text, folder) {
+            continue
+        }
+
+        if query != "" && !strings.Contains(strings.ToLower(n.Text), strings.ToLower(query)) &&
+            !notes[n.Encrypted] && urls[n.Encrypted] == 0 {
+            continue
+        }
+
+        caption := n.Text
+
+        if query == "" {
+            _, caption = rest.SplitPath(n.Text)
+        }
+
+        entries = append(entries, item{Key: n.Encrypted, Name: caption, Label: rest.GetType(n.Type).Label})
+    }
+
+    // Entries for a URL come first, best match first.
+    sort.SliceStable(entries, func(i, j int) bool {
+        if urls[entries[i].Key] != urls[entries[j].Key] {
+            return urls[entries[i].Key] > urls[entries[j].Key]
+        }
+        return entries[i].Name < entries[j].Name
+    })
+
+    p.Items = append(p.Items, entries...)
+    s.render(w, http.StatusOK, "search", p)
+}
+
+// Reads the entry with the storage key given in the request.
+func (s *Server) read(r *http.Request) (*rest.DecodedEntry, error) {
+    v, _ := s.unlocked()
+    key := r.FormValue("key")
+
+    list, err := v.VaultListSecrets()
+
+    if err != nil {
+        return nil, err
+    }
+
+    for _, n := range *list {
+        if n.Encrypted == key {
+            name := n
+            return v.VaultReadSecret(&name)
+        }
+    }
+
+    return nil, rest.ErrNotFound
+}
+
+type entryPage struct {
+    page
+    Key       string
+    Name      string
+    Type      string
+    Label     string
+    New       bool
+    Reveal    bool
+    Data      *rest.DecodedEntry
+    URLs      string
+    Tags      string
+    Modes     []string
+    Code      string
+    Remaining int
+    Size      int
+    PublicKey string
+}
+
+func (s *Server) entryPage(d *rest.DecodedEntry) entryPage {
+    t := rest.GetType(d.Type)
+    p := entryPage{
+        page:  s.page(d.Name.Text),
+        Key:   d.Name.Encrypted,
+        Name:  d.Name.Text,
+        Type:  t.ID,
+        Label: t.Label,
+        Data:  d,
+        URLs:  strings.Join(d.URLs, "\n"),
+        Tags:  strings.Join(d.Tags, ", "),
+        Modes: []string{urlmatch.ModeDomain, urlmatch.ModeHost, urlmatch.ModeStartsWith, urlmatch.ModeRegex},
+        Size:  len(d.File),
+    }
+
+    switch t.ID {
+    case rest.TypeOTP:
+        if d.Password.Len() > 0 {
+            p.Code = otp.ComputeOTPCode(d.Password.Bytes())
+            p.Remaining = otpPeriod - int(time.Now().Unix()%otpPeriod)
+        }
+    case rest.TypeSign:
+        if k, ok := d.Payload.(*rest.KeyPair); ok && len(k.Pub) > 0 {
+            p.PublicKey = base64.StdEncoding.EncodeToString(k.Pub)
+        }
+    }
+
+    return p
+}
+
+func (s *Server) entry(w http.ResponseWriter, r *http.Request) {
+    d, err := s.read(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    p := s.entryPage(d)
+    p.Reveal = r.URL.Query().Get("reveal") != ""
+    s.render(w, http.StatusOK, "entry", p)
+}
+
+func (s *Server) newEntry(w http.ResponseWriter, r *http.Request) {
+    id := r.URL.Query().Get("type")
+
+    if _, ok := rest.LookupType(id); !ok {
+        id = rest.TypeAccount
+    }
+
+    p := s.entryPage(&rest.DecodedEntry{Name: &rest.Name{}, Type: id})
+    p.Title = "New " + p.Label
+    p.New = true
+    s.render(w, http.StatusOK, "entry", p)
+}
+
+// Writes an entry, under a new name if it changed.
+func (s *Server) write(d *rest.DecodedEntry, name string) error {
+    v, _ := s.unlocked()
+
+    if d.Name.Encrypted != "" && d.Name.Text != name {
+        return v.VaultRenameSecret(d, name)
+    }
+
+    d.Name.Text = name
+    return v.VaultWriteSecret(d)
+}
+
+// The entry to save to: the one given, or a new one of the type.
+func (s *Server) target(r *http.Request) (*rest.DecodedEntry, error) {
+    if r.FormValue("key") != "" {
+        return s.read(r)
+    }
+
+    id := r.FormValue("type")
+
+    if _, ok := rest.LookupType(id); !ok {
+        return nil, rest.ErrUnknownType
+    }
+
+    return &rest.DecodedEntry{Name: &rest.Name{}, Type: id}, nil
+}
+
+func redirectToEntry(w http.ResponseWriter, r *http.Request, d *rest.DecodedEntry) {
+    http.Redirect(w, r, "/entry?key="+url.QueryEscape(d.Name.Encrypted), http.StatusSeeOther)
+}
+
+// Saves the common fields of an entry.
+func (s *Server) save(w http.ResponseWriter, r *http.Request) {
+    d, err := s.target(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    name := strings.TrimSpace(r.FormValue("name"))
+
+    if name == "" {
+        s.fail(w, http.StatusBadRequest, ErrNoName)
+        return
+    }
+
+    d.Username = r.FormValue("username")
+    d.Notes = r.FormValue("notes")
+    d.URLs = rest.SplitList(r.FormValue("urls"))
+    d.Tags = rest.SplitList(r.FormValue("tags"))
+
+    if d.Match = r.FormValue("match"); d.Match == urlmatch.ModeDomain {
+        d.Match = ""
+    }
+
+    // An empty password field keeps the password, as it is not sent
+    // to the browser unless revealed.
+    if r.FormValue("generate") != "" {
+        d.SetPassword(lock.EntropyAlphabet(lock.DefaultGeneratedPasswordLength))
+    } else if password := r.FormValue("password"); password != "" {
+        d.SetPassword(password)
+    }
+
+    if err = s.write(d, name); err != nil {
+        s.fail(w, http.StatusBadRequest, err)
+        return
+    }
+
+    redirectToEntry(w, r, d)
+}
+
+func (s *Server) remove(w http.ResponseWriter, r *http.Request) {
+    v, _ := s.unlocked()
+    d, err := s.read(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    if err = v.VaultDeleteSecret(d); err != nil {
+        s.fail(w, http.StatusBadGateway, err)
+        return
+    }
+
+    folder, _ := rest.SplitPath(d.Name.Text)
+    http.Redirect(w, r, "/?folder="+url.QueryEscape(folder), http.StatusSeeOther)
+}
+
+// Downloads the file of a file entry.
+func (s *Server) file(w http.ResponseWriter, r *http.Request) {
+    d, err := s.read(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    _, filename := rest.SplitPath(d.Name.Text)
+    attachment(w, filename, d.File)
+}
+
+func attachment(w http.ResponseWriter, filename string, data []byte) {
+    w.Header().Set("Content-Type", "application/octet-stream")
+    w.Header().Set("Content-Disposition", "attachment; filename="+
+        strings.Replace(url.PathEscape(filename), ";", "%3B", -1))
+    w.Write(data)
+}
+
+// Reads the uploaded file.
+func uploaded(r *http.Request) (string, []byte, error) {
+    f, header, err := r.FormFile("file")
+
+    if err != nil {
+        return "", nil, err
+    }
+
+    defer f.Close()
+
+    data, err := ioutil.ReadAll(f)
+    return path.Base(header.Filename), data, err
+}
+
+// Stores an uploaded file in a new or existing file entry. A new entry
+// is named after the file, unless a name is given.
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+    d, err := s.target(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    filename, data, err := uploaded(r)
+
+    if err != nil {
+        s.fail(w, http.StatusBadRequest, err)
+        return
+    }
+
+    name := strings.TrimSpace(r.FormValue("name"))
+
+    if name == "" {
+        name = d.Name.Text
+    }
+
+    if name == "" {
+        name = filename
+    }
+
+    d.Type = rest.TypeFile
+    d.File = data
+
+    if err = s.write(d, name); err != nil {
+        s.fail(w, http.StatusBadRequest, err)
+        return
+    }
+
+    redirectToEntry(w, r, d)
+}
+
+// Generates the key pair of a new or existing signing entry.
+func (s *Server) generateKey(w http.ResponseWriter, r *http.Request) {
+    d, err := s.target(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    name := strings.TrimSpace(r.FormValue("name"))
+
+    if name == "" {
+        name = d.Name.Text
+    }
+
+    if name == "" {
+        s.fail(w, http.StatusBadRequest, ErrNoName)
+        return
+    }
+
+    pub, priv, err := ed25519.GenerateKey(nil)
+
+    if err != nil {
+        s.fail(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    d.Type = rest.TypeSign
+    d.Payload = &rest.KeyPair{Priv: priv, Pub: pub}
+
+    if err = s.write(d, name); err != nil {
+        s.fail(w, http.StatusBadRequest, err)
+        return
+    }
+
+    redirectToEntry(w, r, d)
+}
+
+// Signs an uploaded file and sends the signature back, named like the
+// desktop app names it.
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+    d, err := s.read(r)
+
+    if err != nil {
+        s.fail(w, http.StatusNotFound, err)
+        return
+    }
+
+    k, ok := d.Payload.(*rest.KeyPair)
+
+    if !ok || len(k.Priv) != ed25519.PrivateKeySize {
+        s.fail(w, http.StatusBadRequest, ErrNoKey)
+        return
+    }
+
+    filename, data, err := uploaded(r)
+
+    if err != nil {
+        s.fail(w, http.StatusBadRequest, err)
+        return
+    }
+
+    attachment(w, filename+SignatureFileExtension, ed25519.Sign(ed25519.PrivateKey(k.Priv), data))
+}
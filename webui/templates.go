@@ -0,0 +1,299 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package webui
+
+import (
+    "html/template"
+)
+
+var templateFuncs = template.FuncMap{
+    "kilobytes": func(n int) int {
+        return (n + 1023) / 1024
+    },
+}
+
+// The pages. html/template escapes everything filled in, and since the
+// policy forbids scripts, nothing relies on them.
+const templates = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="/style.css">
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}
+</body>
+</html>
+{{end}}
+
+{{define "lockbutton"}}
+<form method="post" action="/lock" class="lock">
+    <input type="hidden" name="csrf" value="{{.CSRF}}">
+    <button>Lock</button>
+</form>
+{{end}}
+
+{{define "error"}}{{template "header" .}}
+<main>
+    <p class="error">{{.Error}}</p>
+    <p><a href="/">Back</a></p>
+</main>
+{{template "footer"}}{{end}}
+
+{{define "unlock"}}{{template "header" .}}
+<main class="unlock">
+    <h1>Pass</h1>
+    <form method="post" action="/unlock">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="password" name="password" placeholder="Password" autofocus autocomplete="off">
+        <button>Unlock</button>
+    </form>
+    {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+</main>
+{{template "footer"}}{{end}}
+
+{{define "search"}}{{template "header" .}}
+<header>
+    <form method="get" action="/">
+        <input type="search" name="q" value="{{.Query}}"
+               placeholder="{{if .Folder}}{{.Folder}}{{else}}Search{{end}}" autofocus autocomplete="off">
+        {{if .Folder}}<input type="hidden" name="folder" value="{{.Folder}}">{{end}}
+    </form>
+    {{template "lockbutton" .}}
+</header>
+<main>
+    <ul class="entries">
+        {{if and (not .Query) .Folder}}
+        <li><a href="/?folder={{.Parent}}"><span class="caption">..</span><span class="label">Folder</span></a></li>
+        {{end}}
+        {{range .Items}}
+        {{if .Folder}}
+        <li><a href="/?folder={{.Key}}"><span class="caption">{{.Name}}</span><span class="label">{{.Label}}</span></a></li>
+        {{else}}
+        <li><a href="/entry?key={{.Key}}"><span class="caption">{{.Name}}</span><span class="label">{{.Label}}</span></a></li>
+        {{end}}
+        {{end}}
+    </ul>
+    <p class="new">
+        New:
+        {{range .Types}}<a href="/new?type={{.}}">{{.}}</a> {{end}}
+    </p>
+</main>
+{{template "footer"}}{{end}}
+
+{{define "entry"}}{{template "header" .}}
+<header>
+    <a href="/" class="back">&larr; Back</a>
+    {{template "lockbutton" .}}
+</header>
+<main>
+    <h1>{{if .New}}{{.Title}}{{else}}{{.Name}}{{end}}</h1>
+    <p class="label">{{.Label}}</p>
+
+    {{if eq .Type "otp"}}{{if .Code}}
+    <p class="code">{{.Code}}</p>
+    <p class="remaining">valid for {{.Remaining}} s &middot; <a href="/entry?key={{.Key}}">refresh</a></p>
+    {{end}}{{end}}
+
+    {{if eq .Type "file"}}
+    {{if not .New}}<p>{{kilobytes .Size}} kB &middot; <a href="/file?key={{.Key}}">Download</a></p>{{end}}
+    <form method="post" action="/upload" enctype="multipart/form-data">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="hidden" name="key" value="{{.Key}}">
+        <input type="hidden" name="type" value="file">
+        <input type="text" name="name" value="{{.Name}}" placeholder="Name (default the file name)">
+        <input type="file" name="file" required>
+        <button>{{if .New}}Add{{else}}Replace{{end}}</button>
+    </form>
+    {{end}}
+
+    {{if eq .Type "sign"}}
+    {{if .PublicKey}}
+    <p>Public key</p>
+    <pre class="publickey">{{.PublicKey}}</pre>
+    <form method="post" action="/sign" enctype="multipart/form-data">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="hidden" name="key" value="{{.Key}}">
+        <input type="file" name="file" required>
+        <button>Sign</button>
+    </form>
+    {{else}}
+    <form method="post" action="/generate">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="hidden" name="key" value="{{.Key}}">
+        <input type="hidden" name="type" value="sign">
+        {{if .New}}<input type="text" name="name" placeholder="Name" required>{{end}}
+        <button>Generate key</button>
+    </form>
+    {{end}}
+    {{end}}
+
+    {{if and (ne .Type "file") (ne .Type "sign")}}
+    <form method="post" action="/save" class="entry">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="hidden" name="key" value="{{.Key}}">
+        <input type="hidden" name="type" value="{{.Type}}">
+        <label>Name <input type="text" name="name" value="{{.Name}}" required></label>
+        {{if ne .Type "otp"}}
+        <label>Username <input type="text" name="username" value="{{.Data.Username}}" autocomplete="off"></label>
+        {{end}}
+        <label>{{if eq .Type "otp"}}Secret{{else}}Password{{end}}
+            {{if .Reveal}}
+            <input type="text" name="password" value="{{.Data.PasswordText}}" autocomplete="off">
+            {{else}}
+            <input type="password" name="password" placeholder="{{if .Data.Password.Len}}unchanged{{end}}" autocomplete="new-password">
+            {{end}}
+        </label>
+        {{if and .Data.Password.Len (not .Reveal)}}<p><a href="/entry?key={{.Key}}&amp;reveal=1">Show {{if eq .Type "otp"}}secret{{else}}password{{end}}</a></p>{{end}}
+        {{if ne .Type "otp"}}
+        <label><input type="checkbox" name="generate" value="1"> Generate a new password</label>
+        {{end}}
+        <label>URLs <textarea name="urls" placeholder="One per line">{{.URLs}}</textarea></label>
+        <label>Match
+            <select name="match">
+                {{range .Modes}}<option value="{{.}}" {{if eq . (or $.Data.Match "domain")}}selected{{end}}>{{.}}</option>{{end}}
+            </select>
+        </label>
+        <label>Tags <input type="text" name="tags" value="{{.Tags}}" placeholder="Separated by commas"></label>
+        <label>Notes <textarea name="notes">{{.Data.Notes}}</textarea></label>
+        <button>Save</button>
+    </form>
+    {{end}}
+
+    {{if not .New}}
+    <form method="post" action="/delete" class="delete">
+        <input type="hidden" name="csrf" value="{{.CSRF}}">
+        <input type="hidden" name="key" value="{{.Key}}">
+        <button>Delete</button>
+    </form>
+    {{end}}
+</main>
+{{template "footer"}}{{end}}
+`
+
+const style = `
+body {
+    font-family: -apple-system, "Segoe UI", Roboto, sans-serif;
+    background: #2b2b2b;
+    color: #eee;
+    margin: 0 auto;
+    max-width: 480px;
+    padding: 16px;
+}
+
+a {
+    color: #9cf;
+    text-decoration: none;
+}
+
+header {
+    display: flex;
+    align-items: center;
+    justify-content: space-between;
+    margin-bottom: 16px;
+}
+
+input, textarea, select, button {
+    font: inherit;
+    color: inherit;
+    background: #3b3b3b;
+    border: 1px solid #555;
+    border-radius: 4px;
+    padding: 6px;
+    box-sizing: border-box;
+}
+
+input[type=text], input[type=password], input[type=search], textarea {
+    width: 100%;
+}
+
+textarea {
+    height: 64px;
+    resize: vertical;
+}
+
+label {
+    display: block;
+    margin: 8px 0;
+    font-size: 13px;
+}
+
+button {
+    cursor: pointer;
+}
+
+.entries {
+    list-style: none;
+    padding: 0;
+}
+
+.entries a {
+    display: flex;
+    justify-content: space-between;
+    padding: 8px;
+    border-bottom: 1px solid #3b3b3b;
+    color: inherit;
+}
+
+.label, .remaining {
+    font-size: 12px;
+    opacity: 0.6;
+}
+
+.code {
+    font-size: 32px;
+    letter-spacing: 4px;
+    text-align: center;
+}
+
+.publickey {
+    white-space: pre-wrap;
+    word-break: break-all;
+}
+
+.error {
+    color: #f66;
+}
+
+.delete {
+    margin-top: 24px;
+}
+
+.unlock {
+    text-align: center;
+    margin-top: 20vh;
+}
+`
@@ -0,0 +1,278 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package webui is a front end to Pass served over HTTP on localhost,
+// for platforms where the desktop app does not run. It offers the
+// screens of the desktop app: unlock, search, accounts, OTP, files and
+// signing keys.
+//
+// Only the browser which opened the login link, which carries a random
+// one-time token, gets the session cookie; every other request is
+// refused. Forms carry a CSRF token, pages forbid scripts, framing and
+// loading anything from elsewhere, and the Host header is checked, so
+// that other sites cannot reach the server through DNS rebinding.
+package webui
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "html/template"
+    "net"
+    "net/http"
+    "pass/lock"
+    "pass/rest"
+    "pass/urlmatch"
+    "sync"
+)
+
+const (
+    // Name of the session cookie.
+    CookieName = "pass-session"
+
+    secretLength = 32
+
+    // As written by the desktop app.
+    SignatureFileExtension = ".signature"
+
+    // Largest request body, e.g., a file to sign.
+    MaximumRequestSize = 32 * 1024 * 1024
+
+    // Content Security Policy of all pages: nothing but the style
+    // sheet is loaded, no scripts run, and forms are only submitted
+    // here.
+    contentSecurityPolicy = "default-src 'none'; style-src 'self'; " +
+        "form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+)
+
+var (
+    ErrNotLoopback = errors.New("web UI must listen on a loopback address")
+    ErrNoName      = errors.New("entry has no name")
+    ErrNoKey       = errors.New("no key has been generated")
+)
+
+// A Vault holds the entries; it is implemented by rest.Client.
+type Vault interface {
+    VaultListSecrets() (*[]rest.Name, error)
+    VaultReadSecret(data *rest.Name) (*rest.DecodedEntry, error)
+    VaultWriteSecret(data *rest.DecodedEntry) error
+    VaultRenameSecret(data *rest.DecodedEntry, name string) error
+    VaultDeleteSecret(data *rest.DecodedEntry) error
+    SearchNotes(names []rest.Name, query string) (map[string]bool, error)
+    SearchURLs(names []rest.Name, page string, m *urlmatch.Matcher) (map[string]int, error)
+    Wipe()
+}
+
+// Server serves the web UI for one session.
+type Server struct {
+    // Connect unlocks the vault with the password.
+    Connect func(password string) (Vault, error)
+
+    // Finds entries by URL in the search.
+    Matcher *urlmatch.Matcher
+
+    // Called when the user locks, e.g., to lock the agent too.
+    OnLock func()
+
+    // The session secret, kept in the cookie, and the token of the
+    // login link.
+    secret     []byte
+    loginToken []byte
+
+    // Host:port the server listens on, the only Host accepted.
+    addr string
+
+    mu    sync.Mutex
+    vault Vault
+
+    mux       *http.ServeMux
+    templates *template.Template
+}
+
+// New sets up a locked server.
+func New(connect func(password string) (Vault, error), m *urlmatch.Matcher) *Server {
+    s := &Server{
+        Connect:    connect,
+        Matcher:    m,
+        secret:     lock.Entropy(secretLength),
+        loginToken: lock.Entropy(secretLength),
+        mux:        http.NewServeMux(),
+        templates:  template.Must(template.New("").Funcs(templateFuncs).Parse(templates)),
+    }
+
+    s.routes()
+    return s
+}
+
+// Listen binds to a loopback address, e.g., 127.0.0.1:0 for any free
+// port, and returns the listener and the login link to open.
+func (s *Server) Listen(addr string) (net.Listener, string, error) {
+    host, _, err := net.SplitHostPort(addr)
+
+    if err != nil {
+        return nil, "", err
+    }
+
+    if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+        return nil, "", ErrNotLoopback
+    }
+
+    listener, err := net.Listen("tcp", addr)
+
+    if err != nil {
+        return nil, "", err
+    }
+
+    s.addr = listener.Addr().String()
+
+    return listener, s.LoginURL(), nil
+}
+
+// LoginURL is the link which starts the session. It works once.
+func (s *Server) LoginURL() string {
+    return "http://" + s.addr + "/login?token=" + hex.EncodeToString(s.loginToken)
+}
+
+// The CSRF token of the session, derived from the secret.
+func (s *Server) csrfToken() string {
+    mac := hmac.New(sha256.New, s.secret)
+    mac.Write([]byte("csrf"))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func equal(a string, b string) bool {
+    return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Unlock uses a vault which was unlocked elsewhere, e.g., by the agent.
+func (s *Server) Unlock(v Vault) {
+    s.mu.Lock()
+    s.vault = v
+    s.mu.Unlock()
+}
+
+// Lock wipes the key and the token of the vault and forgets it.
+func (s *Server) Lock() {
+    s.mu.Lock()
+
+    if s.vault != nil {
+        s.vault.Wipe()
+    }
+
+    s.vault = nil
+    s.mu.Unlock()
+
+    if s.OnLock != nil {
+        s.OnLock()
+    }
+}
+
+func (s *Server) unlocked() (Vault, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.vault, s.vault != nil
+}
+
+// ServeHTTP checks every request before handing it to the pages.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    header := w.Header()
+    header.Set("Content-Security-Policy", contentSecurityPolicy)
+    header.Set("X-Content-Type-Options", "nosniff")
+    header.Set("X-Frame-Options", "DENY")
+    header.Set("Referrer-Policy", "no-referrer")
+    header.Set("Cache-Control", "no-store")
+
+    // Another site could resolve its name to 127.0.0.1, but it cannot
+    // make the browser send our address as Host.
+    if r.Host != s.addr {
+        http.Error(w, "wrong host", http.StatusMisdirectedRequest)
+        return
+    }
+
+    if r.URL.Path == "/login" {
+        s.login(w, r)
+        return
+    }
+
+    cookie, err := r.Cookie(CookieName)
+
+    if err != nil || !equal(cookie.Value, hex.EncodeToString(s.secret)) {
+        http.Error(w, "open the link printed when the server was started", http.StatusForbidden)
+        return
+    }
+
+    if r.Method == http.MethodPost {
+        r.Body = http.MaxBytesReader(w, r.Body, MaximumRequestSize)
+
+        // Browsers send the origin of a form; it must be ours.
+        if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+s.addr {
+            http.Error(w, "wrong origin", http.StatusForbidden)
+            return
+        }
+
+        if !equal(r.FormValue("csrf"), s.csrfToken()) {
+            http.Error(w, "invalid form", http.StatusForbidden)
+            return
+        }
+    }
+
+    s.mux.ServeHTTP(w, r)
+}
+
+// Trades the one-time token of the login link for the session cookie.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    token := hex.EncodeToString(s.loginToken)
+    valid := len(s.loginToken) > 0 && equal(r.URL.Query().Get("token"), token)
+
+    if valid {
+        s.loginToken = nil
+    }
+
+    s.mu.Unlock()
+
+    if !valid {
+        http.Error(w, "the login link is invalid or was already used", http.StatusForbidden)
+        return
+    }
+
+    http.SetCookie(w, &http.Cookie{
+        Name:     CookieName,
+        Value:    hex.EncodeToString(s.secret),
+        Path:     "/",
+        HttpOnly: true,
+        SameSite: http.SameSiteStrictMode,
+    })
+
+    http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,416 @@
+/*
+Copyright (c) 2018 Carl Löndahl. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Pass Desktop nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package webui
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "golang.org/x/crypto/ed25519"
+    "io/ioutil"
+    "mime/multipart"
+    "net/http"
+    "net/http/cookiejar"
+    "net/url"
+    "pass/lock"
+    "pass/rest"
+    "pass/urlmatch"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+type fakeVault struct {
+    entries map[string]*rest.DecodedEntry
+    next    int
+    wiped   bool
+}
+
+func (f *fakeVault) VaultListSecrets() (*[]rest.Name, error) {
+    names := []rest.Name{}
+
+    for _, d := range f.entries {
+        names = append(names, *d.Name)
+    }
+
+    return &names, nil
+}
+
+func (f *fakeVault) VaultReadSecret(n *rest.Name) (*rest.DecodedEntry, error) {
+    d, ok := f.entries[n.Encrypted]
+
+    if !ok {
+        return nil, rest.ErrNotFound
+    }
+
+    // Like the client, hand out a copy.
+    c := *d
+    name := *d.Name
+    c.Name = &name
+    return &c, nil
+}
+
+func (f *fakeVault) VaultWriteSecret(d *rest.DecodedEntry) error {
+    if err := d.Validate(); err != nil {
+        return err
+    }
+
+    if d.Name.Encrypted == "" {
+        f.next++
+        d.Name.Encrypted = fmt.Sprintf("key%d", f.next)
+    }
+
+    d.Name.Type = d.Type
+    f.entries[d.Name.Encrypted] = d
+    return nil
+}
+
+func (f *fakeVault) VaultRenameSecret(d *rest.DecodedEntry, name string) error {
+    delete(f.entries, d.Name.Encrypted)
+    d.Name = &rest.Name{Text: name}
+    return f.VaultWriteSecret(d)
+}
+
+func (f *fakeVault) VaultDeleteSecret(d *rest.DecodedEntry) error {
+    delete(f.entries, d.Name.Encrypted)
+    return nil
+}
+
+func (f *fakeVault) SearchNotes(names []rest.Name, query string) (map[string]bool, error) {
+    return map[string]bool{}, nil
+}
+
+func (f *fakeVault) SearchURLs(names []rest.Name, page string, m *urlmatch.Matcher) (map[string]int, error) {
+    scores := map[string]int{}
+    u, _ := urlmatch.Parse(page)
+
+    for _, n := range names {
+        if score, _ := m.Match(f.entries[n.Encrypted].Target(), u); score > 0 {
+            scores[n.Encrypted] = score
+        }
+    }
+
+    return scores, nil
+}
+
+func (f *fakeVault) Wipe() {
+    f.wiped = true
+}
+
+var errPassword = errors.New("wrong password")
+
+// Starts a server and returns it with its login link and a browser.
+func startServer(t *testing.T, v *fakeVault) (*Server, string, *http.Client) {
+    s := New(func(password string) (Vault, error) {
+        if password != "banana" {
+            return nil, errPassword
+        }
+        return v, nil
+    }, urlmatch.NewMatcher(nil))
+
+    listener, link, err := s.Listen("127.0.0.1:0")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    go http.Serve(listener, s)
+    t.Cleanup(func() { listener.Close() })
+
+    jar, _ := cookiejar.New(nil)
+    return s, link, &http.Client{Jar: jar}
+}
+
+func get(t *testing.T, c *http.Client, u string) (int, string) {
+    res, err := c.Get(u)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer res.Body.Close()
+    body, _ := ioutil.ReadAll(res.Body)
+    return res.StatusCode, string(body)
+}
+
+var csrfPattern = regexp.MustCompile(`name="csrf" value="([0-9a-f]+)"`)
+
+func post(t *testing.T, c *http.Client, u string, values url.Values) (int, string) {
+    res, err := c.PostForm(u, values)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer res.Body.Close()
+    body, _ := ioutil.ReadAll(res.Body)
+    return res.StatusCode, string(body)
+}
+
+// Logs in and unlocks, and returns the base URL and the CSRF token.
+func unlock(t *testing.T, s *Server, link string, c *http.Client) (string, string) {
+    base := "http://" + s.addr
+
+    if status, _ := get(t, c, link); status != http.StatusOK {
+        t.Fatalf("Could not log in: %d", status)
+    }
+
+    csrf := s.csrfToken()
+
+    if status, _ := post(t, c, base+"/unlock", url.Values{"csrf": {csrf}, "password": {"banana"}}); status != http.StatusOK {
+        t.Fatalf("Could not unlock: %d", status)
+    }
+
+    return base, csrf
+}
+
+func TestListenOnlyOnLoopback(t *testing.T) {
+    s := New(nil, nil)
+
+    if _, _, err := s.Listen("0.0.0.0:0"); err != ErrNotLoopback {
+        t.Errorf("Listened on all interfaces: %v", err)
+    }
+}
+
+func TestLogin(t *testing.T) {
+    s, link, c := startServer(t, &fakeVault{entries: map[string]*rest.DecodedEntry{}})
+    base := "http://" + s.addr
+
+    if status, _ := get(t, c, base+"/"); status != http.StatusForbidden {
+        t.Errorf("Served without a session: %d", status)
+    }
+
+    if status, _ := get(t, c, strings.Replace(link, "token=", "token=00", 1)); status != http.StatusForbidden {
+        t.Errorf("Logged in with a wrong token: %d", status)
+    }
+
+    status, body := get(t, c, link)
+
+    if status != http.StatusOK || !strings.Contains(body, `name="password"`) {
+        t.Fatalf("Login did not lead to the unlock screen: %d %s", status, body)
+    }
+
+    // The link works only once.
+    other, _ := cookiejar.New(nil)
+
+    if status, _ = get(t, &http.Client{Jar: other}, link); status != http.StatusForbidden {
+        t.Errorf("Login link worked twice: %d", status)
+    }
+
+    res, err := c.Get(base + "/")
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    res.Body.Close()
+
+    for header, value := range map[string]string{
+        "Content-Security-Policy": contentSecurityPolicy,
+        "X-Frame-Options":         "DENY",
+        "X-Content-Type-Options":  "nosniff",
+        "Cache-Control":           "no-store",
+    } {
+        if res.Header.Get(header) != value {
+            t.Errorf("Header %s is %q", header, res.Header.Get(header))
+        }
+    }
+
+    // DNS rebinding: another name for our address.
+    req, _ := http.NewRequest(http.MethodGet, base+"/", nil)
+    req.Host = "evil.example.com"
+
+    if res, err = c.Do(req); err != nil || res.StatusCode != http.StatusMisdirectedRequest {
+        t.Errorf("Served for another host: %v %v", res, err)
+    }
+}
+
+func TestCSRF(t *testing.T) {
+    s, link, c := startServer(t, &fakeVault{entries: map[string]*rest.DecodedEntry{}})
+    base := "http://" + s.addr
+    get(t, c, link)
+
+    if status, _ := post(t, c, base+"/unlock", url.Values{"password": {"banana"}}); status != http.StatusForbidden {
+        t.Errorf("Form without CSRF token accepted: %d", status)
+    }
+
+    req, _ := http.NewRequest(http.MethodPost, base+"/unlock",
+        strings.NewReader(url.Values{"csrf": {s.csrfToken()}, "password": {"banana"}}.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Origin", "http://evil.example.com")
+
+    if res, err := c.Do(req); err != nil || res.StatusCode != http.StatusForbidden {
+        t.Errorf("Form from another origin accepted: %v %v", res, err)
+    }
+
+    if _, ok := s.unlocked(); ok {
+        t.Errorf("Unlocked by a forged form")
+    }
+
+    status, body := post(t, c, base+"/unlock", url.Values{"csrf": {s.csrfToken()}, "password": {"apple"}})
+
+    if status != http.StatusUnauthorized || !strings.Contains(body, errPassword.Error()) {
+        t.Errorf("Wrong password accepted: %d", status)
+    }
+}
+
+func TestSearchAndEdit(t *testing.T) {
+    v := &fakeVault{entries: map[string]*rest.DecodedEntry{}}
+    v.VaultWriteSecret(&rest.DecodedEntry{Name: &rest.Name{Text: "Work/github"}, Username: "carl",
+        Password: lock.SecureCopy([]byte("hunter2")), URLs: []string{"https://github.com/login"}})
+    v.VaultWriteSecret(&rest.DecodedEntry{Name: &rest.Name{Text: "<script>"}, Password: lock.SecureCopy([]byte("x"))})
+
+    s, link, c := startServer(t, v)
+    base, csrf := unlock(t, s, link, c)
+
+    _, body := get(t, c, base+"/")
+
+    if !strings.Contains(body, ">Work<") || strings.Contains(body, "github") {
+        t.Errorf("Root folder not shown: %s", body)
+    }
+
+    if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+        t.Errorf("Name was not escaped")
+    }
+
+    _, body = get(t, c, base+"/?q=github.com")
+
+    if !strings.Contains(body, "Work/github") {
+        t.Errorf("Entry not found by URL: %s", body)
+    }
+
+    _, body = get(t, c, base+"/entry?key=key1")
+
+    if !strings.Contains(body, `value="carl"`) || strings.Contains(body, "hunter2") {
+        t.Errorf("Password shown without asking: %s", body)
+    }
+
+    if _, body = get(t, c, base+"/entry?key=key1&reveal=1"); !strings.Contains(body, "hunter2") {
+        t.Errorf("Password not revealed")
+    }
+
+    // An empty password keeps the password.
+    status, _ := post(t, c, base+"/save", url.Values{"csrf": {csrf}, "key": {"key1"},
+        "name": {"Home/github"}, "username": {"grocid"}, "urls": {"https://github.com"},
+        "match": {"host"}, "tags": {"a, b"}})
+
+    if status != http.StatusOK {
+        t.Fatalf("Could not save: %d", status)
+    }
+
+    d := v.entries["key3"]
+
+    if d == nil || d.Name.Text != "Home/github" || d.Username != "grocid" || !d.HasPassword("hunter2") ||
+        d.Match != urlmatch.ModeHost || len(d.Tags) != 2 || v.entries["key1"] != nil {
+        t.Errorf("Entry was not saved and renamed: %v", d)
+    }
+
+    // Changes are only made by POST.
+    if status, _ = get(t, c, base+"/delete?key=key3&csrf="+csrf); status != http.StatusMethodNotAllowed {
+        t.Errorf("Deleted with GET: %d", status)
+    }
+
+    post(t, c, base+"/delete", url.Values{"csrf": {csrf}, "key": {"key3"}})
+
+    if v.entries["key3"] != nil {
+        t.Errorf("Entry was not deleted")
+    }
+
+    if v.wiped {
+        t.Errorf("Vault wiped while unlocked")
+    }
+
+    post(t, c, base+"/lock", url.Values{"csrf": {csrf}})
+
+    if !v.wiped {
+        t.Errorf("Vault not wiped when locking")
+    }
+
+    if _, body = get(t, c, base+"/entry?key=key2"); !strings.Contains(body, `name="password"`) || strings.Contains(body, "script") {
+        t.Errorf("Entry shown after locking: %s", body)
+    }
+}
+
+func upload(t *testing.T, c *http.Client, u string, fields map[string]string, data []byte) (int, []byte) {
+    var buf bytes.Buffer
+    w := multipart.NewWriter(&buf)
+
+    for k, v := range fields {
+        w.WriteField(k, v)
+    }
+
+    f, _ := w.CreateFormFile("file", "release.tar.gz")
+    f.Write(data)
+    w.Close()
+
+    res, err := c.Post(u, w.FormDataContentType(), &buf)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer res.Body.Close()
+    body, _ := ioutil.ReadAll(res.Body)
+    return res.StatusCode, body
+}
+
+func TestFileAndSign(t *testing.T) {
+    v := &fakeVault{entries: map[string]*rest.DecodedEntry{}}
+    s, link, c := startServer(t, v)
+    base, csrf := unlock(t, s, link, c)
+
+    status, _ := upload(t, c, base+"/upload", map[string]string{"csrf": csrf, "type": "file"}, []byte("secret"))
+
+    if d := v.entries["key1"]; status != http.StatusOK || d == nil || d.Name.Text != "release.tar.gz" || string(d.File) != "secret" {
+        t.Fatalf("File was not stored: %d %v", status, d)
+    }
+
+    if status, body := get(t, c, base+"/file?key=key1"); status != http.StatusOK || body != "secret" {
+        t.Errorf("Unexpected download %d %q", status, body)
+    }
+
+    post(t, c, base+"/generate", url.Values{"csrf": {csrf}, "type": {"sign"}, "name": {"release"}})
+    k, ok := v.entries["key2"].Payload.(*rest.KeyPair)
+
+    if !ok {
+        t.Fatalf("No key was generated")
+    }
+
+    status, signature := upload(t, c, base+"/sign", map[string]string{"csrf": csrf, "key": "key2"}, []byte("data"))
+
+    if status != http.StatusOK || !ed25519.Verify(ed25519.PublicKey(k.Pub), []byte("data"), signature) {
+        t.Errorf("Invalid signature: %d", status)
+    }
+
+    if status, _ = upload(t, c, base+"/sign", map[string]string{"key": "key2"}, []byte("data")); status != http.StatusForbidden {
+        t.Errorf("Signed without CSRF token: %d", status)
+    }
+}